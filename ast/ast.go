@@ -37,6 +37,9 @@ type TranslationUnit struct {
 	Back       map[int]int
 	Referenced map[int]int
 	Definition map[int]int
+
+	// Source locations of the cursors and tokens, and the files they are in.
+	Locations
 }
 
 // DecodeFinish Completes the setup of the lists and maps after the object
@@ -45,6 +48,7 @@ func (tu *TranslationUnit) DecodeFinish() {
 	tu.CursorNameMap.DecodeFinish()
 	tu.TokenMap.DecodeFinish()
 	tu.TokenNameMap.DecodeFinish()
+	tu.Files.DecodeFinish()
 	//Maybe don't call this here after all.
 	//tu.SetBackChildren()
 }
@@ -52,28 +56,33 @@ func (tu *TranslationUnit) DecodeFinish() {
 // SetBackChildren sets the Children (probably one child) for
 // each of the Back Cursors. Done by looking through the Back map,
 // since looking through the Cursors for a Back kind would not lead
-// to knowing where to point anywhere.
-func (tu *TranslationUnit) SetBackChildren() {
-	for backId, seenId := range tu.Back {
-		if backId <= seenId {
-			// TBD make these errors
-			panic("back not after seen")
-		}
-		if backId >= len(tu.Cursors) {
-			panic("back out of range")
-		}
-		if seenId >= len(tu.Cursors) {
-			panic("seen out of range")
+// to knowing where to point anywhere. The entries that cannot be
+// set are left out, and a *TuErr returned that tells of them.
+func (tu *TranslationUnit) SetBackChildren() error {
+	e := tu.Err()
+	for _, backId := range sortedKeys(tu.Back) {
+		seenId := tu.Back[backId]
+		var msg string
+		switch {
+		case backId <= seenId:
+			msg = "back not after seen"
+		case backId >= len(tu.Cursors):
+			msg = "back out of range"
+		case seenId < 0:
+			msg = "seen out of range"
+		case tu.Cursors[backId].CursorKindId != cursorkind.Back:
+			msg = "backId not leading to Back cursor"
+		case tu.Cursors[backId].Children.Len != 0:
+			msg = "backId leads to Back cursor with children already"
 		}
-		if tu.Cursors[backId].CursorKindId != cursorkind.Back {
-			panic("backId not leading to Back cursor")
-		}
-		if tu.Cursors[backId].Children.Len != 0 {
-			panic("backId leads to Back cursor with children already")
+		if msg != "" {
+			e.Msg(fmt.Sprintf("Back %d:%d: %s", backId, seenId, msg))
+			continue
 		}
 		tu.Cursors[backId].Children.Head = seenId
 		tu.Cursors[backId].Children.Len++
 	}
+	return e.checked()
 }
 
 func (tu *TranslationUnit) AssertEqual(tu2 *TranslationUnit) error {
@@ -103,6 +112,9 @@ func (tu *TranslationUnit) AssertEqual(tu2 *TranslationUnit) error {
 		fmt.Println("assertEqualMaps(tu.Definition, tu2.Definition)")
 		return err
 	}
+	if err := tu.Locations.AssertEqual(&tu2.Locations); err != nil {
+		return err
+	}
 	return nil
 }
 
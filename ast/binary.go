@@ -0,0 +1,937 @@
+package ast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/nullability"
+	"github.com/frankreh/go-clang/clang/tokenkind"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+// The binary format, version 2, of a TranslationUnit.
+//
+// Version 1 is the gob stream of EncodeGobV1. Version 2 is smaller, says
+// what it is and lets its parts be loaded on their own. It is a header
+// followed by sections:
+//
+//	magic     the 8 bytes of BinaryMagic
+//	version   2
+//	sections  the number of sections, then the id, offset and length of
+//	          each, the offsets counted from the end of the header
+//
+// All numbers are varints as encoding/binary writes them: counts, lengths
+// and string indexes unsigned, everything else signed. A string is written
+// once, in the strings section, and elsewhere by its index there. A list is
+// its length followed by its elements. The sections, by id, are:
+//
+//	1 strings    the strings, each its length and its bytes
+//	2 cursors    the number of cursors, then one column per field of the
+//	             cursors: kinds, name ids, parents as the distance back to
+//	             them, type indexes, token heads as the difference with the
+//	             previous head, and token lengths; then the CursorNameMap,
+//	             and the Back, Referenced and Definition maps as their
+//	             length and pairs, in the order of their keys, the keys as
+//	             the difference with the previous key
+//	3 tokens     the TokenIds, the TokenMap as the kind and name id of each
+//	             token, and the TokenNameMap
+//	4 types      the TypeMap, a list per slice of it, each element its
+//	             fields in order
+//	5 locations  the Files, the CursorLocs as four columns, file ids,
+//	             offsets as the difference with the previous offset, lines
+//	             and columns, the CursorSpellings as pairs, then the
+//	             TokenLocs and TokenSpellings likewise
+//
+// The children of the cursors are not written; they are rebuilt from the
+// parents. Readers skip the sections they do not know.
+const BinaryMagic = "GOCLAST\x00"
+
+// BinaryVersion is the version of the binary format EncodeBinary writes.
+const BinaryVersion = 2
+
+const (
+	stringsSection = iota + 1
+	cursorsSection
+	tokensSection
+	typesSection
+	locationsSection
+)
+
+// ErrNotBinary is returned when decoding what does not start with
+// BinaryMagic.
+var ErrNotBinary = errors.New("ast: not a binary encoded translation unit")
+
+// binaryWriter appends varints to a section.
+type binaryWriter struct {
+	buf     []byte
+	strings *StringMap // Shared by the sections.
+	tmp     [binary.MaxVarintLen64]byte
+}
+
+func (w *binaryWriter) uint(v int) {
+	n := binary.PutUvarint(w.tmp[:], uint64(v))
+	w.buf = append(w.buf, w.tmp[:n]...)
+}
+
+func (w *binaryWriter) int(v int) {
+	n := binary.PutVarint(w.tmp[:], int64(v))
+	w.buf = append(w.buf, w.tmp[:n]...)
+}
+
+func (w *binaryWriter) bool(v bool) {
+	if v {
+		w.uint(1)
+	} else {
+		w.uint(0)
+	}
+}
+
+func (w *binaryWriter) str(s string) {
+	w.uint(w.strings.Id(s))
+}
+
+func (w *binaryWriter) ints(l []int) {
+	w.uint(len(l))
+	for _, v := range l {
+		w.int(v)
+	}
+}
+
+func (w *binaryWriter) strs(l []string) {
+	w.uint(len(l))
+	for _, s := range l {
+		w.str(s)
+	}
+}
+
+// intMap writes m as its length and its pairs, in the order of the keys.
+func (w *binaryWriter) intMap(m map[int]int) {
+	keys := sortedKeys(m)
+	w.uint(len(keys))
+	prev := 0
+	for _, k := range keys {
+		w.int(k - prev)
+		w.int(m[k])
+		prev = k
+	}
+}
+
+// EncodeBinary writes the translation unit to w in the binary format
+// described by BinaryMagic.
+func (tu *TranslationUnit) EncodeBinary(w io.Writer) error {
+	strs := &StringMap{}
+	sections := []struct {
+		id     int
+		encode func(w *binaryWriter)
+	}{
+		{cursorsSection, tu.encodeCursors},
+		{tokensSection, tu.encodeTokens},
+		{typesSection, tu.TypeMap.encode},
+		{locationsSection, tu.Locations.encode},
+	}
+	bodies := make([][]byte, len(sections))
+	for i, s := range sections {
+		bw := &binaryWriter{strings: strs}
+		s.encode(bw)
+		bodies[i] = bw.buf
+	}
+	// The strings are known once the other sections are written.
+	sw := &binaryWriter{strings: strs}
+	sw.uint(len(strs.Strings))
+	for _, s := range strs.Strings {
+		sw.uint(len(s))
+		sw.buf = append(sw.buf, s...)
+	}
+
+	ids := []int{stringsSection}
+	bodies = append([][]byte{sw.buf}, bodies...)
+	for _, s := range sections {
+		ids = append(ids, s.id)
+	}
+	hw := &binaryWriter{buf: []byte(BinaryMagic)}
+	hw.uint(BinaryVersion)
+	hw.uint(len(bodies))
+	offset := 0
+	for i, body := range bodies {
+		hw.uint(ids[i])
+		hw.uint(offset)
+		hw.uint(len(body))
+		offset += len(body)
+	}
+	if _, err := w.Write(hw.buf); err != nil {
+		return err
+	}
+	for _, body := range bodies {
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tu *TranslationUnit) encodeCursors(w *binaryWriter) {
+	w.uint(len(tu.Cursors))
+	for i := range tu.Cursors {
+		w.int(int(tu.Cursors[i].CursorKindId))
+	}
+	for i := range tu.Cursors {
+		w.int(tu.Cursors[i].CursorNameId)
+	}
+	for i := range tu.Cursors {
+		w.int(i - tu.Cursors[i].ParentIndex)
+	}
+	for i := range tu.Cursors {
+		w.int(tu.Cursors[i].TypeIndex)
+	}
+	prev := 0
+	for i := range tu.Cursors {
+		w.int(tu.Cursors[i].Tokens.Head - prev)
+		prev = tu.Cursors[i].Tokens.Head
+	}
+	for i := range tu.Cursors {
+		w.int(tu.Cursors[i].Tokens.Len)
+	}
+	w.strs(tu.CursorNameMap.Strings)
+	w.intMap(tu.Back)
+	w.intMap(tu.Referenced)
+	w.intMap(tu.Definition)
+}
+
+func (tu *TranslationUnit) encodeTokens(w *binaryWriter) {
+	w.uint(len(tu.TokenIds))
+	for _, id := range tu.TokenIds {
+		w.int(int(id))
+	}
+	w.uint(len(tu.TokenMap.Tokens))
+	for _, t := range tu.TokenMap.Tokens {
+		w.int(int(t.TokenKindId))
+		w.int(t.TokenNameId)
+	}
+	w.strs(tu.TokenNameMap.Strings)
+}
+
+func (tm *TypeMap) encode(w *binaryWriter) {
+	w.uint(len(tm.Keys))
+	for _, k := range tm.Keys {
+		w.int(int(k.TypeKind))
+		w.int(k.TypeId)
+		w.uint(int(k.Qualifiers))
+	}
+	w.uint(len(tm.Intrinsics))
+	for _, t := range tm.Intrinsics {
+		w.int(int(t.TypeKind))
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.Records))
+	for _, t := range tm.Records {
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+		w.uint(len(t.Fields))
+		for _, f := range t.Fields {
+			w.str(f.Name)
+			w.int(f.TypeId)
+			w.int(f.Offset)
+			w.int(f.BitWidth)
+		}
+	}
+	w.uint(len(tm.Enums))
+	for _, t := range tm.Enums {
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.Typedefs))
+	for _, t := range tm.Typedefs {
+		w.int(t.UnderlyingTypeIndex)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.Functions))
+	for _, t := range tm.Functions {
+		w.int(int(t.TypeKind))
+		w.int(t.ResultTypeId)
+		w.ints(t.ArgIds)
+		w.str(t.TypeSpelling)
+		w.int(t.CallingConv)
+		w.bool(t.Variadic)
+		w.int(t.ExceptionSpec)
+	}
+	w.uint(len(tm.Arrays))
+	for _, t := range tm.Arrays {
+		w.int(t.ElemCount)
+		w.int(t.ElemTypeId)
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.MemberPointers))
+	for _, t := range tm.MemberPointers {
+		w.int(t.PointeeTypeId)
+		w.int(t.ClassTypeId)
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.Vectors))
+	for _, t := range tm.Vectors {
+		w.int(int(t.TypeKind))
+		w.int(t.ElemTypeId)
+		w.int(t.ElemCount)
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.Autos))
+	for _, t := range tm.Autos {
+		w.int(t.DeducedTypeId)
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.Attributeds))
+	for _, t := range tm.Attributeds {
+		w.int(t.ModifiedTypeId)
+		w.int(int(t.Nullability))
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.TemplateSpecializations))
+	for _, t := range tm.TemplateSpecializations {
+		w.int(t.CanonicalTypeId)
+		w.ints(t.ArgTypeIds)
+		w.int(t.Align)
+		w.int(t.Size)
+		w.str(t.TypeSpelling)
+	}
+	w.uint(len(tm.ObjCs))
+	for _, t := range tm.ObjCs {
+		w.int(int(t.TypeKind))
+		w.int(t.BaseTypeId)
+		w.ints(t.TypeArgIds)
+		w.strs(t.Protocols)
+		w.str(t.TypeSpelling)
+	}
+}
+
+func (l *Locations) encode(w *binaryWriter) {
+	w.strs(l.Files.Strings)
+	encodeBinaryLocations(w, l.CursorLocs, l.CursorSpellings)
+	encodeBinaryLocations(w, l.TokenLocs, l.TokenSpellings)
+}
+
+func encodeBinaryLocations(w *binaryWriter, locs []Location, spellings map[int]Location) {
+	w.uint(len(locs))
+	for _, l := range locs {
+		w.int(l.FileId)
+	}
+	prev := 0
+	for _, l := range locs {
+		w.int(l.Offset - prev)
+		prev = l.Offset
+	}
+	for _, l := range locs {
+		w.int(l.Line)
+	}
+	for _, l := range locs {
+		w.int(l.Column)
+	}
+	keys := sortedLocationKeys(spellings)
+	w.uint(len(keys))
+	prevKey := 0
+	for _, k := range keys {
+		l := spellings[k]
+		w.int(k - prevKey)
+		w.int(l.FileId)
+		w.int(l.Offset)
+		w.int(l.Line)
+		w.int(l.Column)
+		prevKey = k
+	}
+}
+
+// binaryReader reads the varints of a section. The first error met stops
+// the reading; the values read after it are zeros.
+type binaryReader struct {
+	name    string // Of the section, for errors.
+	buf     []byte
+	pos     int
+	strings []string
+	err     error
+}
+
+func (r *binaryReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("ast: %s section at %d: %s", r.name, r.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *binaryReader) uint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 || v > uint64(maxInt) {
+		r.fail("bad unsigned varint")
+		return 0
+	}
+	r.pos += n
+	return int(v)
+}
+
+func (r *binaryReader) int() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 || v > int64(maxInt) || v < int64(minInt) {
+		r.fail("bad varint")
+		return 0
+	}
+	r.pos += n
+	return int(v)
+}
+
+func (r *binaryReader) bool() bool {
+	return r.uint() != 0
+}
+
+// count reads the length of a list whose elements take at least min bytes
+// each, so a bad length is caught before it is allocated.
+func (r *binaryReader) count(min int) int {
+	n := r.uint()
+	if r.err == nil && n > (len(r.buf)-r.pos)/min {
+		r.fail("length %d beyond the end of the section", n)
+		return 0
+	}
+	return n
+}
+
+func (r *binaryReader) str() string {
+	i := r.uint()
+	if r.err != nil {
+		return ""
+	}
+	if i >= len(r.strings) {
+		r.fail("string %d out of range %d", i, len(r.strings))
+		return ""
+	}
+	return r.strings[i]
+}
+
+func (r *binaryReader) ints() []int {
+	n := r.count(1)
+	if n == 0 {
+		return nil
+	}
+	l := make([]int, n)
+	for i := range l {
+		l[i] = r.int()
+	}
+	return l
+}
+
+func (r *binaryReader) strs() []string {
+	n := r.count(1)
+	if n == 0 {
+		return nil
+	}
+	l := make([]string, n)
+	for i := range l {
+		l[i] = r.str()
+	}
+	return l
+}
+
+func (r *binaryReader) intMap() map[int]int {
+	n := r.count(2)
+	m := make(map[int]int, n)
+	k := 0
+	for i := 0; i < n; i++ {
+		k += r.int()
+		m[k] = r.int()
+	}
+	return m
+}
+
+// done returns the error met, if any, or an error if the section is not all
+// read.
+func (r *binaryReader) done() error {
+	if r.err == nil && r.pos != len(r.buf) {
+		r.fail("%d bytes left over", len(r.buf)-r.pos)
+	}
+	return r.err
+}
+
+const (
+	maxInt = int(^uint(0) >> 1)
+	minInt = -maxInt - 1
+)
+
+// BinaryReader reads a translation unit in the binary format, one part at a
+// time, as it is needed: the cursors, the tokens, the types or the
+// locations. The strings shared by the parts are read once.
+type BinaryReader struct {
+	r        io.ReaderAt
+	version  int
+	base     int64 // Where the sections start.
+	sections map[int]section
+	strings  []string
+}
+
+type section struct {
+	offset, length int64
+}
+
+// byteCounter counts the bytes read through it.
+type byteCounter struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *byteCounter) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// NewBinaryReader reads the header of the translation unit of size bytes
+// that r reads. It returns ErrNotBinary if it does not start with
+// BinaryMagic.
+func NewBinaryReader(r io.ReaderAt, size int64) (*BinaryReader, error) {
+	c := &byteCounter{r: bufio.NewReader(io.NewSectionReader(r, 0, size))}
+	magic := make([]byte, len(BinaryMagic))
+	if _, err := io.ReadFull(c.r, magic); err != nil || string(magic) != BinaryMagic {
+		return nil, ErrNotBinary
+	}
+	c.n = int64(len(magic))
+	header := func() (int64, error) {
+		v, err := binary.ReadUvarint(c)
+		if err != nil {
+			return 0, fmt.Errorf("ast: binary header: %v", err)
+		}
+		if v > uint64(size) {
+			return 0, fmt.Errorf("ast: binary header: value %d beyond the size %d", v, size)
+		}
+		return int64(v), nil
+	}
+	version, err := header()
+	if err != nil {
+		return nil, err
+	}
+	if version != BinaryVersion {
+		return nil, fmt.Errorf("ast: binary version %d, not %d", version, BinaryVersion)
+	}
+	count, err := header()
+	if err != nil {
+		return nil, err
+	}
+	br := &BinaryReader{r: r, version: int(version), sections: make(map[int]section)}
+	for i := int64(0); i < count; i++ {
+		var v [3]int64
+		for j := range v {
+			if v[j], err = header(); err != nil {
+				return nil, err
+			}
+		}
+		br.sections[int(v[0])] = section{offset: v[1], length: v[2]}
+	}
+	br.base = c.n
+	for id, s := range br.sections {
+		if s.offset+s.length > size-br.base {
+			return nil, fmt.Errorf("ast: binary section %d beyond the end", id)
+		}
+	}
+	return br, nil
+}
+
+// Version returns the version of the binary format read.
+func (br *BinaryReader) Version() int {
+	return br.version
+}
+
+var sectionNames = map[int]string{
+	stringsSection:   "strings",
+	cursorsSection:   "cursors",
+	tokensSection:    "tokens",
+	typesSection:     "types",
+	locationsSection: "locations",
+}
+
+// section returns a reader of the section.
+func (br *BinaryReader) section(id int) (*binaryReader, error) {
+	s, ok := br.sections[id]
+	if !ok {
+		return nil, fmt.Errorf("ast: binary has no %s section", sectionNames[id])
+	}
+	buf := make([]byte, s.length)
+	if _, err := br.r.ReadAt(buf, br.base+s.offset); err != nil {
+		return nil, err
+	}
+	if id != stringsSection && br.strings == nil {
+		if err := br.readStrings(); err != nil {
+			return nil, err
+		}
+	}
+	return &binaryReader{name: sectionNames[id], buf: buf, strings: br.strings}, nil
+}
+
+func (br *BinaryReader) readStrings() error {
+	r, err := br.section(stringsSection)
+	if err != nil {
+		return err
+	}
+	strs := make([]string, r.count(1))
+	for i := range strs {
+		n := r.uint()
+		if r.err == nil && n > len(r.buf)-r.pos {
+			r.fail("string of length %d beyond the end of the section", n)
+		}
+		if r.err != nil {
+			break
+		}
+		strs[i] = string(r.buf[r.pos : r.pos+n])
+		r.pos += n
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	br.strings = strs
+	return nil
+}
+
+// LoadCursors loads the cursors of the translation unit into tu, along with
+// the CursorNameMap and the Back, Referenced and Definition maps.
+//
+// Each of the loads checks what it loads refers to within it, and returns a
+// *TuErr that tells what is out of place. Load checks the rest.
+func (br *BinaryReader) LoadCursors(tu *TranslationUnit) error {
+	r, err := br.section(cursorsSection)
+	if err != nil {
+		return err
+	}
+	n := r.count(6)
+	var cursors []Cursor
+	if n > 0 {
+		cursors = make([]Cursor, n)
+	}
+	for i := range cursors {
+		cursors[i].CursorKindId = cursorkind.Kind(r.int())
+	}
+	for i := range cursors {
+		cursors[i].CursorNameId = r.int()
+	}
+	for i := range cursors {
+		p := i - r.int()
+		cursors[i].ParentIndex = p
+		if p < 0 || p >= n {
+			continue // Root will have parent set to -1, checked below.
+		}
+		c := &cursors[p].Children
+		if c.Head == 0 {
+			c.Head = i
+		}
+		c.Len++
+	}
+	for i := range cursors {
+		cursors[i].TypeIndex = r.int()
+	}
+	head := 0
+	for i := range cursors {
+		head += r.int()
+		cursors[i].Tokens.Head = head
+	}
+	for i := range cursors {
+		cursors[i].Tokens.Len = r.int()
+	}
+	names := r.strs()
+	back := r.intMap()
+	referenced := r.intMap()
+	definition := r.intMap()
+	if err := r.done(); err != nil {
+		return err
+	}
+	tu.Cursors = cursors
+	tu.CursorNameMap = StringMap{Strings: names}
+	tu.CursorNameMap.DecodeFinish()
+	tu.Back = back
+	tu.Referenced = referenced
+	tu.Definition = definition
+	e := tu.Err()
+	tu.checkCursors(e)
+	tu.checkChildren(e)
+	return e.checked()
+}
+
+// LoadTokens loads the TokenIds, TokenMap and TokenNameMap of the
+// translation unit into tu.
+func (br *BinaryReader) LoadTokens(tu *TranslationUnit) error {
+	r, err := br.section(tokensSection)
+	if err != nil {
+		return err
+	}
+	var ids []TokenId
+	if n := r.count(1); n > 0 {
+		ids = make([]TokenId, n)
+		for i := range ids {
+			ids[i] = TokenId(r.int())
+		}
+	}
+	var tokens []Token
+	if n := r.count(2); n > 0 {
+		tokens = make([]Token, n)
+		for i := range tokens {
+			tokens[i].TokenKindId = tokenkind.Kind(r.int())
+			tokens[i].TokenNameId = r.int()
+		}
+	}
+	names := r.strs()
+	if err := r.done(); err != nil {
+		return err
+	}
+	tu.TokenIds = ids
+	tu.TokenMap = TokenMap{Tokens: tokens}
+	tu.TokenMap.DecodeFinish()
+	tu.TokenNameMap = StringMap{Strings: names}
+	tu.TokenNameMap.DecodeFinish()
+	e := tu.Err()
+	tu.checkTokens(e)
+	return e.checked()
+}
+
+// LoadTypes loads the TypeMap of the translation unit into tu.
+func (br *BinaryReader) LoadTypes(tu *TranslationUnit) error {
+	r, err := br.section(typesSection)
+	if err != nil {
+		return err
+	}
+	var tm TypeMap
+	if n := r.count(3); n > 0 {
+		tm.Keys = make([]TypeKey, n)
+		for i := range tm.Keys {
+			tm.Keys[i] = TypeKey{TypeKind: typekind.Kind(r.int()), TypeId: r.int(), Qualifiers: Qualifiers(r.uint())}
+		}
+	}
+	if n := r.count(4); n > 0 {
+		tm.Intrinsics = make([]TypeIntrinsic, n)
+		for i := range tm.Intrinsics {
+			t := &tm.Intrinsics[i]
+			t.TypeKind = typekind.Kind(r.int())
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(4); n > 0 {
+		tm.Records = make([]TypeRecord, n)
+		for i := range tm.Records {
+			t := &tm.Records[i]
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+			if n := r.count(4); n > 0 {
+				t.Fields = make([]TypeField, n)
+				for j := range t.Fields {
+					f := &t.Fields[j]
+					f.Name, f.TypeId, f.Offset, f.BitWidth = r.str(), r.int(), r.int(), r.int()
+				}
+			}
+		}
+	}
+	if n := r.count(3); n > 0 {
+		tm.Enums = make([]TypeEnum, n)
+		for i := range tm.Enums {
+			t := &tm.Enums[i]
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(2); n > 0 {
+		tm.Typedefs = make([]TypeTypedef, n)
+		for i := range tm.Typedefs {
+			t := &tm.Typedefs[i]
+			t.UnderlyingTypeIndex, t.TypeSpelling = r.int(), r.str()
+		}
+	}
+	if n := r.count(7); n > 0 {
+		tm.Functions = make([]TypeFunction, n)
+		for i := range tm.Functions {
+			t := &tm.Functions[i]
+			t.TypeKind = typekind.Kind(r.int())
+			t.ResultTypeId, t.ArgIds, t.TypeSpelling = r.int(), r.ints(), r.str()
+			t.CallingConv, t.Variadic, t.ExceptionSpec = r.int(), r.bool(), r.int()
+		}
+	}
+	if n := r.count(5); n > 0 {
+		tm.Arrays = make([]TypeConstantArray, n)
+		for i := range tm.Arrays {
+			t := &tm.Arrays[i]
+			t.ElemCount, t.ElemTypeId = r.int(), r.int()
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(5); n > 0 {
+		tm.MemberPointers = make([]TypeMemberPointer, n)
+		for i := range tm.MemberPointers {
+			t := &tm.MemberPointers[i]
+			t.PointeeTypeId, t.ClassTypeId = r.int(), r.int()
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(6); n > 0 {
+		tm.Vectors = make([]TypeVector, n)
+		for i := range tm.Vectors {
+			t := &tm.Vectors[i]
+			t.TypeKind = typekind.Kind(r.int())
+			t.ElemTypeId, t.ElemCount = r.int(), r.int()
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(4); n > 0 {
+		tm.Autos = make([]TypeAuto, n)
+		for i := range tm.Autos {
+			t := &tm.Autos[i]
+			t.DeducedTypeId = r.int()
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(5); n > 0 {
+		tm.Attributeds = make([]TypeAttributed, n)
+		for i := range tm.Attributeds {
+			t := &tm.Attributeds[i]
+			t.ModifiedTypeId, t.Nullability = r.int(), nullability.Kind(r.int())
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(5); n > 0 {
+		tm.TemplateSpecializations = make([]TypeTemplateSpecialization, n)
+		for i := range tm.TemplateSpecializations {
+			t := &tm.TemplateSpecializations[i]
+			t.CanonicalTypeId, t.ArgTypeIds = r.int(), r.ints()
+			t.Align, t.Size, t.TypeSpelling = r.int(), r.int(), r.str()
+		}
+	}
+	if n := r.count(5); n > 0 {
+		tm.ObjCs = make([]TypeObjC, n)
+		for i := range tm.ObjCs {
+			t := &tm.ObjCs[i]
+			t.TypeKind = typekind.Kind(r.int())
+			t.BaseTypeId, t.TypeArgIds, t.Protocols, t.TypeSpelling = r.int(), r.ints(), r.strs(), r.str()
+		}
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	tu.TypeMap = tm
+	e := tu.Err()
+	tu.TypeMap.check(e)
+	return e.checked()
+}
+
+// LoadLocations loads the Locations of the translation unit into tu.
+func (br *BinaryReader) LoadLocations(tu *TranslationUnit) error {
+	r, err := br.section(locationsSection)
+	if err != nil {
+		return err
+	}
+	var l Locations
+	l.Files = StringMap{Strings: r.strs()}
+	l.CursorLocs, l.CursorSpellings = decodeBinaryLocations(r)
+	l.TokenLocs, l.TokenSpellings = decodeBinaryLocations(r)
+	if err := r.done(); err != nil {
+		return err
+	}
+	l.Files.DecodeFinish()
+	tu.Locations = l
+	e := tu.Err()
+	tu.checkLocations(e)
+	return e.checked()
+}
+
+func decodeBinaryLocations(r *binaryReader) ([]Location, map[int]Location) {
+	var locs []Location
+	if n := r.count(4); n > 0 {
+		locs = make([]Location, n)
+		for i := range locs {
+			locs[i].FileId = r.int()
+		}
+		offset := 0
+		for i := range locs {
+			offset += r.int()
+			locs[i].Offset = offset
+		}
+		for i := range locs {
+			locs[i].Line = r.int()
+		}
+		for i := range locs {
+			locs[i].Column = r.int()
+		}
+	}
+	n := r.count(5)
+	spellings := make(map[int]Location, n)
+	k := 0
+	for i := 0; i < n; i++ {
+		k += r.int()
+		spellings[k] = Location{FileId: r.int(), Offset: r.int(), Line: r.int(), Column: r.int()}
+	}
+	return locs, spellings
+}
+
+// Load loads all of the translation unit into tu, and checks what its parts
+// refer to in each other.
+func (br *BinaryReader) Load(tu *TranslationUnit) error {
+	for _, load := range []func(*TranslationUnit) error{
+		br.LoadCursors,
+		br.LoadTokens,
+		br.LoadTypes,
+		br.LoadLocations,
+	} {
+		if err := load(tu); err != nil {
+			return err
+		}
+	}
+	e := tu.Err()
+	tu.checkLinks(e)
+	return e.checked()
+}
+
+// DecodeBinary reads a translation unit written by EncodeBinary from r.
+func (tu *TranslationUnit) DecodeBinary(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	br, err := NewBinaryReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	return br.Load(tu)
+}
+
+// Decode reads a translation unit from r written by EncodeBinary or, for
+// what was written before the binary format, by EncodeGobV1.
+func (tu *TranslationUnit) Decode(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(data, []byte(BinaryMagic)) {
+		return tu.DecodeGobV1(bytes.NewReader(data))
+	}
+	return tu.DecodeBinary(bytes.NewReader(data))
+}
+
+// MigrateGobV1 reads a translation unit written by EncodeGobV1 from r and
+// writes it to w with EncodeBinary.
+func MigrateGobV1(w io.Writer, r io.Reader) error {
+	var tu TranslationUnit
+	if err := tu.DecodeGobV1(r); err != nil {
+		return err
+	}
+	return tu.EncodeBinary(w)
+}
@@ -0,0 +1,293 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/tokenkind"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+// The checks below are run on what is decoded, so a corrupt encoding is
+// reported rather than left to panic, or to loop, when used, and by
+// Validate. Each adds a message to e for every problem it finds.
+
+// idCheck reports whether id is a valid id into a map of length n. Id 0,
+// the zero value, is let through for a map holding nothing, as it is for
+// the types and files, whose lookups report rather than panic. Names and
+// tokens are looked up directly, so their ids must always be in range.
+func idCheck(id, n int) bool {
+	return id == 0 || (id > 0 && id < n)
+}
+
+// checkCursors checks the kinds and names of the cursors, that each but the
+// root has a parent before it, and the cursors the Back, Referenced and
+// Definition maps lead from and to. Referenced and Definition lead to -1
+// for a cursor not seen when they were populated.
+func (tu *TranslationUnit) checkCursors(e *TuErr) {
+	n := len(tu.Cursors)
+	for i := range tu.Cursors {
+		c := &tu.Cursors[i]
+		if _, err := cursorkind.Validate(int(c.CursorKindId)); err != nil {
+			e.Msg(fmt.Sprintf("cursor %d: kind %d: %v", i, int(c.CursorKindId), err))
+		}
+		if c.CursorNameId < 0 || c.CursorNameId >= tu.CursorNameMap.Len() {
+			e.Msg(fmt.Sprintf("cursor %d: CursorNameMap %v", i, OutOfRangeErr{c.CursorNameId, tu.CursorNameMap.Len()}))
+		}
+		switch p := c.ParentIndex; {
+		case i == 0 && p != -1:
+			e.Msg(fmt.Sprintf("cursor 0: root with parent %d", p))
+		case i > 0 && (p < 0 || p >= i):
+			e.Msg(fmt.Sprintf("cursor %d: parent %d not before it", i, p))
+		}
+	}
+	for _, m := range []struct {
+		name   string
+		m      map[int]int
+		unseen int // The least cursor led to.
+	}{
+		{"Back", tu.Back, 0},
+		{"Referenced", tu.Referenced, -1},
+		{"Definition", tu.Definition, -1},
+	} {
+		for _, k := range sortedKeys(m.m) {
+			if v := m.m[k]; k < 0 || k >= n || v < m.unseen || v >= n {
+				e.Msg(fmt.Sprintf("%s %d:%d: cursors out of range %d", m.name, k, v, n))
+			}
+		}
+	}
+	tu.CursorNameMap.check(e, "CursorNameMap")
+}
+
+// checkTokens checks the TokenIds lead into the TokenMap, and the kinds and
+// names of its tokens.
+func (tu *TranslationUnit) checkTokens(e *TuErr) {
+	n := tu.TokenMap.Len()
+	for i, id := range tu.TokenIds {
+		if id < 0 || int(id) >= n {
+			e.Msg(fmt.Sprintf("TokenIds %d: TokenMap %v", i, OutOfRangeErr{int(id), n}))
+		}
+	}
+	for i, t := range tu.TokenMap.Tokens {
+		if _, err := tokenkind.Validate(int(t.TokenKindId)); err != nil {
+			e.Msg(fmt.Sprintf("token %d: kind %d: %v", i, int(t.TokenKindId), err))
+		}
+		if t.TokenNameId < 0 || t.TokenNameId >= tu.TokenNameMap.Len() {
+			e.Msg(fmt.Sprintf("token %d: TokenNameMap %v", i, OutOfRangeErr{t.TokenNameId, tu.TokenNameMap.Len()}))
+		}
+	}
+	if len(tu.TokenMap.m) != len(tu.TokenMap.Tokens) {
+		e.Msg("TokenMap with duplicate tokens")
+	}
+	tu.TokenNameMap.check(e, "TokenNameMap")
+}
+
+// check checks the strings, once DecodeFinish has mapped them, are unique.
+func (s *StringMap) check(e *TuErr, name string) {
+	if len(s.m) != len(s.Strings) {
+		e.Msg(name + " with duplicate strings")
+	}
+}
+
+// checkLocations checks the locations lead into the Files.
+func (tu *TranslationUnit) checkLocations(e *TuErr) {
+	n := tu.Files.Len()
+	for _, l := range []struct {
+		name      string
+		locs      []Location
+		spellings map[int]Location
+	}{
+		{"CursorLocs", tu.CursorLocs, tu.CursorSpellings},
+		{"TokenLocs", tu.TokenLocs, tu.TokenSpellings},
+	} {
+		for i, loc := range l.locs {
+			if !idCheck(loc.FileId, n) {
+				e.Msg(fmt.Sprintf("%s %d: Files %v", l.name, i, OutOfRangeErr{loc.FileId, n}))
+			}
+		}
+		for _, k := range sortedLocationKeys(l.spellings) {
+			if loc := l.spellings[k]; !idCheck(loc.FileId, n) {
+				e.Msg(fmt.Sprintf("%s spelling %d: Files %v", l.name, k, OutOfRangeErr{loc.FileId, n}))
+			}
+		}
+	}
+	tu.Files.check(e, "Files")
+}
+
+// checkLinks checks what leads from one part of the translation unit into
+// another: the types and tokens of the cursors, and the locations of the
+// cursors and tokens.
+func (tu *TranslationUnit) checkLinks(e *TuErr) {
+	for i := range tu.Cursors {
+		c := &tu.Cursors[i]
+		if !idCheck(c.TypeIndex, tu.TypeMap.Len()) {
+			e.Msg(fmt.Sprintf("cursor %d: TypeMap %v", i, OutOfRangeErr{c.TypeIndex, tu.TypeMap.Len()}))
+		}
+		if t := c.Tokens; t.Head < 0 || t.Len < 0 || t.Head > len(tu.TokenIds)-t.Len {
+			e.Msg(fmt.Sprintf("cursor %d: tokens %d:%d out of TokenIds %d", i, t.Head, t.Len, len(tu.TokenIds)))
+		}
+	}
+	for _, l := range []struct {
+		name      string
+		locs      []Location
+		spellings map[int]Location
+		n         int
+	}{
+		{"CursorLocs", tu.CursorLocs, tu.CursorSpellings, len(tu.Cursors)},
+		{"TokenLocs", tu.TokenLocs, tu.TokenSpellings, len(tu.TokenIds)},
+	} {
+		// Streams without locations have none.
+		if len(l.locs) != 0 && len(l.locs) != l.n {
+			e.Msg(fmt.Sprintf("%s of length %d for %d", l.name, len(l.locs), l.n))
+		}
+		for _, k := range sortedLocationKeys(l.spellings) {
+			if k < 0 || k >= l.n {
+				e.Msg(fmt.Sprintf("%s spelling %v", l.name, OutOfRangeErr{k, l.n}))
+			}
+		}
+	}
+}
+
+// check checks the kinds of the keys, that each leads to a type, and that
+// the types lead to keys, or to -1 where there may be none. The keys that
+// lead to keys directly, like pointers, must not lead around in a circle,
+// which Spelling would follow forever.
+func (tm *TypeMap) check(e *TuErr) {
+	n := len(tm.Keys)
+	for i, k := range tm.Keys {
+		if _, err := typekind.Validate(int(k.TypeKind)); err != nil {
+			e.Msg(fmt.Sprintf("type %d: kind %d: %v", i, int(k.TypeKind), err))
+			continue
+		}
+		if _, err := tm.Type(i); err != nil {
+			e.Msg(fmt.Sprintf("type %d: %v", i, err))
+		}
+	}
+	ref := func(slice string, i, id int) {
+		if id < -1 || id >= n {
+			e.Msg(fmt.Sprintf("%s %d: Keys %v", slice, i, OutOfRangeErr{id, n}))
+		}
+	}
+	refs := func(slice string, i int, ids []int) {
+		for _, id := range ids {
+			ref(slice, i, id)
+		}
+	}
+	for i, t := range tm.Records {
+		for _, f := range t.Fields {
+			ref("Records", i, f.TypeId)
+		}
+	}
+	for i, t := range tm.Typedefs {
+		ref("Typedefs", i, t.UnderlyingTypeIndex)
+	}
+	for i, t := range tm.Functions {
+		ref("Functions", i, t.ResultTypeId)
+		refs("Functions", i, t.ArgIds)
+	}
+	for i, t := range tm.Arrays {
+		ref("Arrays", i, t.ElemTypeId)
+	}
+	for i, t := range tm.MemberPointers {
+		ref("MemberPointers", i, t.PointeeTypeId)
+		ref("MemberPointers", i, t.ClassTypeId)
+	}
+	for i, t := range tm.Vectors {
+		ref("Vectors", i, t.ElemTypeId)
+	}
+	for i, t := range tm.Autos {
+		ref("Autos", i, t.DeducedTypeId)
+	}
+	for i, t := range tm.Attributeds {
+		ref("Attributeds", i, t.ModifiedTypeId)
+	}
+	for i, t := range tm.TemplateSpecializations {
+		ref("TemplateSpecializations", i, t.CanonicalTypeId)
+		refs("TemplateSpecializations", i, t.ArgTypeIds)
+	}
+	for i, t := range tm.ObjCs {
+		ref("ObjCs", i, t.BaseTypeId)
+		refs("ObjCs", i, t.TypeArgIds)
+	}
+
+	// Follow each chain of keys leading to keys once.
+	const (
+		unseen = iota
+		following
+		followed
+	)
+	state := make([]uint8, n)
+	for i := range tm.Keys {
+		var chain []int
+		j := i
+		for tm.leadsToKey(j) && state[j] == unseen {
+			state[j] = following
+			chain = append(chain, j)
+			j = tm.Keys[j].TypeId
+		}
+		if tm.leadsToKey(j) && state[j] == following {
+			e.Msg(fmt.Sprintf("type %d: leads back to itself", j))
+		}
+		for _, c := range chain {
+			state[c] = followed
+		}
+	}
+}
+
+// leadsToKey reports whether the key at index i has the index of another
+// key for its TypeId, in range.
+func (tm *TypeMap) leadsToKey(i int) bool {
+	switch tm.Keys[i].TypeKind {
+	case typekind.Pointer,
+		typekind.Elaborated,
+		typekind.LValueReference,
+		typekind.RValueReference,
+		typekind.BlockPointer,
+		typekind.Atomic,
+		typekind.ObjCObjectPointer:
+		id := tm.Keys[i].TypeId
+		return id >= 0 && id < len(tm.Keys)
+	}
+	return false
+}
+
+// checked returns the error e, or nil if it holds nothing.
+func (t *TuErr) checked() error {
+	if len(t.msgs) == 0 && len(t.elist) == 0 && len(t.cursorIds) == 0 && len(t.childIds) == 0 && t.errflags == 0 {
+		return nil
+	}
+	return t
+}
+
+// checkDecoded runs all the checks of what is decoded. The Children are
+// rebuilt from the parents when decoding, so checking them tells whether the
+// children of each cursor followed each other.
+func (tu *TranslationUnit) checkDecoded() error {
+	e := tu.Err()
+	tu.checkCursors(e)
+	tu.checkChildren(e)
+	tu.checkTokens(e)
+	tu.TypeMap.check(e)
+	tu.checkLocations(e)
+	tu.checkLinks(e)
+	return e.checked()
+}
+
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func sortedLocationKeys(m map[int]Location) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -64,8 +64,9 @@ func (t TuErr) Error() string {
 	if len(t.elist) > 0 {
 		fmt.Fprintf(b, " %v\n", t.elist)
 	}
-	if len(t.msgs) > 0 {
-		fmt.Fprintf(b, " %v\n", t.msgs)
+	// One message a line, as Validate may have many to tell.
+	for _, msg := range t.msgs {
+		fmt.Fprintf(b, " %s\n", msg)
 	}
 	if t.errflags&ChildrenCountErr != 0 {
 		fmt.Fprintf(b, " incorrect children count\n")
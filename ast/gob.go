@@ -2,6 +2,7 @@ package ast
 
 import (
 	"encoding/gob"
+	"fmt"
 	"io"
 
 	"github.com/frankreh/go-clang/clang/cursorkind"
@@ -92,9 +93,100 @@ func (tu *TranslationUnit) EncodeGobV1(w io.Writer) error {
 	if err := enc.Encode(tu.Definition); err != nil {
 		return err
 	}
+	if err := enc.Encode(tu.Files); err != nil {
+		return err
+	}
+	if err := encodeLocations(enc, tu.CursorLocs); err != nil {
+		return err
+	}
+	if err := enc.Encode(tu.CursorSpellings); err != nil {
+		return err
+	}
+	if err := encodeLocations(enc, tu.TokenLocs); err != nil {
+		return err
+	}
+	if err := enc.Encode(tu.TokenSpellings); err != nil {
+		return err
+	}
 	return nil
 }
 
+// encodeLocations encodes the locations as one list per field. The offsets,
+// which mostly grow from one location to the next, are encoded as the
+// difference with the previous one to keep them small.
+func encodeLocations(enc *gob.Encoder, locs []Location) error {
+	ints := make([]int, len(locs))
+
+	// FileId
+	for i := range ints {
+		ints[i] = locs[i].FileId
+	}
+	if err := enc.Encode(ints); err != nil {
+		return err
+	}
+	// Offset
+	prev := 0
+	for i := range ints {
+		ints[i] = locs[i].Offset - prev
+		prev = locs[i].Offset
+	}
+	if err := enc.Encode(ints); err != nil {
+		return err
+	}
+	// Line
+	for i := range ints {
+		ints[i] = locs[i].Line
+	}
+	if err := enc.Encode(ints); err != nil {
+		return err
+	}
+	// Column
+	for i := range ints {
+		ints[i] = locs[i].Column
+	}
+	return enc.Encode(ints)
+}
+
+// decodeLocations decodes the locations encoded by encodeLocations.
+func decodeLocations(dec *gob.Decoder) ([]Location, error) {
+	var ints []int
+
+	// FileId
+	if err := dec.Decode(&ints); err != nil {
+		return nil, err
+	}
+	locs := make([]Location, len(ints))
+	for i := range ints {
+		locs[i].FileId = ints[i]
+	}
+	// Offset, Line and Column
+	for field := 0; field < 3; field++ {
+		ints = nil
+		if err := dec.Decode(&ints); err != nil {
+			return nil, err
+		}
+		if len(ints) != len(locs) {
+			return nil, fmt.Errorf("location lists of lengths %d and %d", len(locs), len(ints))
+		}
+		prev := 0
+		for i := range ints {
+			switch field {
+			case 0:
+				locs[i].Offset = prev + ints[i]
+				prev = locs[i].Offset
+			case 1:
+				locs[i].Line = ints[i]
+			case 2:
+				locs[i].Column = ints[i]
+			}
+		}
+	}
+	return locs, nil
+}
+
+// DecodeGobV1 reads a translation unit written by EncodeGobV1 from r. What
+// the cursors, tokens, types and locations refer to is checked, and a *TuErr
+// returned that tells what is out of place.
 func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	dec := gob.NewDecoder(r) // Will read from r.
 
@@ -118,7 +210,7 @@ func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	for i := range ints {
 		kind, err := cursorkind.Validate(ints[i])
 		if err != nil {
-			return err
+			return tu.Err(fmt.Sprintf("cursor %d: kind %d: %v", i, ints[i], err))
 		}
 		tu.Cursors[i].CursorKindId = kind
 	}
@@ -128,6 +220,9 @@ func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	if err := dec.Decode(&ints); err != nil {
 		return err
 	}
+	if len(ints) != len(tu.Cursors) {
+		return tu.Err(fmt.Sprintf("CursorNameId of length %d for %d cursors", len(ints), len(tu.Cursors)))
+	}
 	for i := range ints {
 		tu.Cursors[i].CursorNameId = ints[i]
 	}
@@ -137,11 +232,14 @@ func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	if err := dec.Decode(&ints); err != nil {
 		return err
 	}
+	if len(ints) != len(tu.Cursors) {
+		return tu.Err(fmt.Sprintf("ParentIndex of length %d for %d cursors", len(ints), len(tu.Cursors)))
+	}
 	for i := range ints {
 		tu.Cursors[i].ParentIndex = ints[i]
 		p := ints[i]
-		if p < 0 {
-			continue // Root will have parent set to -1.
+		if p < 0 || p >= len(ints) {
+			continue // Root will have parent set to -1, checked below.
 		}
 		c := &tu.Cursors[p].Children
 		if c.Head == 0 {
@@ -155,6 +253,9 @@ func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	if err := dec.Decode(&ints); err != nil {
 		return err
 	}
+	if len(ints) != len(tu.Cursors) {
+		return tu.Err(fmt.Sprintf("TypeIndex of length %d for %d cursors", len(ints), len(tu.Cursors)))
+	}
 	for i := range ints {
 		tu.Cursors[i].TypeIndex = ints[i]
 	}
@@ -164,6 +265,9 @@ func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	if err := dec.Decode(&ints); err != nil {
 		return err
 	}
+	if len(ints) != len(tu.Cursors) {
+		return tu.Err(fmt.Sprintf("Tokens.Head of length %d for %d cursors", len(ints), len(tu.Cursors)))
+	}
 	for i := range ints {
 		tu.Cursors[i].Tokens.Head = ints[i]
 	}
@@ -173,6 +277,9 @@ func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	if err := dec.Decode(&ints); err != nil {
 		return err
 	}
+	if len(ints) != len(tu.Cursors) {
+		return tu.Err(fmt.Sprintf("Tokens.Len of length %d for %d cursors", len(ints), len(tu.Cursors)))
+	}
 	for i := range ints {
 		tu.Cursors[i].Tokens.Len = ints[i]
 	}
@@ -208,6 +315,28 @@ func (tu *TranslationUnit) DecodeGobV1(r io.Reader) error {
 	if err := dec.Decode(&tu.Definition); err != nil {
 		return err
 	}
+	// Streams encoded before locations were recorded end here, and decode
+	// without locations.
+	err := dec.Decode(&tu.Files)
+	if err == io.EOF {
+		tu.DecodeFinish()
+		return tu.checkDecoded()
+	}
+	if err != nil {
+		return err
+	}
+	if tu.CursorLocs, err = decodeLocations(dec); err != nil {
+		return err
+	}
+	if err := dec.Decode(&tu.CursorSpellings); err != nil {
+		return err
+	}
+	if tu.TokenLocs, err = decodeLocations(dec); err != nil {
+		return err
+	}
+	if err := dec.Decode(&tu.TokenSpellings); err != nil {
+		return err
+	}
 	tu.DecodeFinish()
-	return nil
+	return tu.checkDecoded()
 }
@@ -0,0 +1,156 @@
+package ast
+
+import "fmt"
+
+// Location is the pure Go version of a clang source location.
+//
+// FileId 0 stands for no file, as for the root cursor, so the zero Location
+// is the unknown location. Offset is in bytes from the start of the file,
+// Line and Column count from 1.
+type Location struct {
+	FileId int // Id into Files
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid returns true if the location is in a file.
+func (l Location) IsValid() bool {
+	return l.FileId != 0
+}
+
+// Locations of the cursors and tokens of a TranslationUnit.
+//
+// The expansion location, where a cursor or token ends up once macros are
+// expanded, is recorded for every cursor and token. The spelling location is
+// only recorded where it differs from the expansion location.
+//
+// The spelling location is the one libclang's clang_getSpellingLocation
+// gives, which is the file location: for a macro argument, where it is
+// written in the macro invocation, but for what comes out of the body of a
+// macro, the expansion location again, never the macro definition. The
+// tokens libclang gives are those of the file, not expanded, so populated
+// from libclang the TokenSpellings are empty.
+type Locations struct {
+	Files StringMap // File names, "" being id 0.
+
+	CursorLocs      []Location // Mirrors Cursors.
+	CursorSpellings map[int]Location
+	TokenLocs       []Location // Mirrors TokenIds.
+	TokenSpellings  map[int]Location
+}
+
+// CursorLocation returns the expansion location of the cursor.
+func (tu *TranslationUnit) CursorLocation(cursorId int) Location {
+	if cursorId < 0 || cursorId >= len(tu.CursorLocs) {
+		return Location{}
+	}
+	return tu.CursorLocs[cursorId]
+}
+
+// CursorSpellingLocation returns the spelling location of the cursor.
+func (tu *TranslationUnit) CursorSpellingLocation(cursorId int) Location {
+	if l, ok := tu.CursorSpellings[cursorId]; ok {
+		return l
+	}
+	return tu.CursorLocation(cursorId)
+}
+
+// TokenLocation returns the expansion location of the token at index i of
+// TokenIds.
+func (tu *TranslationUnit) TokenLocation(i int) Location {
+	if i < 0 || i >= len(tu.TokenLocs) {
+		return Location{}
+	}
+	return tu.TokenLocs[i]
+}
+
+// TokenSpellingLocation returns the spelling location of the token at index i
+// of TokenIds.
+func (tu *TranslationUnit) TokenSpellingLocation(i int) Location {
+	if l, ok := tu.TokenSpellings[i]; ok {
+		return l
+	}
+	return tu.TokenLocation(i)
+}
+
+// FileName returns the name of the file of the location, "" if it has none.
+func (tu *TranslationUnit) FileName(l Location) string {
+	if l.FileId <= 0 || l.FileId >= tu.Files.Len() {
+		return ""
+	}
+	return tu.Files.ToString(l.FileId)
+}
+
+// Position returns the location as file:line:column, the way compilers
+// report them.
+func (tu *TranslationUnit) Position(l Location) string {
+	if !l.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%s:%d:%d", tu.FileName(l), l.Line, l.Column)
+}
+
+// AddLocation returns the location for a file, offset, line and column,
+// adding the file to Files if needed.
+func (tu *TranslationUnit) AddLocation(file string, offset, line, column int) Location {
+	if tu.Files.Len() == 0 {
+		_ = tu.Files.Id("")
+	}
+	if file == "" {
+		return Location{}
+	}
+	return Location{
+		FileId: tu.Files.Id(file),
+		Offset: offset,
+		Line:   line,
+		Column: column,
+	}
+}
+
+func (a *Locations) AssertEqual(b *Locations) error {
+	if a == b {
+		return nil
+	}
+	if err := a.Files.AssertEqual(&b.Files); err != nil {
+		return err
+	}
+	if err := assertEqualLocationSlices("CursorLocs", a.CursorLocs, b.CursorLocs); err != nil {
+		return err
+	}
+	if err := assertEqualLocationMaps("CursorSpellings", a.CursorSpellings, b.CursorSpellings); err != nil {
+		return err
+	}
+	if err := assertEqualLocationSlices("TokenLocs", a.TokenLocs, b.TokenLocs); err != nil {
+		return err
+	}
+	if err := assertEqualLocationMaps("TokenSpellings", a.TokenSpellings, b.TokenSpellings); err != nil {
+		return err
+	}
+	return nil
+}
+
+func assertEqualLocationSlices(name string, a, b []Location) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("%s unequal slice lengths, %d %d", name, len(a), len(b))
+	}
+	for i, v := range a {
+		if v != b[i] {
+			return fmt.Errorf("%s unequal slice entry, %d %v %v", name, i, v, b[i])
+		}
+	}
+	return nil
+}
+
+func assertEqualLocationMaps(name string, a, b map[int]Location) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("%s map lengths a %d, b %d", name, len(a), len(b))
+	}
+	for k, v := range a {
+		bvalue, ok := b[k]
+		if !ok || bvalue != v {
+			return fmt.Errorf("%s map values for key %d, a %v, b %v", name, k, v, bvalue)
+		}
+	}
+	return nil
+}
@@ -4,14 +4,49 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/frankreh/go-clang/clang/nullability"
 	"github.com/frankreh/go-clang/clang/typekind"
 )
 
 // TypeKey keys a single index int to a kind of type and the
 // index into the appropriate slice.
 type TypeKey struct {
-	TypeKind typekind.Kind
-	TypeId   int // Index into relevant TypeMap slices.
+	TypeKind   typekind.Kind
+	TypeId     int // Index into relevant TypeMap slices.
+	Qualifiers Qualifiers
+}
+
+// String returns the key as {Kind TypeId}, followed by the qualifiers when
+// it has any.
+func (k TypeKey) String() string {
+	if k.Qualifiers == 0 {
+		return fmt.Sprintf("{%v %d}", k.TypeKind, k.TypeId)
+	}
+	return fmt.Sprintf("{%v %d %v}", k.TypeKind, k.TypeId, k.Qualifiers)
+}
+
+// Qualifiers are the const, volatile and restrict qualifiers of a type.
+type Qualifiers uint8
+
+const (
+	QualConst Qualifiers = 1 << iota
+	QualVolatile
+	QualRestrict
+)
+
+// String returns the qualifiers as C spells them, like "const volatile".
+func (q Qualifiers) String() string {
+	var r []string
+	if q&QualConst != 0 {
+		r = append(r, "const")
+	}
+	if q&QualVolatile != 0 {
+		r = append(r, "volatile")
+	}
+	if q&QualRestrict != 0 {
+		r = append(r, "restrict")
+	}
+	return strings.Join(r, " ")
 }
 
 type Type interface {
@@ -53,6 +88,29 @@ type TypeRecord struct {
 	Align        int // TBD unsigned byte or unsigned short?
 	Size         int
 	TypeSpelling string
+	Fields       []TypeField
+}
+
+func (a TypeRecord) Equal(b TypeRecord) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i := range a.Fields {
+		if a.Fields[i] != b.Fields[i] {
+			return false
+		}
+	}
+	return a.Align == b.Align &&
+		a.Size == b.Size &&
+		a.TypeSpelling == b.TypeSpelling
+}
+
+// TypeField is a field of a record.
+type TypeField struct {
+	Name     string
+	TypeId   int
+	Offset   int // In bits. -1 when the record cannot be laid out.
+	BitWidth int // -1 when the field is not a bit field.
 }
 
 func (p *TypeRecord) Kind() typekind.Kind {
@@ -78,17 +136,26 @@ func (p *TypeTypedef) Kind() typekind.Kind {
 	return typekind.Typedef
 }
 
+// TypeFunction is a FunctionProto or a FunctionNoProto. The names of the
+// parameters are not part of the type, declarations naming them differently
+// share it; they are those of the ParmDecl cursors of a declaration.
 type TypeFunction struct {
 	TypeKindKind
-	ResultTypeId int
-	ArgIds       []int // TBD this could be put into separate TypeMap category and indexed, to same some space for like signatures.
-	TypeSpelling string
+	ResultTypeId  int
+	ArgIds        []int // TBD this could be put into separate TypeMap category and indexed, to same some space for like signatures.
+	TypeSpelling  string
+	CallingConv   int // The clang.CallingConv.
+	Variadic      bool
+	ExceptionSpec int // The clang.ExceptionSpecification.
 }
 
 func (a TypeFunction) Equal(b TypeFunction) bool {
 	return a.TypeKindKind == b.TypeKindKind &&
 		a.ResultTypeId == b.ResultTypeId &&
 		a.TypeSpelling == b.TypeSpelling &&
+		a.CallingConv == b.CallingConv &&
+		a.Variadic == b.Variadic &&
+		a.ExceptionSpec == b.ExceptionSpec &&
 		equalIntSlices(a.ArgIds, b.ArgIds)
 }
 
@@ -112,6 +179,152 @@ func (p *TypeVariableArray) Kind() typekind.Kind {
 	return typekind.VariableArray
 }
 
+// TypeIncompleteArray is an array without a size, like int[].
+type TypeIncompleteArray struct {
+	TypeVariableArray
+}
+
+func (p *TypeIncompleteArray) Kind() typekind.Kind {
+	return typekind.IncompleteArray
+}
+
+// TypeDependentSizedArray is an array whose size depends on a template
+// parameter, like T[N].
+type TypeDependentSizedArray struct {
+	TypeVariableArray
+}
+
+func (p *TypeDependentSizedArray) Kind() typekind.Kind {
+	return typekind.DependentSizedArray
+}
+
+// TypeReference is an LValueReference or an RValueReference.
+type TypeReference struct {
+	TypeKindKind
+	UnderlyingTypeIndex int
+}
+
+type TypeBlockPointer struct {
+	UnderlyingTypeIndex int
+}
+
+func (p *TypeBlockPointer) Kind() typekind.Kind {
+	return typekind.BlockPointer
+}
+
+type TypeAtomic struct {
+	UnderlyingTypeIndex int // The value type.
+}
+
+func (p *TypeAtomic) Kind() typekind.Kind {
+	return typekind.Atomic
+}
+
+type TypeObjCObjectPointer struct {
+	UnderlyingTypeIndex int
+}
+
+func (p *TypeObjCObjectPointer) Kind() typekind.Kind {
+	return typekind.ObjCObjectPointer
+}
+
+type TypeMemberPointer struct {
+	PointeeTypeId int
+	ClassTypeId   int
+	Align         int
+	Size          int
+	TypeSpelling  string
+}
+
+func (p *TypeMemberPointer) Kind() typekind.Kind {
+	return typekind.MemberPointer
+}
+
+// TypeVector is a Vector, an ExtVector or a Complex. A Complex has an
+// ElemCount of 2, its real and imaginary parts.
+type TypeVector struct {
+	TypeKindKind
+	ElemTypeId   int
+	ElemCount    int
+	Align        int
+	Size         int
+	TypeSpelling string
+}
+
+type TypeAuto struct {
+	DeducedTypeId int // -1 while the type is not deduced.
+	Align         int
+	Size          int
+	TypeSpelling  string
+}
+
+func (p *TypeAuto) Kind() typekind.Kind {
+	return typekind.Auto
+}
+
+// TypeAttributed is a type modified by an attribute. The nullability
+// attributes, like _Nonnull, are recorded; others leave it Invalid.
+type TypeAttributed struct {
+	ModifiedTypeId int
+	Nullability    nullability.Kind
+	Align          int
+	Size           int
+	TypeSpelling   string
+}
+
+func (p *TypeAttributed) Kind() typekind.Kind {
+	return typekind.Attributed
+}
+
+// TypeTemplateSpecialization is a specialization of a class template, like
+// vector<int>. libclang has no kind for them, it reports them as Unexposed,
+// so their key is of the Unexposed kind but, unlike Keys[1], has an index.
+type TypeTemplateSpecialization struct {
+	CanonicalTypeId int   // -1 when the type is its own canonical type.
+	ArgTypeIds      []int // -1 for the arguments that are not types.
+	Align           int
+	Size            int
+	TypeSpelling    string
+}
+
+func (p *TypeTemplateSpecialization) Kind() typekind.Kind {
+	return typekind.Unexposed
+}
+
+func (a TypeTemplateSpecialization) Equal(b TypeTemplateSpecialization) bool {
+	return a.CanonicalTypeId == b.CanonicalTypeId &&
+		a.Align == b.Align &&
+		a.Size == b.Size &&
+		a.TypeSpelling == b.TypeSpelling &&
+		equalIntSlices(a.ArgTypeIds, b.ArgTypeIds)
+}
+
+// TypeObjC is an ObjCInterface, an ObjCObject or an ObjCTypeParam. Only an
+// ObjCObject, like NSArray<NSString *><NSCopying>, has a base type, type
+// arguments and protocols.
+type TypeObjC struct {
+	TypeKindKind
+	BaseTypeId   int // -1 but for an ObjCObject.
+	TypeArgIds   []int
+	Protocols    []string
+	TypeSpelling string
+}
+
+func (a TypeObjC) Equal(b TypeObjC) bool {
+	if len(a.Protocols) != len(b.Protocols) {
+		return false
+	}
+	for i := range a.Protocols {
+		if a.Protocols[i] != b.Protocols[i] {
+			return false
+		}
+	}
+	return a.TypeKindKind == b.TypeKindKind &&
+		a.BaseTypeId == b.BaseTypeId &&
+		a.TypeSpelling == b.TypeSpelling &&
+		equalIntSlices(a.TypeArgIds, b.TypeArgIds)
+}
+
 // AddIntrinsic adds TypeIntrinsic to its mapping and returns the new key index for it.
 // But returns an error if the underlying type index is already in the mapping.
 func (tm *TypeMap) AddIntrinsic(r TypeIntrinsic) (int, error) {
@@ -124,7 +337,8 @@ func (tm *TypeMap) AddIntrinsic(r TypeIntrinsic) (int, error) {
 	if r.Align == 0 {
 		// Allow a few type kinds to be coming in with an align of 0.
 		switch r.TypeKind {
-		case typekind.Void:
+		case typekind.Void,
+			typekind.Dependent:
 			break
 		default:
 			return -1, fmt.Errorf("TypeMap.AddIntrinsic.Align is 0")
@@ -134,7 +348,8 @@ func (tm *TypeMap) AddIntrinsic(r TypeIntrinsic) (int, error) {
 		// Allow a few type kinds to be coming in with a size of 0.
 		switch r.TypeKind {
 		case typekind.Void,
-			typekind.VariableArray:
+			typekind.VariableArray,
+			typekind.Dependent:
 			break
 		default:
 			return -1, fmt.Errorf("TypeMap.AddIntrinsic.Size is 0")
@@ -216,7 +431,7 @@ func (tm *TypeMap) AddElaborated(r TypeElaborated) (int, error) {
 func (tm *TypeMap) AddRecord(r TypeRecord) (int, error) {
 	l := &tm.Records
 	for i, e := range *l {
-		if r == e {
+		if r.Equal(e) {
 			panic(fmt.Sprintf("Entry already exists at %d", i))
 		}
 	}
@@ -224,6 +439,29 @@ func (tm *TypeMap) AddRecord(r TypeRecord) (int, error) {
 	return tm.addToKeys(typekind.Record, len(*l)-1), nil
 }
 
+// SetFields sets the fields of the record at key index i. The fields of a
+// record can be set once the record is added, so that fields whose types
+// lead back to the record, like the next field of a list, can be indexed.
+func (tm *TypeMap) SetFields(i int, fields []TypeField) error {
+	if err := indexCheck(i, len(tm.Keys), "Keys"); err != nil {
+		return fmt.Errorf("TypeMap.SetFields: %s", err)
+	}
+	if k := tm.Keys[i]; k.TypeKind != typekind.Record {
+		return fmt.Errorf("TypeMap.SetFields: typekind %[1]s:%[1]d is not a record", k.TypeKind)
+	}
+	for _, f := range fields {
+		if err := indexCheck(f.TypeId, len(tm.Keys), "Keys"); err != nil {
+			return fmt.Errorf("TypeMap.SetFields: field %s: %s", f.Name, err)
+		}
+	}
+	li := tm.Keys[i].TypeId
+	if err := indexCheck(li, len(tm.Records), "Records"); err != nil {
+		return fmt.Errorf("TypeMap.SetFields: %s", err)
+	}
+	tm.Records[li].Fields = fields
+	return nil
+}
+
 // AddEnum adds TypeEnum to its mapping and returns the new key index for it.
 func (tm *TypeMap) AddEnum(r TypeEnum) (int, error) {
 	l := &tm.Enums
@@ -290,6 +528,158 @@ func (tm *TypeMap) AddVariableArray(v TypeVariableArray) (int, error) {
 	return tm.addToKeys(typekind.VariableArray, len(*l)-1), nil
 }
 
+// AddIncompleteArray adds TypeIncompleteArray to its mapping and returns the new key index for it.
+// Like TypeVariableArray, it is stored with an ElemCount of -1 along with TypeConstantArray structs.
+func (tm *TypeMap) AddIncompleteArray(v TypeIncompleteArray) (int, error) {
+	return tm.addSizelessArray(typekind.IncompleteArray, v.TypeVariableArray)
+}
+
+// AddDependentSizedArray adds TypeDependentSizedArray to its mapping and returns the new key index for it.
+// Like TypeVariableArray, it is stored with an ElemCount of -1 along with TypeConstantArray structs.
+func (tm *TypeMap) AddDependentSizedArray(v TypeDependentSizedArray) (int, error) {
+	return tm.addSizelessArray(typekind.DependentSizedArray, v.TypeVariableArray)
+}
+
+func (tm *TypeMap) addSizelessArray(kind typekind.Kind, v TypeVariableArray) (int, error) {
+	r := TypeConstantArray{
+		ElemCount:         -1,
+		TypeVariableArray: v,
+	}
+	l := &tm.Arrays
+	for i, e := range *l {
+		if r == e {
+			panic(fmt.Sprintf("Entry already exists at %d", i))
+		}
+	}
+	*l = append(*l, r)
+	return tm.addToKeys(kind, len(*l)-1), nil
+}
+
+// AddReference adds TypeReference to its mapping and returns the new key index for it.
+// Like pointers, the referenced type index is stored directly in the key.
+func (tm *TypeMap) AddReference(r TypeReference) (int, error) {
+	if r.TypeKind != typekind.LValueReference && r.TypeKind != typekind.RValueReference {
+		return -1, fmt.Errorf("TypeMap.AddReference: typekind %[1]s:%[1]d is not a reference", r.TypeKind)
+	}
+	if err := indexCheck(r.UnderlyingTypeIndex, len(tm.Keys), "Keys"); err != nil {
+		return -1, fmt.Errorf("TypeMap.AddReference: %s", err)
+	}
+	return tm.addToKeys(r.TypeKind, r.UnderlyingTypeIndex), nil
+}
+
+// AddBlockPointer adds TypeBlockPointer to its mapping and returns the new key index for it.
+// The pointee type index is stored directly in the key.
+func (tm *TypeMap) AddBlockPointer(r TypeBlockPointer) (int, error) {
+	if err := indexCheck(r.UnderlyingTypeIndex, len(tm.Keys), "Keys"); err != nil {
+		return -1, fmt.Errorf("TypeMap.AddBlockPointer: %s", err)
+	}
+	return tm.addToKeys(typekind.BlockPointer, r.UnderlyingTypeIndex), nil
+}
+
+// AddAtomic adds TypeAtomic to its mapping and returns the new key index for it.
+// The value type index is stored directly in the key.
+func (tm *TypeMap) AddAtomic(r TypeAtomic) (int, error) {
+	if err := indexCheck(r.UnderlyingTypeIndex, len(tm.Keys), "Keys"); err != nil {
+		return -1, fmt.Errorf("TypeMap.AddAtomic: %s", err)
+	}
+	return tm.addToKeys(typekind.Atomic, r.UnderlyingTypeIndex), nil
+}
+
+// AddObjCObjectPointer adds TypeObjCObjectPointer to its mapping and returns the new key index for it.
+// The pointee type index is stored directly in the key.
+func (tm *TypeMap) AddObjCObjectPointer(r TypeObjCObjectPointer) (int, error) {
+	if err := indexCheck(r.UnderlyingTypeIndex, len(tm.Keys), "Keys"); err != nil {
+		return -1, fmt.Errorf("TypeMap.AddObjCObjectPointer: %s", err)
+	}
+	return tm.addToKeys(typekind.ObjCObjectPointer, r.UnderlyingTypeIndex), nil
+}
+
+// AddMemberPointer adds TypeMemberPointer to its mapping and returns the new key index for it.
+func (tm *TypeMap) AddMemberPointer(r TypeMemberPointer) (int, error) {
+	l := &tm.MemberPointers
+	for i, e := range *l {
+		if r == e {
+			panic(fmt.Sprintf("Entry already exists at %d", i))
+		}
+	}
+	*l = append(*l, r)
+	return tm.addToKeys(typekind.MemberPointer, len(*l)-1), nil
+}
+
+// AddVector adds TypeVector to its mapping and returns the new key index for it.
+func (tm *TypeMap) AddVector(r TypeVector) (int, error) {
+	switch r.TypeKind {
+	case typekind.Vector,
+		typekind.ExtVector,
+		typekind.Complex:
+	default:
+		return -1, fmt.Errorf("TypeMap.AddVector: typekind %[1]s:%[1]d is not a vector", r.TypeKind)
+	}
+	l := &tm.Vectors
+	for i, e := range *l {
+		if r == e {
+			panic(fmt.Sprintf("Entry already exists at %d", i))
+		}
+	}
+	*l = append(*l, r)
+	return tm.addToKeys(r.TypeKind, len(*l)-1), nil
+}
+
+// AddAuto adds TypeAuto to its mapping and returns the new key index for it.
+func (tm *TypeMap) AddAuto(r TypeAuto) (int, error) {
+	l := &tm.Autos
+	for i, e := range *l {
+		if r == e {
+			panic(fmt.Sprintf("Entry already exists at %d", i))
+		}
+	}
+	*l = append(*l, r)
+	return tm.addToKeys(typekind.Auto, len(*l)-1), nil
+}
+
+// AddAttributed adds TypeAttributed to its mapping and returns the new key index for it.
+func (tm *TypeMap) AddAttributed(r TypeAttributed) (int, error) {
+	l := &tm.Attributeds
+	for i, e := range *l {
+		if r == e {
+			panic(fmt.Sprintf("Entry already exists at %d", i))
+		}
+	}
+	*l = append(*l, r)
+	return tm.addToKeys(typekind.Attributed, len(*l)-1), nil
+}
+
+// AddTemplateSpecialization adds TypeTemplateSpecialization to its mapping and returns the new key index for it.
+func (tm *TypeMap) AddTemplateSpecialization(r TypeTemplateSpecialization) (int, error) {
+	l := &tm.TemplateSpecializations
+	for i, e := range *l {
+		if r.Equal(e) {
+			panic(fmt.Sprintf("Entry already exists at %d", i))
+		}
+	}
+	*l = append(*l, r)
+	return tm.addToKeys(typekind.Unexposed, len(*l)-1), nil
+}
+
+// AddObjC adds TypeObjC to its mapping and returns the new key index for it.
+func (tm *TypeMap) AddObjC(r TypeObjC) (int, error) {
+	switch r.TypeKind {
+	case typekind.ObjCInterface,
+		typekind.ObjCObject,
+		typekind.ObjCTypeParam:
+	default:
+		return -1, fmt.Errorf("TypeMap.AddObjC: typekind %[1]s:%[1]d is not an ObjC object", r.TypeKind)
+	}
+	l := &tm.ObjCs
+	for i, e := range *l {
+		if r.Equal(e) {
+			panic(fmt.Sprintf("Entry already exists at %d", i))
+		}
+	}
+	*l = append(*l, r)
+	return tm.addToKeys(r.TypeKind, len(*l)-1), nil
+}
+
 // TypeMap maps a Cursor.TypeIndex (an int) to a Type.
 type TypeMap struct {
 	Keys       []TypeKey
@@ -300,7 +690,14 @@ type TypeMap struct {
 	Enums     []TypeEnum
 	Typedefs  []TypeTypedef
 	Functions []TypeFunction
-	Arrays    []TypeConstantArray
+	Arrays    []TypeConstantArray // Also the VariableArray, IncompleteArray and DependentSizedArray types.
+
+	MemberPointers          []TypeMemberPointer
+	Vectors                 []TypeVector
+	Autos                   []TypeAuto
+	Attributeds             []TypeAttributed
+	TemplateSpecializations []TypeTemplateSpecialization
+	ObjCs                   []TypeObjC
 }
 
 // Init ensures the struct is setup properly before first use.
@@ -312,17 +709,37 @@ func (tm *TypeMap) Init() {
 	// [0] representing typekind.Invalid and
 	// [1] representing typekind.Unexposed.
 	// Use index of -1 to catch incorrect use of these keys with any mapping slice.
-	tm.Keys = append(tm.Keys, TypeKey{typekind.Invalid, -1})
-	tm.Keys = append(tm.Keys, TypeKey{typekind.Unexposed, -1})
+	tm.Keys = append(tm.Keys, TypeKey{TypeKind: typekind.Invalid, TypeId: -1})
+	tm.Keys = append(tm.Keys, TypeKey{TypeKind: typekind.Unexposed, TypeId: -1})
 }
 
 // addToKeys adds a TypeKey record to the slice for the given type kind and corresponding index.
 // Returns the index of the record that was added.
 func (tm *TypeMap) addToKeys(kind typekind.Kind, index int) int {
-	tm.Keys = append(tm.Keys, TypeKey{kind, index})
+	tm.Keys = append(tm.Keys, TypeKey{TypeKind: kind, TypeId: index})
 	return len(tm.Keys) - 1
 }
 
+// Qualify sets the qualifiers of the type at key index i.
+func (tm *TypeMap) Qualify(i int, q Qualifiers) error {
+	if err := indexCheck(i, len(tm.Keys), "Keys"); err != nil {
+		return fmt.Errorf("TypeMap.Qualify: %s", err)
+	}
+	if i <= 1 {
+		return fmt.Errorf("TypeMap.Qualify: the shared key %d cannot be qualified", i)
+	}
+	tm.Keys[i].Qualifiers = q
+	return nil
+}
+
+// Qualifiers returns the qualifiers of the type at key index i.
+func (tm *TypeMap) Qualifiers(i int) Qualifiers {
+	if i < 0 || i >= len(tm.Keys) {
+		return 0
+	}
+	return tm.Keys[i].Qualifiers
+}
+
 // AutoKeyIndex returns a key index that is 0 or greater if the typekind maps
 // directly to a key index. But returns -1 if a specific Add method needs to be
 // used for the typekind in question.
@@ -364,6 +781,13 @@ func (tm *TypeMap) Type(i int) (Type, error) {
 	case typekind.Invalid:
 		return nil, nil
 	case typekind.Unexposed:
+		if li >= 0 {
+			l := tm.TemplateSpecializations
+			if err := indexCheck(li, len(l), "TemplateSpecializations"); err != nil {
+				return nil, err
+			}
+			return &l[li], nil
+		}
 		// libclang may not expose the type. But this package or the astbridge package may not expose it either.
 		return nil, nil
 	case typekind.Pointer:
@@ -431,10 +855,152 @@ func (tm *TypeMap) Type(i int) (Type, error) {
 			return nil, err
 		}
 		return &l[li].TypeVariableArray, nil
+	case typekind.IncompleteArray:
+		l := tm.Arrays
+		if err := indexCheck(li, len(l), "Arrays"); err != nil {
+			return nil, err
+		}
+		return &TypeIncompleteArray{l[li].TypeVariableArray}, nil
+	case typekind.DependentSizedArray:
+		l := tm.Arrays
+		if err := indexCheck(li, len(l), "Arrays"); err != nil {
+			return nil, err
+		}
+		return &TypeDependentSizedArray{l[li].TypeVariableArray}, nil
+	case typekind.LValueReference,
+		typekind.RValueReference,
+		typekind.BlockPointer,
+		typekind.Atomic,
+		typekind.ObjCObjectPointer:
+		l := tm.Keys
+		if err := indexCheck(li, len(l), "Keys"); err != nil {
+			return nil, err
+		}
+		// Create the instance on the fly.
+		switch tkind {
+		case typekind.BlockPointer:
+			return &TypeBlockPointer{li}, nil
+		case typekind.Atomic:
+			return &TypeAtomic{li}, nil
+		case typekind.ObjCObjectPointer:
+			return &TypeObjCObjectPointer{li}, nil
+		}
+		return &TypeReference{TypeKindKind{tkind}, li}, nil
+	case typekind.MemberPointer:
+		l := tm.MemberPointers
+		if err := indexCheck(li, len(l), "MemberPointers"); err != nil {
+			return nil, err
+		}
+		return &l[li], nil
+	case typekind.Vector,
+		typekind.ExtVector,
+		typekind.Complex:
+		l := tm.Vectors
+		if err := indexCheck(li, len(l), "Vectors"); err != nil {
+			return nil, err
+		}
+		return &l[li], nil
+	case typekind.Auto:
+		l := tm.Autos
+		if err := indexCheck(li, len(l), "Autos"); err != nil {
+			return nil, err
+		}
+		return &l[li], nil
+	case typekind.Attributed:
+		l := tm.Attributeds
+		if err := indexCheck(li, len(l), "Attributeds"); err != nil {
+			return nil, err
+		}
+		return &l[li], nil
+	case typekind.ObjCInterface,
+		typekind.ObjCObject,
+		typekind.ObjCTypeParam:
+		l := tm.ObjCs
+		if err := indexCheck(li, len(l), "ObjCs"); err != nil {
+			return nil, err
+		}
+		return &l[li], nil
 	}
-	errmsg := fmt.Sprintf("Type(i=%d) %[2]s:%[2]d", i, tkind)
-	panic("TypeMap.Type type not yet handled: " + errmsg)
-	return nil, nil
+	return nil, fmt.Errorf("TypeMap.Type(%d) kind not handled: %[2]s:%[2]d", i, tkind)
+}
+
+// Spelling returns the spelling of the type at index i, "" if it has none.
+// Pointers and elaborated types are not spelled by the TypeMap; their
+// spelling is made from the type they lead to and their qualifiers.
+func (tm *TypeMap) Spelling(i int) string {
+	t, err := tm.Type(i)
+	if err != nil || t == nil {
+		return ""
+	}
+	q := tm.Keys[i].Qualifiers.String()
+	prefix := func(s string) string {
+		if q == "" {
+			return s
+		}
+		return q + " " + s
+	}
+	switch t := t.(type) {
+	case *TypeIntrinsic:
+		return t.TypeSpelling
+	case *TypePointer:
+		s := tm.Spelling(t.UnderlyingTypeIndex)
+		if k := tm.Keys[t.UnderlyingTypeIndex].TypeKind; k == typekind.FunctionProto || k == typekind.FunctionNoProto {
+			if paren := strings.Index(s, "("); paren >= 0 {
+				return s[:paren] + "(*" + q + ")" + s[paren:]
+			}
+		}
+		if strings.HasSuffix(s, "*") {
+			return s + "*" + q
+		}
+		return s + " *" + q
+	case *TypeElaborated:
+		return prefix(tm.Spelling(t.UnderlyingTypeIndex))
+	case *TypeRecord:
+		return t.TypeSpelling
+	case *TypeEnum:
+		return t.TypeSpelling
+	case *TypeTypedef:
+		return t.TypeSpelling
+	case *TypeFunction:
+		return t.TypeSpelling
+	case *TypeConstantArray:
+		return t.TypeSpelling
+	case *TypeVariableArray:
+		return t.TypeSpelling
+	case *TypeIncompleteArray:
+		return t.TypeSpelling
+	case *TypeDependentSizedArray:
+		return t.TypeSpelling
+	case *TypeReference:
+		s := tm.Spelling(t.UnderlyingTypeIndex)
+		if t.TypeKind == typekind.RValueReference {
+			return s + " &&"
+		}
+		return s + " &"
+	case *TypeBlockPointer:
+		s := tm.Spelling(t.UnderlyingTypeIndex)
+		if paren := strings.Index(s, "("); paren >= 0 {
+			return s[:paren] + "(^" + q + ")" + s[paren:]
+		}
+		return s + " ^" + q
+	case *TypeAtomic:
+		return prefix("_Atomic(" + tm.Spelling(t.UnderlyingTypeIndex) + ")")
+	case *TypeObjCObjectPointer:
+		return tm.Spelling(t.UnderlyingTypeIndex) + " *" + q
+	case *TypeMemberPointer:
+		return t.TypeSpelling
+	case *TypeVector:
+		return t.TypeSpelling
+	case *TypeAuto:
+		return t.TypeSpelling
+	case *TypeAttributed:
+		return t.TypeSpelling
+	case *TypeTemplateSpecialization:
+		return t.TypeSpelling
+	case *TypeObjC:
+		return t.TypeSpelling
+	}
+	return ""
 }
 
 func (tm TypeMap) GoString() string {
@@ -469,6 +1035,24 @@ func (tm TypeMap) GoString() string {
 	if len(tm.Arrays) > 0 {
 		fmt.Fprintf(b, "    Arrays: %v\n", tm.Arrays)
 	}
+	if len(tm.MemberPointers) > 0 {
+		fmt.Fprintf(b, "    MemberPointers: %v\n", tm.MemberPointers)
+	}
+	if len(tm.Vectors) > 0 {
+		fmt.Fprintf(b, "    Vectors: %v\n", tm.Vectors)
+	}
+	if len(tm.Autos) > 0 {
+		fmt.Fprintf(b, "    Autos: %v\n", tm.Autos)
+	}
+	if len(tm.Attributeds) > 0 {
+		fmt.Fprintf(b, "    Attributeds: %v\n", tm.Attributeds)
+	}
+	if len(tm.TemplateSpecializations) > 0 {
+		fmt.Fprintf(b, "    TemplateSpecializations: %v\n", tm.TemplateSpecializations)
+	}
+	if len(tm.ObjCs) > 0 {
+		fmt.Fprintf(b, "    ObjCs: %v\n", tm.ObjCs)
+	}
 	fmt.Fprintf(b, "}")
 
 	return b.String()
@@ -512,6 +1096,24 @@ func (a *TypeMap) AssertEqual(b *TypeMap) error {
 	if err := a.assertEqualConstantArrays(b); err != nil {
 		return err
 	}
+	if err := a.assertEqualMemberPointers(b); err != nil {
+		return err
+	}
+	if err := a.assertEqualVectors(b); err != nil {
+		return err
+	}
+	if err := a.assertEqualAutos(b); err != nil {
+		return err
+	}
+	if err := a.assertEqualAttributeds(b); err != nil {
+		return err
+	}
+	if err := a.assertEqualTemplateSpecializations(b); err != nil {
+		return err
+	}
+	if err := a.assertEqualObjCs(b); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -583,7 +1185,7 @@ func (a *TypeMap) assertEqualRecords(b *TypeMap) error {
 	}
 	for i, v := range a.Records {
 		v2 := b.Records[i]
-		if v != v2 {
+		if !v.Equal(v2) {
 			return fmt.Errorf("TypeMap unequal Records entry, %d %#v %#v",
 				i, v, v2)
 		}
@@ -651,6 +1253,96 @@ func (a *TypeMap) assertEqualConstantArrays(b *TypeMap) error {
 	return nil
 }
 
+func (a *TypeMap) assertEqualMemberPointers(b *TypeMap) error {
+	if len(a.MemberPointers) != len(b.MemberPointers) {
+		return fmt.Errorf("TypeMap unequal MemberPointers lengths, %d %d",
+			len(a.MemberPointers), len(b.MemberPointers))
+	}
+	for i, v := range a.MemberPointers {
+		v2 := b.MemberPointers[i]
+		if v != v2 {
+			return fmt.Errorf("TypeMap unequal MemberPointers entry, %d %#v %#v",
+				i, v, v2)
+		}
+	}
+	return nil
+}
+
+func (a *TypeMap) assertEqualVectors(b *TypeMap) error {
+	if len(a.Vectors) != len(b.Vectors) {
+		return fmt.Errorf("TypeMap unequal Vectors lengths, %d %d",
+			len(a.Vectors), len(b.Vectors))
+	}
+	for i, v := range a.Vectors {
+		v2 := b.Vectors[i]
+		if v != v2 {
+			return fmt.Errorf("TypeMap unequal Vectors entry, %d %#v %#v",
+				i, v, v2)
+		}
+	}
+	return nil
+}
+
+func (a *TypeMap) assertEqualAutos(b *TypeMap) error {
+	if len(a.Autos) != len(b.Autos) {
+		return fmt.Errorf("TypeMap unequal Autos lengths, %d %d",
+			len(a.Autos), len(b.Autos))
+	}
+	for i, v := range a.Autos {
+		v2 := b.Autos[i]
+		if v != v2 {
+			return fmt.Errorf("TypeMap unequal Autos entry, %d %#v %#v",
+				i, v, v2)
+		}
+	}
+	return nil
+}
+
+func (a *TypeMap) assertEqualAttributeds(b *TypeMap) error {
+	if len(a.Attributeds) != len(b.Attributeds) {
+		return fmt.Errorf("TypeMap unequal Attributeds lengths, %d %d",
+			len(a.Attributeds), len(b.Attributeds))
+	}
+	for i, v := range a.Attributeds {
+		v2 := b.Attributeds[i]
+		if v != v2 {
+			return fmt.Errorf("TypeMap unequal Attributeds entry, %d %#v %#v",
+				i, v, v2)
+		}
+	}
+	return nil
+}
+
+func (a *TypeMap) assertEqualTemplateSpecializations(b *TypeMap) error {
+	if len(a.TemplateSpecializations) != len(b.TemplateSpecializations) {
+		return fmt.Errorf("TypeMap unequal TemplateSpecializations lengths, %d %d",
+			len(a.TemplateSpecializations), len(b.TemplateSpecializations))
+	}
+	for i, v := range a.TemplateSpecializations {
+		v2 := b.TemplateSpecializations[i]
+		if !v.Equal(v2) {
+			return fmt.Errorf("TypeMap unequal TemplateSpecializations entry, %d %#v %#v",
+				i, v, v2)
+		}
+	}
+	return nil
+}
+
+func (a *TypeMap) assertEqualObjCs(b *TypeMap) error {
+	if len(a.ObjCs) != len(b.ObjCs) {
+		return fmt.Errorf("TypeMap unequal ObjCs lengths, %d %d",
+			len(a.ObjCs), len(b.ObjCs))
+	}
+	for i, v := range a.ObjCs {
+		v2 := b.ObjCs[i]
+		if !v.Equal(v2) {
+			return fmt.Errorf("TypeMap unequal ObjCs entry, %d %#v %#v",
+				i, v, v2)
+		}
+	}
+	return nil
+}
+
 // TBD kind of got to here.
 /*
 // Does this struct even need a map? The astbridge code will keep its own
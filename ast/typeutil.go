@@ -0,0 +1,263 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+// Desugar returns the index of the type the type at index i stands for, one
+// step removed: the type a typedef names, the type an elaborated or
+// attributed type qualifies, the deduced type of an auto or the canonical
+// type of a template specialization. It returns -1 when the type stands for
+// no other.
+func (tm *TypeMap) Desugar(i int) int {
+	if i < 0 || i >= len(tm.Keys) {
+		return -1
+	}
+	t, err := tm.Type(i)
+	if err != nil {
+		return -1
+	}
+	u := -1
+	switch t := t.(type) {
+	case *TypeTypedef:
+		u = t.UnderlyingTypeIndex
+	case *TypeElaborated:
+		u = t.UnderlyingTypeIndex
+	case *TypeAttributed:
+		u = t.ModifiedTypeId
+	case *TypeAuto:
+		u = t.DeducedTypeId
+	case *TypeTemplateSpecialization:
+		u = t.CanonicalTypeId
+	}
+	if u < 0 || u >= len(tm.Keys) || u == i {
+		return -1
+	}
+	return u
+}
+
+// Canonical returns the index of the canonical type of the type at index i,
+// what Desugar leads to until it leads nowhere. The qualifiers of the types
+// desugared are dropped; Strip returns them.
+func (tm *TypeMap) Canonical(i int) int {
+	c, _ := tm.Strip(i)
+	return c
+}
+
+// Strip returns the index of the canonical type of the type at index i,
+// along with the qualifiers of the types met on the way to it, its own
+// included: for a const size_t, the index of unsigned long and QualConst.
+func (tm *TypeMap) Strip(i int) (int, Qualifiers) {
+	var q Qualifiers
+	// Bound the steps, should the map lead around in circles.
+	for n := 0; n <= len(tm.Keys); n++ {
+		q |= tm.Qualifiers(i)
+		u := tm.Desugar(i)
+		if u < 0 {
+			break
+		}
+		i = u
+	}
+	return i, q
+}
+
+// Pointee returns the index of the type pointed to by the canonical type of
+// the type at index i, when it is a Pointer, a BlockPointer or an
+// ObjCObjectPointer, and -1 otherwise.
+func (tm *TypeMap) Pointee(i int) int {
+	c := tm.Canonical(i)
+	if c < 0 || c >= len(tm.Keys) {
+		return -1
+	}
+	switch k := tm.Keys[c]; k.TypeKind {
+	case typekind.Pointer,
+		typekind.BlockPointer,
+		typekind.ObjCObjectPointer:
+		if k.TypeId >= 0 && k.TypeId < len(tm.Keys) {
+			return k.TypeId
+		}
+	}
+	return -1
+}
+
+// PointerDepth returns the number of pointers to go through from the type
+// at index i to a type that is not a pointer, with the typedefs seen
+// through: 2 for char **.
+func (tm *TypeMap) PointerDepth(i int) int {
+	depth := 0
+	for p := tm.Pointee(i); p >= 0 && depth < len(tm.Keys); p = tm.Pointee(p) {
+		depth++
+	}
+	return depth
+}
+
+// canonicalKind returns the kind of the canonical type of the type at
+// index i, Invalid if i is out of range.
+func (tm *TypeMap) canonicalKind(i int) typekind.Kind {
+	c := tm.Canonical(i)
+	if c < 0 || c >= len(tm.Keys) {
+		return typekind.Invalid
+	}
+	return tm.Keys[c].TypeKind
+}
+
+// IsInteger reports whether the canonical type of the type at index i is an
+// integer, a bool, a character or an enum, as C counts them.
+func (tm *TypeMap) IsInteger(i int) bool {
+	switch k := tm.canonicalKind(i); {
+	case k >= typekind.Bool && k <= typekind.Int128:
+		return true
+	case k == typekind.Enum:
+		return true
+	}
+	return false
+}
+
+// IsFloat reports whether the canonical type of the type at index i is a
+// floating point type.
+func (tm *TypeMap) IsFloat(i int) bool {
+	switch tm.canonicalKind(i) {
+	case typekind.Float,
+		typekind.Double,
+		typekind.LongDouble,
+		typekind.Float128,
+		typekind.Half,
+		typekind.Float16,
+		typekind.BFloat16:
+		return true
+	}
+	return false
+}
+
+// IsPointer reports whether the canonical type of the type at index i is a
+// pointer, including block, ObjC object and member pointers.
+func (tm *TypeMap) IsPointer(i int) bool {
+	switch tm.canonicalKind(i) {
+	case typekind.Pointer,
+		typekind.BlockPointer,
+		typekind.ObjCObjectPointer,
+		typekind.MemberPointer:
+		return true
+	}
+	return false
+}
+
+// IsRecord reports whether the canonical type of the type at index i is a
+// struct, a union or a class.
+func (tm *TypeMap) IsRecord(i int) bool {
+	return tm.canonicalKind(i) == typekind.Record
+}
+
+// Declarator returns the C declaration of name with the type at index i,
+// like "int (*fp)(char *)". The name can be empty, for the spelling of an
+// abstract declarator, like that of a parameter type: "char *". Typedefs and
+// named types are spelled by name, not desugared.
+func (tm *TypeMap) Declarator(i int, name string) string {
+	return tm.declarator(i, name, 0)
+}
+
+// declarator returns the declaration of inner, the part of the declarator
+// already built, with the type at index i.
+func (tm *TypeMap) declarator(i int, inner string, depth int) string {
+	t, err := tm.Type(i)
+	if err != nil || t == nil || depth > len(tm.Keys) {
+		return join(tm.Spelling(i), inner)
+	}
+	q := tm.Qualifiers(i).String()
+	switch t := t.(type) {
+	case *TypePointer:
+		return tm.declarator(t.UnderlyingTypeIndex, tm.pointerInner(t.UnderlyingTypeIndex, "*", q, inner), depth+1)
+	case *TypeBlockPointer:
+		return tm.declarator(t.UnderlyingTypeIndex, tm.pointerInner(t.UnderlyingTypeIndex, "^", q, inner), depth+1)
+	case *TypeReference:
+		op := "&"
+		if t.TypeKind == typekind.RValueReference {
+			op = "&&"
+		}
+		return tm.declarator(t.UnderlyingTypeIndex, tm.pointerInner(t.UnderlyingTypeIndex, op, "", inner), depth+1)
+	case *TypeMemberPointer:
+		op := tm.Spelling(t.ClassTypeId) + "::*"
+		return tm.declarator(t.PointeeTypeId, tm.pointerInner(t.PointeeTypeId, op, q, inner), depth+1)
+	case *TypeConstantArray:
+		return tm.declarator(t.ElemTypeId, inner+"["+strconv.Itoa(t.ElemCount)+"]", depth+1)
+	case *TypeVariableArray:
+		return tm.declarator(t.ElemTypeId, inner+arrayBounds(t.TypeSpelling), depth+1)
+	case *TypeIncompleteArray:
+		return tm.declarator(t.ElemTypeId, inner+"[]", depth+1)
+	case *TypeDependentSizedArray:
+		return tm.declarator(t.ElemTypeId, inner+arrayBounds(t.TypeSpelling), depth+1)
+	case *TypeFunction:
+		var args []string
+		for _, a := range t.ArgIds {
+			args = append(args, tm.declarator(a, "", depth+1))
+		}
+		if t.Variadic {
+			args = append(args, "...")
+		}
+		if len(args) == 0 && t.TypeKind == typekind.FunctionProto {
+			args = append(args, "void")
+		}
+		return tm.declarator(t.ResultTypeId, inner+"("+strings.Join(args, ", ")+")", depth+1)
+	}
+	return join(tm.Spelling(i), inner)
+}
+
+// pointerInner returns inner behind the pointer operator op and its
+// qualifiers, in parentheses when the type pointed to is a function or an
+// array, whose suffix would otherwise bind first.
+func (tm *TypeMap) pointerInner(pointee int, op, qualifiers, inner string) string {
+	s := op + qualifiers
+	if qualifiers != "" && inner != "" {
+		s += " "
+	}
+	s += inner
+	if pointee >= 0 && pointee < len(tm.Keys) {
+		switch tm.Keys[pointee].TypeKind {
+		case typekind.FunctionProto,
+			typekind.FunctionNoProto,
+			typekind.ConstantArray,
+			typekind.VariableArray,
+			typekind.IncompleteArray,
+			typekind.DependentSizedArray:
+			return "(" + s + ")"
+		}
+	}
+	return s
+}
+
+// arrayBounds returns the first bounds of the spelling of an array type,
+// "[n]" for "int [n][4]", "[]" if it has none.
+func arrayBounds(spelling string) string {
+	start := strings.IndexByte(spelling, '[')
+	if start < 0 {
+		return "[]"
+	}
+	depth := 0
+	for i := start; i < len(spelling); i++ {
+		switch spelling[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return spelling[start : i+1]
+			}
+		}
+	}
+	return "[]"
+}
+
+// join joins the spelling of a type to a declarator, with a space between
+// them when neither is empty.
+func join(spelling, inner string) string {
+	if inner == "" {
+		return spelling
+	}
+	if spelling == "" {
+		return inner
+	}
+	return spelling + " " + inner
+}
@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Validate checks the translation unit holds together the way this package
+// relies on: the kinds and names of the cursors, that the children of each
+// cursor follow each other and have it for their parent, that the tokens of
+// the cursors are within the TokenIds, the ids into the TokenMap and the
+// StringMaps, the indexes into the TypeMap, and the cursors the Back,
+// Referenced and Definition maps lead from and to. Every problem found is
+// told of in the *TuErr returned, not only the first; nil if there is none.
+func (tu *TranslationUnit) Validate() error {
+	e := tu.Err()
+	tu.checkCursors(e)
+	tu.checkTokens(e)
+	tu.TypeMap.check(e)
+	tu.checkLocations(e)
+	tu.checkLinks(e)
+	tu.checkChildren(e)
+	tu.checkBackLinks(e)
+	return e.checked()
+}
+
+// checkChildren checks the Children of each cursor, but for the Back
+// cursors, are the cursors that have it for their parent, following each
+// other after it.
+func (tu *TranslationUnit) checkChildren(e *TuErr) {
+	n := len(tu.Cursors)
+	counts := make([]int, n)
+	for j := range tu.Cursors {
+		if p := tu.Cursors[j].ParentIndex; p >= 0 && p < j {
+			counts[p]++
+		}
+	}
+	for i := range tu.Cursors {
+		c := &tu.Cursors[i]
+		if c.CursorKindId == cursorkind.Back {
+			if counts[i] != 0 {
+				e.Msg(fmt.Sprintf("cursor %d: Back cursor with %d children", i, counts[i])).Cursor(i)
+			}
+			continue
+		}
+		h, l := c.Children.Head, c.Children.Len
+		if l < 0 || (l > 0 && (h <= i || h > n-l)) {
+			e.Msg(fmt.Sprintf("cursor %d: children %d:%d out of range", i, h, l)).Cursor(i)
+			continue
+		}
+		if l != counts[i] {
+			e.Msg(fmt.Sprintf("cursor %d: %d children, not %d", i, counts[i], l)).Cursor(i).ChildrenCount()
+		}
+		for j := h; j < h+l; j++ {
+			if p := tu.Cursors[j].ParentIndex; p != i {
+				e.Msg(fmt.Sprintf("cursor %d: child %d has parent %d", i, j, p)).Cursor(i).Children(j)
+			}
+		}
+	}
+	// A child outside the Children of its parent tells they do not follow
+	// each other.
+	for j := range tu.Cursors {
+		p := tu.Cursors[j].ParentIndex
+		if p < 0 || p >= j || tu.Cursors[p].CursorKindId == cursorkind.Back {
+			continue
+		}
+		if c := tu.Cursors[p].Children; j < c.Head || j >= c.Next() {
+			e.Msg(fmt.Sprintf("cursor %d: outside the children %d:%d of its parent %d", j, c.Head, c.Len, p)).Children(j)
+		}
+	}
+}
+
+// checkBackLinks checks the Back map leads from each of the Back cursors,
+// and only from them, to a cursor before it, and that the Back, Referenced
+// and Definition maps lead to no Back cursor. The Children of a Back cursor
+// are none, or the cursor SetBackChildren has it lead to.
+func (tu *TranslationUnit) checkBackLinks(e *TuErr) {
+	n := len(tu.Cursors)
+	for i := range tu.Cursors {
+		c := &tu.Cursors[i]
+		if c.CursorKindId != cursorkind.Back {
+			continue
+		}
+		seen, ok := tu.Back[i]
+		if !ok {
+			e.Msg(fmt.Sprintf("cursor %d: Back cursor not in the Back map", i)).Cursor(i)
+			continue
+		}
+		if c.Children.Len != 0 && (c.Children.Len != 1 || c.Children.Head != seen) {
+			e.Msg(fmt.Sprintf("cursor %d: Back cursor with children %d:%d, not leading to %d", i, c.Children.Head, c.Children.Len, seen)).Cursor(i)
+		}
+	}
+	for _, k := range sortedKeys(tu.Back) {
+		v := tu.Back[k]
+		if k < 0 || k >= n || v < 0 || v >= n {
+			continue // Told of by checkCursors.
+		}
+		if tu.Cursors[k].CursorKindId != cursorkind.Back {
+			e.Msg(fmt.Sprintf("Back %d:%d: not from a Back cursor", k, v)).Cursor(k)
+		}
+		if v >= k {
+			e.Msg(fmt.Sprintf("Back %d:%d: not leading back", k, v)).Cursor(k)
+		}
+	}
+	for _, m := range []struct {
+		name string
+		m    map[int]int
+	}{
+		{"Back", tu.Back},
+		{"Referenced", tu.Referenced},
+		{"Definition", tu.Definition},
+	} {
+		for _, k := range sortedKeys(m.m) {
+			if v := m.m[k]; v >= 0 && v < n && tu.Cursors[v].CursorKindId == cursorkind.Back {
+				e.Msg(fmt.Sprintf("%s %d:%d: leading to a Back cursor", m.name, k, v))
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package ast
+
+import "github.com/frankreh/go-clang/clang/cursorkind"
+
+// WalkAction is returned by the functions called by Walk to tell it how to go on.
+type WalkAction int
+
+const (
+	WalkContinue WalkAction = iota // Go on, into the children of the cursor when entering it.
+	WalkSkip                       // Skip the children of the cursor entered.
+	WalkStop                       // Stop the walk.
+)
+
+// BackLinks tells the traversals what to do with the cursorkind.Back cursors,
+// which stand for a cursor clang visits a second time, under another parent.
+type BackLinks int
+
+const (
+	IgnoreBack BackLinks = iota // Leave the Back cursors out.
+	KeepBack                    // Visit the Back cursors themselves, as leaves.
+	FollowBack                  // Visit the cursor a Back cursor leads to in its place, with its descendants.
+)
+
+// Walk walks the tree of cursors from root, depth first, leaving the Back
+// cursors out. See WalkWith.
+func (tu *TranslationUnit) Walk(root int, enter, leave func(id int) WalkAction) {
+	tu.WalkWith(root, IgnoreBack, enter, leave)
+}
+
+// WalkWith walks the tree of cursors from root, depth first, calling enter
+// before the children of a cursor are walked and leave after. Either can be
+// nil. leave is called for every cursor entered, even when enter returns
+// WalkSkip, until one of them returns WalkStop.
+//
+// The Back cursors are treated as told by back. When following them, a Back
+// cursor leading to a cursor being walked, which would loop, is left out.
+//
+// The walk relies on the Children of the cursors, which Populate and
+// DecodeGobV1 set.
+func (tu *TranslationUnit) WalkWith(root int, back BackLinks, enter, leave func(id int) WalkAction) {
+	if root < 0 || root >= len(tu.Cursors) {
+		return
+	}
+	w := walker{tu: tu, back: back, enter: enter, leave: leave}
+	if back == FollowBack {
+		w.walking = make(map[int]bool)
+	}
+	if id, ok := w.resolve(root); ok {
+		w.walk(id)
+	}
+}
+
+type walker struct {
+	tu           *TranslationUnit
+	back         BackLinks
+	enter, leave func(id int) WalkAction
+	walking      map[int]bool // The cursors being walked, when following Back links.
+}
+
+// resolve returns the cursor to visit for id, and whether there is one.
+func (w *walker) resolve(id int) (int, bool) {
+	if w.tu.Cursors[id].CursorKindId != cursorkind.Back {
+		return id, true
+	}
+	switch w.back {
+	case KeepBack:
+		return id, true
+	case FollowBack:
+		seen, ok := w.tu.Back[id]
+		if !ok || seen < 0 || seen >= len(w.tu.Cursors) || w.walking[seen] {
+			return 0, false
+		}
+		return seen, true
+	}
+	return 0, false
+}
+
+// walk walks the tree from id and returns false once the walk is stopped.
+func (w *walker) walk(id int) bool {
+	action := WalkContinue
+	if w.enter != nil {
+		action = w.enter(id)
+	}
+	if action == WalkStop {
+		return false
+	}
+	if action != WalkSkip && w.tu.Cursors[id].CursorKindId != cursorkind.Back {
+		if w.walking != nil {
+			w.walking[id] = true
+		}
+		children := w.tu.Cursors[id].Children
+		for child := children.Head; child < children.Next(); child++ {
+			if c, ok := w.resolve(child); ok && !w.walk(c) {
+				return false
+			}
+		}
+		if w.walking != nil {
+			delete(w.walking, id)
+		}
+	}
+	if w.leave != nil && w.leave(id) == WalkStop {
+		return false
+	}
+	return true
+}
+
+// Children returns the children of the cursor, in order, with the Back
+// cursors treated as told by back. The children of a Back cursor followed are
+// those of the cursor it leads to.
+func (tu *TranslationUnit) Children(id int, back BackLinks) []int {
+	if id < 0 || id >= len(tu.Cursors) {
+		return nil
+	}
+	if tu.Cursors[id].CursorKindId == cursorkind.Back {
+		seen, ok := tu.Back[id]
+		if back != FollowBack || !ok || seen < 0 || seen >= len(tu.Cursors) {
+			return nil
+		}
+		id = seen
+	}
+	w := walker{tu: tu, back: back}
+	var r []int
+	children := tu.Cursors[id].Children
+	for child := children.Head; child < children.Next(); child++ {
+		if c, ok := w.resolve(child); ok {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// Ancestors returns the parent of the cursor, the parent of the parent and so
+// on up to the root.
+func (tu *TranslationUnit) Ancestors(id int) []int {
+	var r []int
+	for id >= 0 && id < len(tu.Cursors) {
+		id = tu.Cursors[id].ParentIndex
+		if id < 0 || id >= len(tu.Cursors) || len(r) >= len(tu.Cursors) {
+			break
+		}
+		r = append(r, id)
+	}
+	return r
+}
+
+// Siblings returns the other children of the parent of the cursor, in order,
+// with the Back cursors treated as told by back.
+func (tu *TranslationUnit) Siblings(id int, back BackLinks) []int {
+	if id < 0 || id >= len(tu.Cursors) {
+		return nil
+	}
+	var r []int
+	for _, c := range tu.Children(tu.Cursors[id].ParentIndex, back) {
+		if c != id {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// Descendants returns the descendants of the cursor of the given kind, in
+// the order Walk finds them, with the Back cursors treated as told by back.
+// When following them, a cursor reached through Back links is listed each
+// time it is reached.
+func (tu *TranslationUnit) Descendants(id int, kind cursorkind.Kind, back BackLinks) []int {
+	var r []int
+	root := true
+	tu.WalkWith(id, back, func(d int) WalkAction {
+		if root {
+			root = false
+			return WalkContinue
+		}
+		if tu.Cursors[d].CursorKindId == kind {
+			r = append(r, d)
+		}
+		return WalkContinue
+	}, nil)
+	return r
+}
+
+// ParamNames returns the names of the parameters of the function cursor, the
+// names of its ParmDecl children, "" for those unnamed. The names are those
+// of this declaration; the TypeFunction of the cursor is shared with the
+// declarations that name them otherwise.
+func (tu *TranslationUnit) ParamNames(id int) []string {
+	var r []string
+	for _, c := range tu.Children(id, FollowBack) {
+		if cursor := tu.Cursors[c]; cursor.CursorKindId == cursorkind.ParmDecl {
+			r = append(r, tu.CursorNameMap.ToString(cursor.CursorNameId))
+		}
+	}
+	return r
+}
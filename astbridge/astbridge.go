@@ -27,16 +27,17 @@ type ClangTranslationUnit struct {
 }
 
 // Tokenize
-//		ensures GoTo slice and map structures used for tokens are initialized,
-//		calls Tokenize on the cursor's Extent,
-//		can return a range of already existing toeksn if the first token is found in the map,
-//		otherwise appends the new clang tokens to those being tracked,
-//		creates GoTo Tokens from the Token kind and spelling,
-//		adds them to the TokenMap, which may recognize them as already in the map,
-//		appends the GoTo Token indexes to the slice of tokens,
-//		and puts all the Token source locations into the map to allow
-//		subsequent calls to possibly find their subrange of tokens is already in
-//		the slice.
+//
+//	ensures GoTo slice and map structures used for tokens are initialized,
+//	calls Tokenize on the cursor's Extent,
+//	can return a range of already existing toeksn if the first token is found in the map,
+//	otherwise appends the new clang tokens to those being tracked,
+//	creates GoTo Tokens from the Token kind and spelling,
+//	adds them to the TokenMap, which may recognize them as already in the map,
+//	appends the GoTo Token indexes to the slice of tokens,
+//	and puts all the Token source locations into the map to allow
+//	subsequent calls to possibly find their subrange of tokens is already in
+//	the slice.
 func (ctu *ClangTranslationUnit) Tokenize(cursor clang.Cursor) ast.IndexPair {
 	// Ensure setup of the slice and the map.
 	if ctu.GoTu.TokenIds == nil {
@@ -47,6 +48,9 @@ func (ctu *ClangTranslationUnit) Tokenize(cursor clang.Cursor) ast.IndexPair {
 	if ctu.mapTokenIndex == nil {
 		ctu.mapTokenIndex = make(map[clang.SourceLocation]int)
 	}
+	if ctu.GoTu.TokenSpellings == nil {
+		ctu.GoTu.TokenSpellings = make(map[int]ast.Location)
+	}
 	m := ctu.mapTokenIndex
 
 	// Process tokens from cursor. Most times they will already have been seen.
@@ -82,12 +86,38 @@ func (ctu *ClangTranslationUnit) Tokenize(cursor clang.Cursor) ast.IndexPair {
 		*s = append(*s, tokenId)
 
 		clangSourceLocation := ctu.ClangTu.TokenLocation(clangToken)
+		expansion, spelling := ctu.locations(clangSourceLocation)
+		if spelling != expansion {
+			ctu.GoTu.TokenSpellings[len(ctu.GoTu.TokenLocs)] = spelling
+		}
+		ctu.GoTu.TokenLocs = append(ctu.GoTu.TokenLocs, expansion)
+
 		m[clangSourceLocation] = len(m) // len tracks length of the slice and the map.
 	}
 
 	return tokenRange
 }
 
+// locations returns the expansion and spelling locations of the clang source
+// location, adding their files to the file table.
+func (ctu *ClangTranslationUnit) locations(sl clang.SourceLocation) (ast.Location, ast.Location) {
+	file, line, column, offset := sl.ExpansionLocation()
+	expansion := ctu.GoTu.AddLocation(file.Name(), int(offset), int(line), int(column))
+	file, line, column, offset = sl.SpellingLocation()
+	spelling := ctu.GoTu.AddLocation(file.Name(), int(offset), int(line), int(column))
+	return expansion, spelling
+}
+
+// addCursorLocation appends the locations of the cursor to the lists that
+// mirror the Cursors.
+func (ctu *ClangTranslationUnit) addCursorLocation(cursor clang.Cursor) {
+	expansion, spelling := ctu.locations(cursor.Location())
+	if spelling != expansion {
+		ctu.GoTu.CursorSpellings[len(ctu.GoTu.CursorLocs)] = spelling
+	}
+	ctu.GoTu.CursorLocs = append(ctu.GoTu.CursorLocs, expansion)
+}
+
 func (ctu *ClangTranslationUnit) Populate(tu *clang.TranslationUnit, topLevelNamesToSkip map[string]bool) error {
 	if ctu.ClangTu != nil {
 		return errors.New("Already populated")
@@ -96,6 +126,9 @@ func (ctu *ClangTranslationUnit) Populate(tu *clang.TranslationUnit, topLevelNam
 
 	// For some tidyness, have the "" string map to the 0 id.
 	_ = ctu.GoTu.CursorNameMap.Id("")
+	// And the 0 file id stands for no file.
+	_ = ctu.GoTu.Files.Id("")
+	ctu.GoTu.CursorSpellings = make(map[int]ast.Location)
 
 	ctu.ClangTu = tu
 	clangRootCursor := tu.TranslationUnitCursor()
@@ -130,6 +163,7 @@ func (ctu *ClangTranslationUnit) Populate(tu *clang.TranslationUnit, topLevelNam
 		// to better show what's going on.
 		// Index: 0, // Index no longer exists in this structure.
 	})
+	ctu.addCursorLocation(clangRootCursor)
 
 	debug := false
 	if debug {
@@ -194,6 +228,11 @@ func (ctu *ClangTranslationUnit) Populate(tu *clang.TranslationUnit, topLevelNam
 				// Keep the lists the same length. Use the nullCursor as a place holder.
 				ctu.GoTu.Cursors = append(ctu.GoTu.Cursors, backCursor)
 				ctu.ClangCursors = append(ctu.ClangCursors, nullCursor)
+				// The Back cursor is located where the cursor it leads to is.
+				ctu.GoTu.CursorLocs = append(ctu.GoTu.CursorLocs, ctu.GoTu.CursorLocs[seenIndex])
+				if spelling, ok := ctu.GoTu.CursorSpellings[seenIndex]; ok {
+					ctu.GoTu.CursorSpellings[ownIndex] = spelling
+				}
 
 			} else {
 
@@ -246,6 +285,7 @@ func (ctu *ClangTranslationUnit) Populate(tu *clang.TranslationUnit, topLevelNam
 				// lengths not match.
 				ctu.GoTu.Cursors = append(ctu.GoTu.Cursors, newCursor)
 				ctu.ClangCursors = append(ctu.ClangCursors, cursor)
+				ctu.addCursorLocation(cursor)
 
 				// Record when cursor has a referenced cursor and when it has a difference definition cursor.
 				if referenced := cursor.Referenced(); interestingCursor(referenced, cursor) {
@@ -312,6 +352,10 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 
 	tkind := ctype.Kind()
 	typeIndex = ctu.GoTu.TypeMap.AutoKeyIndex(tkind)
+	if tkind == typekind.Unexposed && ctype.NumTemplateArguments() > 0 {
+		// A template specialization, which libclang does not expose.
+		typeIndex = -1
+	}
 	if typeIndex == -1 {
 
 		// The type spelling.
@@ -327,7 +371,7 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 			break
 		default:
 			alignof, err = ctype.AlignOf()
-			if err != nil {
+			if err != nil && !noLayout(err) {
 				panic(fmt.Sprintf("In calling AlignOf, type %v %s %s: %s", ctype, tkind, typespelling, err))
 			}
 		}
@@ -341,7 +385,7 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 			break
 		default:
 			sizeof, err = ctype.SizeOf()
-			if err != nil {
+			if err != nil && !noLayout(err) {
 				panic(fmt.Sprintf("In calling SizeOf, type %v %s %s: %s", ctype, tkind, typespelling, err))
 			}
 		}
@@ -361,6 +405,15 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 				panic(errmsg + ": " + err.Error())
 			}
 
+			// Cache the record before determining the types of its fields,
+			// which may lead back to it.
+			ctu.typeIndexes[ctype] = typeIndex
+			err = ctu.GoTu.TypeMap.SetFields(typeIndex, ctu.recordFields(ctype))
+			if err != nil {
+				errmsg := fmt.Sprintf("%[1]s:%[1]d", ctype.Kind())
+				panic(errmsg + ": " + err.Error())
+			}
+
 		case typekind.Enum:
 			// Same as Record
 
@@ -420,10 +473,13 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 
 				func(resulttypeindex int) (int, error) {
 					return ctu.GoTu.TypeMap.AddFunction(ast.TypeFunction{
-						TypeKindKind: ast.TypeKindKind{tkind},
-						ResultTypeId: resulttypeindex,
-						ArgIds:       argtypeindexes,
-						TypeSpelling: typespelling,
+						TypeKindKind:  ast.TypeKindKind{TypeKind: tkind},
+						ResultTypeId:  resulttypeindex,
+						ArgIds:        argtypeindexes,
+						TypeSpelling:  typespelling,
+						CallingConv:   int(ctype.FunctionTypeCallingConv()),
+						Variadic:      ctype.IsFunctionTypeVariadic(),
+						ExceptionSpec: int(ctype.ExceptionSpecification()),
 					})
 				})
 
@@ -462,10 +518,210 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 					})
 				})
 
+		case typekind.IncompleteArray:
+
+			typeIndex = ctu.addSuperWithOneSubType(tkind,
+				ctype.ElementType(),
+
+				func(elemtypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddIncompleteArray(ast.TypeIncompleteArray{
+						TypeVariableArray: ast.TypeVariableArray{
+							ElemTypeId:   elemtypeindex,
+							Align:        int(alignof),
+							Size:         int(sizeof),
+							TypeSpelling: typespelling,
+						},
+					})
+				})
+
+		case typekind.DependentSizedArray:
+
+			typeIndex = ctu.addSuperWithOneSubType(tkind,
+				ctype.ElementType(),
+
+				func(elemtypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddDependentSizedArray(ast.TypeDependentSizedArray{
+						TypeVariableArray: ast.TypeVariableArray{
+							ElemTypeId:   elemtypeindex,
+							Align:        int(alignof),
+							Size:         int(sizeof),
+							TypeSpelling: typespelling,
+						},
+					})
+				})
+
+		case typekind.LValueReference,
+			typekind.RValueReference:
+			typeIndex = ctu.determineTypeIndex2(tkind,
+				"Referencee",
+				ctype.PointeeType(),
+				func(pointeetypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddReference(ast.TypeReference{
+						TypeKindKind:        ast.TypeKindKind{TypeKind: tkind},
+						UnderlyingTypeIndex: pointeetypeindex,
+					})
+				})
+		case typekind.BlockPointer:
+			typeIndex = ctu.determineTypeIndex2(tkind,
+				"Pointee",
+				ctype.PointeeType(),
+				func(pointeetypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddBlockPointer(ast.TypeBlockPointer{
+						UnderlyingTypeIndex: pointeetypeindex,
+					})
+				})
+		case typekind.ObjCObjectPointer:
+			typeIndex = ctu.determineTypeIndex2(tkind,
+				"Pointee",
+				ctype.PointeeType(),
+				func(pointeetypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddObjCObjectPointer(ast.TypeObjCObjectPointer{
+						UnderlyingTypeIndex: pointeetypeindex,
+					})
+				})
+		case typekind.Atomic:
+			typeIndex = ctu.determineTypeIndex2(tkind,
+				"Value",
+				ctype.ValueType(),
+				func(valuetypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddAtomic(ast.TypeAtomic{
+						UnderlyingTypeIndex: valuetypeindex,
+					})
+				})
+
+		case typekind.MemberPointer:
+			classtypeindex := ctu.mustDetermineSubTypeIndex(tkind, ctype.ClassType(), "class")
+
+			typeIndex = ctu.addSuperWithOneSubType(tkind,
+				ctype.PointeeType(),
+
+				func(pointeetypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddMemberPointer(ast.TypeMemberPointer{
+						PointeeTypeId: pointeetypeindex,
+						ClassTypeId:   classtypeindex,
+						Align:         int(alignof),
+						Size:          int(sizeof),
+						TypeSpelling:  typespelling,
+					})
+				})
+
+		case typekind.Vector,
+			typekind.ExtVector,
+			typekind.Complex:
+			numelem := int64(2) // The real and imaginary parts of a Complex.
+			if tkind != typekind.Complex {
+				numelem = ctype.NumElements()
+			}
+
+			typeIndex = ctu.addSuperWithOneSubType(tkind,
+				ctype.ElementType(),
+
+				func(elemtypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddVector(ast.TypeVector{
+						TypeKindKind: ast.TypeKindKind{TypeKind: tkind},
+						ElemTypeId:   elemtypeindex,
+						ElemCount:    int(numelem),
+						Align:        int(alignof),
+						Size:         int(sizeof),
+						TypeSpelling: typespelling,
+					})
+				})
+
+		case typekind.Auto:
+			typeIndex, err = ctu.GoTu.TypeMap.AddAuto(ast.TypeAuto{
+				DeducedTypeId: ctu.canonicalTypeIndex(ctype),
+				Align:         int(alignof),
+				Size:          int(sizeof),
+				TypeSpelling:  typespelling,
+			})
+			if err != nil {
+				errmsg := fmt.Sprintf("%[1]s:%[1]d", ctype.Kind())
+				panic(errmsg + ": " + err.Error())
+			}
+
+		case typekind.Attributed:
+			typeIndex = ctu.addSuperWithOneSubType(tkind,
+				ctype.ModifiedType(),
+
+				func(modifiedtypeindex int) (int, error) {
+					return ctu.GoTu.TypeMap.AddAttributed(ast.TypeAttributed{
+						ModifiedTypeId: modifiedtypeindex,
+						Nullability:    ctype.Nullability(),
+						Align:          int(alignof),
+						Size:           int(sizeof),
+						TypeSpelling:   typespelling,
+					})
+				})
+
+		case typekind.Unexposed:
+			// Only template specializations get here.
+
+			// Build list of template argument types, -1 for those that are not types.
+			var argtypeindexes []int
+			numargs := ctype.NumTemplateArguments()
+			for i := int32(0); i < numargs; i++ {
+				ati := -1
+				if at := ctype.TemplateArgumentAsType(uint32(i)); at.Kind() != typekind.Invalid {
+					ati = ctu.determineTypeIndex(at)
+				}
+				argtypeindexes = append(argtypeindexes, ati)
+			}
+
+			typeIndex, err = ctu.GoTu.TypeMap.AddTemplateSpecialization(ast.TypeTemplateSpecialization{
+				CanonicalTypeId: ctu.canonicalTypeIndex(ctype),
+				ArgTypeIds:      argtypeindexes,
+				Align:           int(alignof),
+				Size:            int(sizeof),
+				TypeSpelling:    typespelling,
+			})
+			if err != nil {
+				errmsg := fmt.Sprintf("%[1]s:%[1]d", ctype.Kind())
+				panic(errmsg + ": " + err.Error())
+			}
+
+		case typekind.ObjCInterface,
+			typekind.ObjCTypeParam:
+			typeIndex, err = ctu.GoTu.TypeMap.AddObjC(ast.TypeObjC{
+				TypeKindKind: ast.TypeKindKind{TypeKind: tkind},
+				BaseTypeId:   -1,
+				TypeSpelling: typespelling,
+			})
+			if err != nil {
+				errmsg := fmt.Sprintf("%[1]s:%[1]d", ctype.Kind())
+				panic(errmsg + ": " + err.Error())
+			}
+
+		case typekind.ObjCObject:
+			basetypeindex := ctu.mustDetermineSubTypeIndex(tkind, ctype.ObjCObjectBaseType(), "base")
+
+			var argtypeindexes []int
+			numargs := ctype.NumObjCTypeArgs()
+			for i := uint(0); i < numargs; i++ {
+				ati := ctu.mustDetermineSubTypeIndex(tkind, ctype.ObjCTypeArg(i), "arg")
+				argtypeindexes = append(argtypeindexes, ati)
+			}
+			var protocols []string
+			numprotocols := ctype.NumObjCProtocolRefs()
+			for i := uint(0); i < numprotocols; i++ {
+				protocols = append(protocols, ctype.ObjCProtocolDecl(i).Spelling())
+			}
+
+			typeIndex, err = ctu.GoTu.TypeMap.AddObjC(ast.TypeObjC{
+				TypeKindKind: ast.TypeKindKind{TypeKind: tkind},
+				BaseTypeId:   basetypeindex,
+				TypeArgIds:   argtypeindexes,
+				Protocols:    protocols,
+				TypeSpelling: typespelling,
+			})
+			if err != nil {
+				errmsg := fmt.Sprintf("%[1]s:%[1]d", ctype.Kind())
+				panic(errmsg + ": " + err.Error())
+			}
+
 		default:
 			if tkind.IsBuiltin() {
 				typeIndex, err = ctu.GoTu.TypeMap.AddIntrinsic(ast.TypeIntrinsic{
-					TypeKindKind: ast.TypeKindKind{tkind},
+					TypeKindKind: ast.TypeKindKind{TypeKind: tkind},
 					Align:        int(alignof), // TBD change api to return int rather than uint64.
 					Size:         int(sizeof),  // TBD change api to return int rather than uint64.
 					TypeSpelling: typespelling,
@@ -482,6 +738,14 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 				typeIndex = ctu.GoTu.TypeMap.MustAutoKeyIndex(typekind.Unexposed) // TBD
 			}
 		}
+
+		// The qualifiers of the type.
+
+		if q := qualifiers(ctype); q != 0 && typeIndex > 1 {
+			if err := ctu.GoTu.TypeMap.Qualify(typeIndex, q); err != nil {
+				panic(err)
+			}
+		}
 	}
 
 	// Cache typeIndex.
@@ -489,6 +753,70 @@ func (ctu *ClangTranslationUnit) determineTypeIndex(ctype clang.Type) int {
 	return typeIndex
 }
 
+// recordFields returns the fields of the record type, in the order they are
+// declared.
+func (ctu *ClangTranslationUnit) recordFields(ctype clang.Type) []ast.TypeField {
+	var fields []ast.TypeField
+	ctype.Declaration().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() != cursorkind.FieldDecl {
+			return clang.ChildVisit_Continue
+		}
+		field := ast.TypeField{
+			Name:     cursor.Spelling(),
+			TypeId:   ctu.determineTypeIndex(cursor.Type()),
+			Offset:   -1,
+			BitWidth: -1,
+		}
+		if offset, err := cursor.OffsetOfField(); err == nil {
+			field.Offset = int(offset)
+		}
+		if cursor.IsBitField() {
+			field.BitWidth = int(cursor.FieldDeclBitWidth())
+		}
+		fields = append(fields, field)
+		return clang.ChildVisit_Continue
+	})
+	return fields
+}
+
+// qualifiers returns the const, volatile and restrict qualifiers of ctype.
+func qualifiers(ctype clang.Type) ast.Qualifiers {
+	var q ast.Qualifiers
+	if ctype.IsConstQualifiedType() {
+		q |= ast.QualConst
+	}
+	if ctype.IsVolatileQualifiedType() {
+		q |= ast.QualVolatile
+	}
+	if ctype.IsRestrictQualifiedType() {
+		q |= ast.QualRestrict
+	}
+	return q
+}
+
+// canonicalTypeIndex returns the type index of the canonical type of ctype,
+// or -1 when ctype is its own canonical type, as an undeduced auto is.
+func (ctu *ClangTranslationUnit) canonicalTypeIndex(ctype clang.Type) int {
+	canonical := ctype.CanonicalType()
+	if canonical.Kind() == typekind.Invalid || canonical.Equal(ctype) {
+		return -1
+	}
+	return ctu.determineTypeIndex(canonical)
+}
+
+// noLayout reports whether err is that of a type clang cannot lay out
+// because it is incomplete, dependent or undeduced. Such types are given an
+// alignment and size of 0.
+func noLayout(err error) bool {
+	switch err {
+	case clang.TypeLayout_IncompleteErr,
+		clang.TypeLayout_DependentErr,
+		clang.TypeLayout_UndeducedErr:
+		return true
+	}
+	return false
+}
+
 func (ctu *ClangTranslationUnit) determineTypeIndex2(tkind typekind.Kind,
 	errname string,
 	pointeetype clang.Type,
@@ -533,13 +861,14 @@ func (ctu *ClangTranslationUnit) addSuperWithOneSubType(superTypeKind typekind.K
 
 func (ctu *ClangTranslationUnit) mustDetermineSubTypeIndex(superTypeKind typekind.Kind, subType clang.Type, errname string) int {
 	subtypeindex := ctu.determineTypeIndex(subType)
-	if subtypeindex <= 1 {
-		// Would indicate an array of an Invalid or Unexposed type kind.
+	if subtypeindex == 0 {
+		// Would indicate an array of an Invalid type kind. Unexposed
+		// subtypes, like template type parameters, are kept.
 		errmsg := fmt.Sprintf("%[1]s:%[1]d", superTypeKind)
 		errmsg += fmt.Sprintf(" %s %[2]s:%[2]d", errname, subType.Kind())
 		errmsg += fmt.Sprintf(" %s typeindex %d", errname, subtypeindex)
 		errmsg += fmt.Sprintf(" Key%v", ctu.GoTu.TypeMap.Keys[subtypeindex])
-		panic(errmsg + ": " + errname + " typeindex <= 1")
+		panic(errmsg + ": " + errname + " typeindex is 0")
 	}
 	return subtypeindex
 }
@@ -0,0 +1,266 @@
+// Package astquery selects the cursors of an ast.TranslationUnit with
+// selectors in the manner of CSS, so the questions asked of a serialized AST
+// need no Go code of their own.
+//
+// A selector is a chain of compounds. A compound is the name of a cursor
+// kind, as cursorkind.Kind.String gives it, or * for any kind, followed by
+// conditions:
+//
+//	[name=main]      the spelling of the cursor is main
+//	[type^="char"]   the spelling of its type starts with char
+//	[token=malloc]   one of its tokens is malloc
+//	[name]           it has a name
+//	:has(CallExpr)   a descendant of it is a CallExpr
+//	:has(> ParmDecl) a child of it is a ParmDecl
+//	:not(VarDecl, [name=x])
+//
+// The operators are = and != for equality, ^= for a prefix, $= for a
+// suffix, *= for a substring and ~= for a regular expression. [token!=x] holds
+// when no token of the cursor is x. Values with spaces or ] are quoted with "
+// or '.
+//
+// Compounds are related to the one before them by combinators: a space for
+// a descendant, > for a child, + for the next sibling and ~ for any later
+// sibling. Selectors separated by commas select the cursors any of them
+// selects. For example
+//
+//	FunctionDecl[name=main] > CompoundStmt CallExpr[name^=str]
+//
+// selects the calls of the str functions made in the body of main.
+//
+// The Back cursors, which stand for a cursor clang visits a second time, are
+// never selected and do not count as children or siblings.
+package astquery
+
+import (
+	"strings"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Query is a compiled selector.
+type Query struct {
+	selector string
+	list     []*complexSelector
+}
+
+// Compile compiles a selector, or returns a *SyntaxError.
+func Compile(selector string) (*Query, error) {
+	p := &parser{s: selector}
+	list, err := p.list(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return &Query{selector: selector, list: list}, nil
+}
+
+// MustCompile is like Compile but panics if the selector does not compile.
+func MustCompile(selector string) *Query {
+	q, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func (q *Query) String() string {
+	return q.selector
+}
+
+// Select returns the cursors of the translation unit the query selects, in
+// the order of ast.TranslationUnit.Walk.
+func (q *Query) Select(tu *ast.TranslationUnit) []int {
+	return q.SelectFrom(tu, 0)
+}
+
+// SelectFrom returns the cursors the query selects among root and its
+// descendants, in the order of ast.TranslationUnit.Walk. The relations of
+// the selector are not limited to the descendants of root, only the cursors
+// selected are.
+func (q *Query) SelectFrom(tu *ast.TranslationUnit, root int) []int {
+	m := &matcher{tu: tu}
+	var r []int
+	tu.Walk(root, func(id int) ast.WalkAction {
+		if m.matchList(q.list, id, -1) {
+			r = append(r, id)
+		}
+		return ast.WalkContinue
+	}, nil)
+	return r
+}
+
+// Match reports whether the query selects the cursor.
+func (q *Query) Match(tu *ast.TranslationUnit, id int) bool {
+	if id < 0 || id >= len(tu.Cursors) {
+		return false
+	}
+	return (&matcher{tu: tu}).matchList(q.list, id, -1)
+}
+
+type matcher struct {
+	tu *ast.TranslationUnit
+}
+
+// matchList reports whether any of the selectors matches id. anchor is the
+// cursor of :has(), -1 outside of it.
+func (m *matcher) matchList(list []*complexSelector, id, anchor int) bool {
+	for _, c := range list {
+		if m.matchComplex(c, len(c.compounds)-1, id, anchor) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchComplex reports whether id matches the compound i of c, and the
+// compounds before it match the cursors it is related to.
+func (m *matcher) matchComplex(c *complexSelector, i, id, anchor int) bool {
+	if !m.matchCompound(c.compounds[i], id) {
+		return false
+	}
+	if i == 0 {
+		return anchor < 0 || m.related(c.combinators[0], anchor, id)
+	}
+	for _, x := range m.candidates(c.combinators[i], id) {
+		if m.matchComplex(c, i-1, x, anchor) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the cursors that x must be one of to be related to id
+// by comb, as the left side of comb.
+func (m *matcher) candidates(comb byte, id int) []int {
+	switch comb {
+	case descendant:
+		return m.tu.Ancestors(id)
+	case child:
+		if p := m.tu.Cursors[id].ParentIndex; p >= 0 {
+			return []int{p}
+		}
+	case adjacent, sibling:
+		before := m.before(id)
+		if comb == adjacent && len(before) > 0 {
+			return before[len(before)-1:]
+		}
+		return before
+	}
+	return nil
+}
+
+// related reports whether x is related to id by comb.
+func (m *matcher) related(comb byte, x, id int) bool {
+	for _, c := range m.candidates(comb, id) {
+		if c == x {
+			return true
+		}
+	}
+	return false
+}
+
+// before returns the siblings of id that come before it.
+func (m *matcher) before(id int) []int {
+	var r []int
+	for _, s := range m.tu.Children(m.tu.Cursors[id].ParentIndex, ast.IgnoreBack) {
+		if s == id {
+			break
+		}
+		r = append(r, s)
+	}
+	return r
+}
+
+func (m *matcher) matchCompound(c *compound, id int) bool {
+	cursor := &m.tu.Cursors[id]
+	if c.anyKind {
+		if cursor.CursorKindId == cursorkind.Back {
+			return false
+		}
+	} else if cursor.CursorKindId != c.kind {
+		return false
+	}
+	for _, a := range c.attrs {
+		if !m.matchAttr(a, id) {
+			return false
+		}
+	}
+	for _, l := range c.not {
+		if m.matchList(l, id, -1) {
+			return false
+		}
+	}
+	for _, l := range c.has {
+		if !m.has(l, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// has reports whether a cursor related to id matches one of the relative
+// selectors. The cursors related to id are among the descendants of its
+// parent.
+func (m *matcher) has(list []*complexSelector, id int) bool {
+	scope := m.tu.Cursors[id].ParentIndex
+	if scope < 0 {
+		scope = id
+	}
+	found := false
+	m.tu.Walk(scope, func(x int) ast.WalkAction {
+		if x != id && m.matchList(list, x, id) {
+			found = true
+			return ast.WalkStop
+		}
+		return ast.WalkContinue
+	}, nil)
+	return found
+}
+
+func (m *matcher) matchAttr(a attr, id int) bool {
+	cursor := &m.tu.Cursors[id]
+	switch a.property {
+	case "name":
+		return a.test(m.tu.CursorNameMap.ToString(cursor.CursorNameId))
+	case "type":
+		return a.test(m.tu.TypeMap.Spelling(cursor.TypeIndex))
+	}
+	// Any token, or no token for !=.
+	tokens := cursor.Tokens
+	for i := tokens.Head; i < tokens.Next() && i < len(m.tu.TokenIds); i++ {
+		token := m.tu.TokenMap.ToToken(m.tu.TokenIds[i])
+		spelling := m.tu.TokenNameMap.ToString(token.TokenNameId)
+		if a.op == "!=" {
+			if spelling == a.value {
+				return false
+			}
+		} else if a.test(spelling) {
+			return true
+		}
+	}
+	return a.op == "!="
+}
+
+func (a attr) test(s string) bool {
+	switch a.op {
+	case "":
+		return s != ""
+	case "=":
+		return s == a.value
+	case "!=":
+		return s != a.value
+	case "^=":
+		return strings.HasPrefix(s, a.value)
+	case "$=":
+		return strings.HasSuffix(s, a.value)
+	case "*=":
+		return strings.Contains(s, a.value)
+	case "~=":
+		return a.re.MatchString(s)
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package astquery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/tokenkind"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+type node struct {
+	kind     cursorkind.Kind
+	name     string
+	typ      string
+	tokens   string
+	children []node
+}
+
+// build returns a translation unit of the tree of nodes, laid out as
+// astbridge lays out cursors: the children of a cursor follow each other.
+func build(t *testing.T, root node) *ast.TranslationUnit {
+	tu := &ast.TranslationUnit{}
+	tu.TypeMap.Init()
+	types := map[string]int{"": 0}
+	intrinsic := func(k typekind.Kind, spelling string) int {
+		i, err := tu.TypeMap.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: k}, Size: 4, Align: 4, TypeSpelling: spelling})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	types["int"] = intrinsic(typekind.Int, "int")
+	types["char"] = intrinsic(typekind.Char_S, "char")
+	p, err := tu.TypeMap.AddPointer(ast.TypePointer{UnderlyingTypeIndex: types["char"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types["char *"] = p
+	if types["char **"], err = tu.TypeMap.AddPointer(ast.TypePointer{UnderlyingTypeIndex: p}); err != nil {
+		t.Fatal(err)
+	}
+
+	type queued struct {
+		n      node
+		parent int
+	}
+	_ = tu.CursorNameMap.Id("")
+	queue := []queued{{root, -1}}
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		id := len(tu.Cursors)
+		typ, ok := types[q.n.typ]
+		if !ok {
+			t.Fatalf("no type %s", q.n.typ)
+		}
+		c := ast.Cursor{
+			CursorKindId: q.n.kind,
+			CursorNameId: tu.CursorNameMap.Id(q.n.name),
+			ParentIndex:  q.parent,
+			TypeIndex:    typ,
+			Tokens:       ast.IndexPair{Head: len(tu.TokenIds)},
+		}
+		for _, s := range strings.Fields(q.n.tokens) {
+			token := ast.Token{TokenKindId: tokenkind.Identifier, TokenNameId: tu.TokenNameMap.Id(s)}
+			tu.TokenIds = append(tu.TokenIds, tu.TokenMap.Id(token))
+			c.Tokens.Len++
+		}
+		tu.Cursors = append(tu.Cursors, c)
+		if q.parent >= 0 {
+			children := &tu.Cursors[q.parent].Children
+			if children.Len == 0 {
+				children.Head = id
+			}
+			children.Len++
+		}
+		for _, child := range q.n.children {
+			queue = append(queue, queued{child, id})
+		}
+	}
+	return tu
+}
+
+func sample(t *testing.T) *ast.TranslationUnit {
+	return build(t, node{kind: cursorkind.TranslationUnit, name: "sample.c", children: []node{
+		{kind: cursorkind.FunctionDecl, name: "main", children: []node{
+			{kind: cursorkind.ParmDecl, name: "argc", typ: "int"},
+			{kind: cursorkind.ParmDecl, name: "argv", typ: "char **"},
+			{kind: cursorkind.CompoundStmt, children: []node{
+				{kind: cursorkind.CallExpr, name: "strlen", typ: "int", tokens: "strlen ( s )", children: []node{
+					{kind: cursorkind.UnexposedExpr, name: "s", children: []node{
+						{kind: cursorkind.DeclRefExpr, name: "s", typ: "char *", tokens: "s"},
+					}},
+				}},
+				{kind: cursorkind.CallExpr, name: "malloc", typ: "char *", tokens: "malloc ( 10 )"},
+				{kind: cursorkind.ReturnStmt, tokens: "return 0"},
+			}},
+		}},
+		{kind: cursorkind.FunctionDecl, name: "helper", children: []node{
+			{kind: cursorkind.CompoundStmt, children: []node{
+				{kind: cursorkind.CallExpr, name: "strcpy", tokens: "strcpy ( a , b )"},
+			}},
+		}},
+		{kind: cursorkind.VarDecl, name: "x", typ: "int", tokens: "int x"},
+	}})
+}
+
+func describe(tu *ast.TranslationUnit, ids []int) string {
+	var r []string
+	for _, id := range ids {
+		c := tu.Cursors[id]
+		r = append(r, c.CursorKindId.String()+":"+tu.CursorNameMap.ToString(c.CursorNameId))
+	}
+	return strings.Join(r, " ")
+}
+
+func TestSelect(t *testing.T) {
+	tu := sample(t)
+	tests := []struct {
+		selector string
+		expect   string
+	}{
+		{"FunctionDecl", "FunctionDecl:main FunctionDecl:helper"},
+		{"FunctionDecl[name=main] > CompoundStmt CallExpr[name^=str]", "CallExpr:strlen"},
+		{"CallExpr[name^=str]", "CallExpr:strlen CallExpr:strcpy"},
+		{"FunctionDecl:has(CallExpr[name=malloc])", "FunctionDecl:main"},
+		{"FunctionDecl:not([name=main])", "FunctionDecl:helper"},
+		{"ParmDecl + ParmDecl", "ParmDecl:argv"},
+		{"ParmDecl ~ CompoundStmt", "CompoundStmt:"},
+		{"ParmDecl+CompoundStmt", "CompoundStmt:"},
+		{"CompoundStmt + ParmDecl", ""},
+		{`FunctionDecl:has(> ParmDecl[type$="**"])`, "FunctionDecl:main"},
+		{"FunctionDecl:has(> CallExpr)", ""},
+		{"[type=int]", "ParmDecl:argc CallExpr:strlen VarDecl:x"},
+		{"[type='char *']", "DeclRefExpr:s CallExpr:malloc"},
+		{"CallExpr[token=10]", "CallExpr:malloc"},
+		{"CallExpr[token!=s]", "CallExpr:malloc CallExpr:strcpy"},
+		{"VarDecl, ParmDecl[name=argc]", "ParmDecl:argc VarDecl:x"},
+		{`CallExpr:has(DeclRefExpr[name~="^s$"])`, "CallExpr:strlen"},
+		{"CompoundStmt > *", "CallExpr:strlen CallExpr:malloc ReturnStmt: CallExpr:strcpy"},
+		{"*:has(+ ReturnStmt)", "CallExpr:malloc"},
+		{"*:has(~ VarDecl)", "FunctionDecl:main FunctionDecl:helper"},
+		{"CompoundStmt [name]", "CallExpr:strlen UnexposedExpr:s DeclRefExpr:s CallExpr:malloc CallExpr:strcpy"},
+		{"FunctionDecl[name*=elp] CallExpr", "CallExpr:strcpy"},
+		{"TranslationUnit > VarDecl:not(:has(*))", "VarDecl:x"},
+	}
+	for _, test := range tests {
+		q, err := Compile(test.selector)
+		if err != nil {
+			t.Errorf("%s: %v", test.selector, err)
+			continue
+		}
+		if got := describe(tu, q.Select(tu)); got != test.expect {
+			t.Errorf("%s: got %q, expected %q", test.selector, got, test.expect)
+		}
+	}
+
+	q := MustCompile("CallExpr")
+	if got := describe(tu, q.SelectFrom(tu, 1)); got != "CallExpr:strlen CallExpr:malloc" {
+		t.Errorf("SelectFrom main: got %q", got)
+	}
+	if q.Match(tu, 1) || !q.Match(tu, q.Select(tu)[0]) || q.Match(tu, len(tu.Cursors)) {
+		t.Errorf("Match")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		selector string
+		offset   int
+		msg      string
+	}{
+		{"", 0, "missing selector"},
+		{"Foo", 0, "unknown cursor kind Foo"},
+		{"FunctionDecl[", 13, "missing attribute"},
+		{"FunctionDecl[size=1]", 13, "unknown attribute size, not name, type or token"},
+		{"FunctionDecl[name<1]", 17, "unknown operator"},
+		{"FunctionDecl[name=a b]", 20, "missing ]"},
+		{`[name="x]`, 6, "unterminated string"},
+		{`[name~="("]`, 7, "error parsing regexp: missing closing ): `(`"},
+		{"FunctionDecl:is(x)", 13, "unknown pseudo-class is, not has or not"},
+		{"FunctionDecl:has", 16, "missing ( after :has"},
+		{"FunctionDecl:has(CallExpr", 25, "missing )"},
+		{"FunctionDecl >", 14, "missing selector"},
+		{"FunctionDecl)", 12, `unexpected ')'`},
+		{"FunctionDecl > > CallExpr", 15, `unexpected '>'`},
+	}
+	for _, test := range tests {
+		_, err := Compile(test.selector)
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: got %v, expected a SyntaxError", test.selector, err)
+			continue
+		}
+		if se.Offset != test.offset || se.Msg != test.msg {
+			t.Errorf("%q: got %d %q, expected %d %q", test.selector, se.Offset, se.Msg, test.offset, test.msg)
+		}
+	}
+}
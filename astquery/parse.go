@@ -0,0 +1,306 @@
+package astquery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// SyntaxError reports a selector that does not compile.
+type SyntaxError struct {
+	Selector string
+	Offset   int // in bytes, into Selector
+	Msg      string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("astquery: %s at offset %d of %q", e.Msg, e.Offset, e.Selector)
+}
+
+// kinds maps the names of the cursor kinds to the kinds.
+var kinds = func() map[string]cursorkind.Kind {
+	m := make(map[string]cursorkind.Kind)
+	for i := -1; i < 1000; i++ {
+		if k, err := cursorkind.Validate(i); err == nil {
+			m[k.String()] = k
+		}
+	}
+	return m
+}()
+
+// Combinators relate a compound to the one before it.
+const (
+	descendant = ' '
+	child      = '>'
+	adjacent   = '+' // the next sibling
+	sibling    = '~' // any later sibling
+)
+
+// complexSelector is a chain of compounds, each related to the one before
+// it by its combinator. The combinator of the first compound relates it to
+// the cursor of :has(), and is 0 otherwise.
+type complexSelector struct {
+	compounds   []*compound
+	combinators []byte
+}
+
+// compound is a kind, or any kind, with conditions.
+type compound struct {
+	kind    cursorkind.Kind
+	anyKind bool
+	attrs   []attr
+	has     [][]*complexSelector
+	not     [][]*complexSelector
+}
+
+// attr tests a property of the cursor: its name, the spelling of its type
+// or, for token, the spelling of any of its tokens.
+type attr struct {
+	property string
+	op       string // "" tests that the property is not empty
+	value    string
+	re       *regexp.Regexp // for ~=
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Selector: p.s, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(" \t\n\r", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func isIdentByte(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+func (p *parser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) && isIdentByte(p.s[p.pos], p.pos == start) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// list parses selectors separated by commas, up to the end or, inside a
+// pseudo-class, to the closing parenthesis. relative allows the selectors to
+// start with a combinator, as those of :has() do.
+func (p *parser) list(relative bool) ([]*complexSelector, error) {
+	var r []*complexSelector
+	for {
+		c, err := p.complex(relative)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, c)
+		p.skipSpace()
+		if p.peek() != ',' {
+			return r, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) complex(relative bool) (*complexSelector, error) {
+	c := &complexSelector{}
+	p.skipSpace()
+	var comb byte
+	if relative {
+		comb = descendant
+		if b := p.peek(); b == child || b == adjacent || b == sibling {
+			comb = b
+			p.pos++
+			p.skipSpace()
+		}
+	}
+	for {
+		cp, err := p.compound()
+		if err != nil {
+			return nil, err
+		}
+		c.compounds = append(c.compounds, cp)
+		c.combinators = append(c.combinators, comb)
+
+		space := p.skipSpace()
+		switch b := p.peek(); {
+		case b == child || b == adjacent || b == sibling:
+			comb = b
+			p.pos++
+			p.skipSpace()
+		case b == 0 || b == ',' || b == ')':
+			return c, nil
+		case space:
+			comb = descendant
+		default:
+			return nil, p.errorf("unexpected %q", b)
+		}
+	}
+}
+
+func (p *parser) compound() (*compound, error) {
+	c := &compound{}
+	switch b := p.peek(); {
+	case b == '*':
+		p.pos++
+		c.anyKind = true
+	case isIdentByte(b, true):
+		start := p.pos
+		name := p.ident()
+		k, ok := kinds[name]
+		if !ok {
+			p.pos = start
+			return nil, p.errorf("unknown cursor kind %s", name)
+		}
+		c.kind = k
+	case b == '[' || b == ':':
+		c.anyKind = true
+	default:
+		if b == 0 {
+			return nil, p.errorf("missing selector")
+		}
+		return nil, p.errorf("unexpected %q", b)
+	}
+	for {
+		switch p.peek() {
+		case '[':
+			a, err := p.attr()
+			if err != nil {
+				return nil, err
+			}
+			c.attrs = append(c.attrs, a)
+		case ':':
+			if err := p.pseudo(c); err != nil {
+				return nil, err
+			}
+		default:
+			return c, nil
+		}
+	}
+}
+
+var ops = []string{"!=", "^=", "$=", "*=", "~=", "="}
+
+func (p *parser) attr() (attr, error) {
+	var a attr
+	p.pos++ // [
+	p.skipSpace()
+	start := p.pos
+	a.property = p.ident()
+	switch a.property {
+	case "name", "type", "token":
+	case "":
+		return a, p.errorf("missing attribute")
+	default:
+		p.pos = start
+		return a, p.errorf("unknown attribute %s, not name, type or token", a.property)
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		for _, op := range ops {
+			if strings.HasPrefix(p.s[p.pos:], op) {
+				a.op = op
+				break
+			}
+		}
+		if a.op == "" {
+			return a, p.errorf("unknown operator")
+		}
+		p.pos += len(a.op)
+		p.skipSpace()
+		start := p.pos
+		v, err := p.value()
+		if err != nil {
+			return a, err
+		}
+		a.value = v
+		if a.op == "~=" {
+			if a.re, err = regexp.Compile(v); err != nil {
+				p.pos = start
+				return a, p.errorf("%v", err)
+			}
+		}
+		p.skipSpace()
+	}
+	if p.peek() != ']' {
+		return a, p.errorf("missing ]")
+	}
+	p.pos++
+	return a, nil
+}
+
+// value parses a value quoted with " or ', in which \ escapes the next
+// character, or else running to the next space or ].
+func (p *parser) value() (string, error) {
+	q := p.peek()
+	if q != '"' && q != '\'' {
+		start := p.pos
+		for p.pos < len(p.s) && strings.IndexByte("] \t\n\r", p.s[p.pos]) < 0 {
+			p.pos++
+		}
+		return p.s[start:p.pos], nil
+	}
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == q:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) pseudo(c *compound) error {
+	p.pos++ // :
+	start := p.pos
+	name := p.ident()
+	if name != "has" && name != "not" {
+		p.pos = start
+		return p.errorf("unknown pseudo-class %s, not has or not", name)
+	}
+	if p.peek() != '(' {
+		return p.errorf("missing ( after :%s", name)
+	}
+	p.pos++
+	l, err := p.list(name == "has")
+	if err != nil {
+		return err
+	}
+	if p.peek() != ')' {
+		return p.errorf("missing )")
+	}
+	p.pos++
+	if name == "has" {
+		c.has = append(c.has, l)
+	} else {
+		c.not = append(c.not, l)
+	}
+	return nil
+}
@@ -20,6 +20,36 @@ cd clang
 go install ./...
 go test ./...
 
+cd ../clangprofile
+go test
+
+cd ../clangsession
+go test
+
+cd ../clangcache
+go test
+
+cd ../clangindex
+go test
+
+cd ../clangcallgraph
+go test
+
+cd ../clangincludes
+go test
+
+cd ../clangmacros
+go test
+
+cd ../clangconfigs
+go test
+
+cd ../astquery
+go test
+
+cd ../clangmatch
+go test
+
 cd ../cmd/go-clang-dump
 go build
 go test
@@ -35,3 +65,51 @@ go test -cflags="$CGO_CPPFLAGS"
 cd ../go-clang-globals
 go build
 go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-genenums
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-coverage
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-modules
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-profile
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-pch
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-lsp
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-index
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-callgraph
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-iwyu
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-macros
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-configs
+go build
+go test -cflags="$CGO_CPPFLAGS"
+
+cd ../go-clang-query
+go build
+go test -cflags="$CGO_CPPFLAGS"
@@ -1,4 +1,5 @@
 package clang
 
 // #cgo CFLAGS: -I${SRCDIR}
+// #cgo linux LDFLAGS: -ldl
 import "C"
@@ -1,6 +1,7 @@
 package clang_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/frankreh/go-clang/clang"
@@ -77,3 +78,19 @@ func TestCompilationDatabase(t *testing.T) {
 		}
 	}
 }
+
+func TestCompileCommandParseArgs(t *testing.T) {
+	cmd := clang.CompileCommand{
+		Directory: "/home/user/build",
+		Filename:  "/home/user/build/src/a.cpp",
+		Args:      []string{"g++", "-c", "-DMYMACRO=a", "-o", "a.o", "-MF", "a.d", "-Iinclude", "src/a.cpp"},
+	}
+	source, args := cmd.ParseArgs()
+	if source != "/home/user/build/src/a.cpp" {
+		t.Errorf("source = %q", source)
+	}
+	want := []string{"-working-directory=/home/user/build", "-DMYMACRO=a", "-Iinclude"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+}
@@ -10,6 +10,15 @@ type CompileCommand struct {
 	Args      []string // Args[0] is the compiler executable
 }
 
+// ParseArgs returns the absolute source file name of the compile command and
+// the arguments to pass to clang along with it, those of its ParseOptions.
+// The compiler, the source file and the output options are dropped, and the
+// working directory of the command is passed on.
+func (cmd CompileCommand) ParseArgs() (string, []string) {
+	source, o := cmd.ParseOptions()
+	return source, o.args()
+}
+
 func newCompileCommand(c C.CXCompileCommand) CompileCommand {
 	var r CompileCommand
 
@@ -1,6 +1,26 @@
+// For dladdr with glibc.
+#define _GNU_SOURCE
+
 #include "_cgo_export.h"
 #include "go-clang.h"
 
+#include <dlfcn.h>
+
 unsigned go_clang_visit_children(CXCursor c, void *opaque) {
 	return clang_visitChildren(c, (CXCursorVisitor)&GoClangCursorVisitor, opaque);
 }
+
+void go_clang_get_inclusions(CXTranslationUnit tu, uintptr_t index) {
+	clang_getInclusions(tu, (CXInclusionVisitor)&GoClangInclusionVisitor, (CXClientData)index);
+}
+
+// go_clang_library_path returns the path of the libclang shared library the
+// program is linked with, or "" when it is not known, as when it is linked
+// statically.
+const char *go_clang_library_path(void) {
+	Dl_info info;
+	if (dladdr((void *)&clang_getClangVersion, &info) == 0 || info.dli_fname == NULL) {
+		return "";
+	}
+	return info.dli_fname;
+}
@@ -1,10 +1,13 @@
 #ifndef GO_CLANG
 #define GO_CLANG
 
+#include <stdint.h>
 #include <stdlib.h>
 
 #include "clang-c/Index.h"
 
 unsigned go_clang_visit_children(CXCursor c, void *opaque);
+void go_clang_get_inclusions(CXTranslationUnit tu, uintptr_t index);
+const char *go_clang_library_path(void);
 
 #endif
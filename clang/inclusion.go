@@ -0,0 +1,97 @@
+package clang
+
+// #include "go-clang.h"
+import "C"
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// InclusionVisitor is the callback function type passed to GetInclusions.
+/**
+ * Visitor invoked for each file in a translation unit
+ *        (used with clang_getInclusions()).
+ *
+ * This visitor function will be invoked by clang_getInclusions() for each
+ * file included (either at the top-level or by \#include directives) within
+ * a translation unit.  The first argument is the file being included, and
+ * the second and third arguments provide the inclusion stack.  The
+ * array is sorted in order of immediate inclusion.  For example,
+ * the first element refers to the location that included 'included_file'.
+ */
+type InclusionVisitor func(includedFile File, inclusionStack []SourceLocation)
+
+var inclusionVisitors = struct {
+	sync.RWMutex
+	index uintptr
+	funcs map[uintptr]InclusionVisitor
+}{
+	funcs: make(map[uintptr]InclusionVisitor),
+}
+
+// GetInclusions visits the set of preprocessor inclusions in a translation
+// unit. The visitor function is called with the provided data for every
+// included file. This does not include headers included by the PCH file
+// (unless one is inspecting the inclusions in the PCH file itself).
+func (tu TranslationUnit) GetInclusions(visitor InclusionVisitor) {
+	inclusionVisitors.Lock()
+	inclusionVisitors.index++
+	for inclusionVisitors.funcs[inclusionVisitors.index] != nil {
+		inclusionVisitors.index++
+	}
+	index := inclusionVisitors.index
+	inclusionVisitors.funcs[index] = visitor
+	inclusionVisitors.Unlock()
+
+	defer func() {
+		inclusionVisitors.Lock()
+		delete(inclusionVisitors.funcs, index)
+		inclusionVisitors.Unlock()
+	}()
+
+	C.go_clang_get_inclusions(tu.c, C.uintptr_t(index))
+}
+
+// GoClangInclusionVisitor calls the inclusion visitor
+//
+//export GoClangInclusionVisitor
+func GoClangInclusionVisitor(includedFile C.CXFile, inclusionStack *C.CXSourceLocation, includeLen C.uint, opaque unsafe.Pointer) {
+	inclusionVisitors.RLock()
+	fn := inclusionVisitors.funcs[uintptr(opaque)]
+	inclusionVisitors.RUnlock()
+
+	if fn == nil {
+		return
+	}
+
+	var cs []C.CXSourceLocation
+	gos_cs := (*reflect.SliceHeader)(unsafe.Pointer(&cs))
+	gos_cs.Cap = int(includeLen)
+	gos_cs.Len = int(includeLen)
+	gos_cs.Data = uintptr(unsafe.Pointer(inclusionStack))
+
+	stack := make([]SourceLocation, len(cs))
+	for i := range cs {
+		stack[i] = SourceLocation{cs[i]}
+	}
+
+	fn(File{includedFile}, stack)
+}
+
+// Inclusion is a file of a translation unit, with the stack of locations
+// that included it, the innermost first. The stack of the main file is empty.
+type Inclusion struct {
+	File  File
+	Stack []SourceLocation
+}
+
+// Inclusions returns the files of the translation unit, the main file
+// included, in the order they were entered.
+func (tu TranslationUnit) Inclusions() []Inclusion {
+	var r []Inclusion
+	tu.GetInclusions(func(includedFile File, inclusionStack []SourceLocation) {
+		r = append(r, Inclusion{includedFile, inclusionStack})
+	})
+	return r
+}
@@ -7,6 +7,12 @@ type Module struct {
 	c C.CXModule
 }
 
+// IsNull returns true when there is no module, as for the parent of a
+// top-level module or a file that is not part of any module.
+func (m Module) IsNull() bool {
+	return m.c == nil
+}
+
 /*
 	Parameter Module a module object.
 
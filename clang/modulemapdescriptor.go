@@ -46,7 +46,7 @@ func (mmd ModuleMapDescriptor) SetUmbrellaHeader(name string) error {
 */
 func (mmd ModuleMapDescriptor) WriteToBuffer(options uint32) (string, uint32, error) {
 	var outBufferPtr *C.char
-	defer C.clang_free(unsafe.Pointer(outBufferPtr))
+	defer func() { C.clang_free(unsafe.Pointer(outBufferPtr)) }()
 	var outBufferSize C.uint
 
 	o := convertErrorCode(C.clang_ModuleMapDescriptor_writeToBuffer(mmd.c, C.uint(options), &outBufferPtr, &outBufferSize))
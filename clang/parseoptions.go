@@ -0,0 +1,330 @@
+package clang
+
+// #include "go-clang.h"
+import "C"
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseOptions describes the compilation of a translation unit, to be turned
+// into the arguments of ParseTranslationUnit2FullArgv by Args, or parsed
+// directly with Index.ParseWithOptions.
+type ParseOptions struct {
+	Language           string        // -x, e.g. "c", "c++" or "objective-c"
+	Standard           string        // -std, e.g. "c11" or "gnu++17"
+	Target             string        // --target, e.g. "x86_64-pc-linux-gnu"
+	IncludePaths       []string      // -I
+	SystemIncludePaths []string      // -isystem
+	Macros             []MacroOption // -D and -U, in the order given
+	Warnings           []string      // -W, e.g. "all" or "no-unused-variable", in the order given
+	ResourceDir        string        // -resource-dir, see ResourceDir
+	WorkingDirectory   string        // -working-directory, relative paths are resolved from it
+	Extra              []string      // any other arguments, passed last
+	Flags              TranslationUnit_Flags
+}
+
+// MacroOption is a -D or a -U option. Like the warnings, a later one overrides
+// an earlier one for the same macro, so their order is kept.
+type MacroOption struct {
+	Undefine bool   // -U rather than -D
+	Macro    string // NAME, or NAME=VALUE for -D
+}
+
+// Arg returns the option as a clang argument.
+func (m MacroOption) Arg() string {
+	if m.Undefine {
+		return "-U" + m.Macro
+	}
+	return "-D" + m.Macro
+}
+
+// ParseOptionsErr lists the problems Validate finds in a ParseOptions.
+type ParseOptionsErr []string
+
+func (e ParseOptionsErr) Error() string {
+	return "invalid parse options: " + strings.Join(e, "; ")
+}
+
+// languages maps the values of -x to their family, for checking the
+// standard.
+var languages = map[string]string{
+	"c":                    "c",
+	"c-header":             "c",
+	"cpp-output":           "c",
+	"objective-c":          "c",
+	"objective-c-header":   "c",
+	"c++":                  "c++",
+	"c++-header":           "c++",
+	"c++-cpp-output":       "c++",
+	"objective-c++":        "c++",
+	"objective-c++-header": "c++",
+	"cuda":                 "c++",
+	"hip":                  "c++",
+	"cl":                   "cl",
+}
+
+// standardFamily returns the language family of a -std value, or "" when it
+// is not known.
+func standardFamily(std string) string {
+	s := strings.ToLower(std)
+	switch {
+	case strings.HasPrefix(s, "c++"), strings.HasPrefix(s, "gnu++"):
+		return "c++"
+	case strings.HasPrefix(s, "cl"):
+		return "cl"
+	case strings.HasPrefix(s, "c"), strings.HasPrefix(s, "gnu"), strings.HasPrefix(s, "iso9899"):
+		return "c"
+	}
+	return ""
+}
+
+// macroName returns the name of the macro of a -D or -U value.
+func macroName(d string) string {
+	if i := strings.IndexAny(d, "=("); i >= 0 {
+		return d[:i]
+	}
+	return d
+}
+
+// Validate checks the options for missing values and for a standard of
+// another language. A macro defined and undefined, or a warning enabled and
+// disabled, is no contradiction: the later option wins, as it does for
+// clang. The error is a ParseOptionsErr.
+func (o *ParseOptions) Validate() error {
+	var problems ParseOptionsErr
+	family := ""
+	if o.Language != "" {
+		var ok bool
+		if family, ok = languages[o.Language]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown language %q", o.Language))
+		}
+	}
+	if o.Standard != "" {
+		std := standardFamily(o.Standard)
+		switch {
+		case std == "":
+			problems = append(problems, fmt.Sprintf("unknown standard %q", o.Standard))
+		case family != "" && std != family:
+			problems = append(problems, fmt.Sprintf("standard %s is not for language %s", o.Standard, o.Language))
+		}
+	}
+	if strings.ContainsAny(o.Target, " \t") {
+		problems = append(problems, fmt.Sprintf("target %q contains spaces", o.Target))
+	}
+	for _, p := range o.IncludePaths {
+		if p == "" {
+			problems = append(problems, "empty include path")
+		}
+	}
+	for _, p := range o.SystemIncludePaths {
+		if p == "" {
+			problems = append(problems, "empty system include path")
+		}
+	}
+
+	for _, m := range o.Macros {
+		if macroName(m.Macro) == "" {
+			problems = append(problems, fmt.Sprintf("%s has no macro name", m.Arg()))
+		}
+	}
+	for _, w := range o.Warnings {
+		if w == "" {
+			problems = append(problems, "empty warning")
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// Args validates the options and returns the arguments they stand for,
+// without the source file and the leading compiler name.
+func (o *ParseOptions) Args() ([]string, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return o.args(), nil
+}
+
+// args returns the arguments the options stand for.
+func (o *ParseOptions) args() []string {
+	var args []string
+	if o.WorkingDirectory != "" {
+		args = append(args, "-working-directory="+o.WorkingDirectory)
+	}
+	if o.Language != "" {
+		args = append(args, "-x", o.Language)
+	}
+	if o.Standard != "" {
+		args = append(args, "-std="+o.Standard)
+	}
+	if o.Target != "" {
+		args = append(args, "--target="+o.Target)
+	}
+	if o.ResourceDir != "" {
+		args = append(args, "-resource-dir", o.ResourceDir)
+	}
+	for _, m := range o.Macros {
+		args = append(args, m.Arg())
+	}
+	for _, p := range o.IncludePaths {
+		args = append(args, "-I"+p)
+	}
+	for _, p := range o.SystemIncludePaths {
+		args = append(args, "-isystem", p)
+	}
+	for _, w := range o.Warnings {
+		args = append(args, "-W"+w)
+	}
+	return append(args, o.Extra...)
+}
+
+// ParseOptions returns the source file of the compile command, made absolute,
+// and the options it stands for. Output and dependency file arguments are
+// dropped, and the arguments without a field of their own end up in Extra.
+func (cmd CompileCommand) ParseOptions() (string, *ParseOptions) {
+	source := cmd.Filename
+	if !filepath.IsAbs(source) && cmd.Directory != "" {
+		source = filepath.Join(cmd.Directory, source)
+	}
+	o := &ParseOptions{WorkingDirectory: cmd.Directory}
+
+	for i := 1; i < len(cmd.Args); i++ {
+		arg := cmd.Args[i]
+		// value returns the value of the option arg starts with, joined to it
+		// or in the next argument.
+		value := func(option string) string {
+			if v := arg[len(option):]; v != "" {
+				return v
+			}
+			if i+1 < len(cmd.Args) {
+				i++
+				return cmd.Args[i]
+			}
+			return ""
+		}
+
+		switch {
+		case arg == "-c", arg == cmd.Filename, cmd.Directory != "" && filepath.Join(cmd.Directory, arg) == source:
+		case arg == "-o", arg == "-MF", arg == "-MT", arg == "-MQ":
+			i++
+		case strings.HasPrefix(arg, "-std="):
+			o.Standard = arg[len("-std="):]
+		case strings.HasPrefix(arg, "-isystem"):
+			o.SystemIncludePaths = append(o.SystemIncludePaths, value("-isystem"))
+		case strings.HasPrefix(arg, "-I"):
+			o.IncludePaths = append(o.IncludePaths, value("-I"))
+		case strings.HasPrefix(arg, "-D"):
+			o.Macros = append(o.Macros, MacroOption{Macro: value("-D")})
+		case strings.HasPrefix(arg, "-U"):
+			o.Macros = append(o.Macros, MacroOption{Undefine: true, Macro: value("-U")})
+		case strings.HasPrefix(arg, "--target="):
+			o.Target = arg[len("--target="):]
+		case arg == "-target", arg == "--target":
+			o.Target = value(arg)
+		case strings.HasPrefix(arg, "-resource-dir="):
+			o.ResourceDir = arg[len("-resource-dir="):]
+		case arg == "-resource-dir":
+			o.ResourceDir = value(arg)
+		case strings.HasPrefix(arg, "-x"):
+			o.Language = value("-x")
+		case strings.HasPrefix(arg, "-W") && len(arg) > 2 && !strings.HasPrefix(arg, "-Wl,") &&
+			!strings.HasPrefix(arg, "-Wa,") && !strings.HasPrefix(arg, "-Wp,"):
+			o.Warnings = append(o.Warnings, arg[len("-W"):])
+		default:
+			o.Extra = append(o.Extra, arg)
+		}
+	}
+	return source, o
+}
+
+// ParseWithOptions parses source with the arguments of the options, and
+// their flags. The compiler name given to libclang is "clang".
+func (i Index) ParseWithOptions(source string, o *ParseOptions, unsavedFiles []UnsavedFile) (TranslationUnit, error) {
+	args, err := o.Args()
+	if err != nil {
+		return TranslationUnit{}, err
+	}
+	var tu TranslationUnit
+	err = i.ParseTranslationUnit2FullArgv(source, append([]string{"clang"}, args...), unsavedFiles, o.Flags, &tu)
+	return tu, err
+}
+
+// ResourceDir returns the resource directory of the libclang the program is
+// linked with, holding the headers of the compiler such as stddef.h. It is
+// found next to the library, in lib/clang/<version>, the version being the
+// full one or the major one only, as used by recent releases.
+func ResourceDir() (string, error) {
+	lib := C.GoString(C.go_clang_library_path())
+	if lib == "" {
+		return "", fmt.Errorf("the path of libclang is not known")
+	}
+	if real, err := filepath.EvalSymlinks(lib); err == nil {
+		lib = real
+	}
+	return findResourceDir(filepath.Dir(lib), GetClangVersion(), func(dir string) bool {
+		_, err := os.Stat(filepath.Join(dir, "include", "stddef.h"))
+		return err == nil
+	})
+}
+
+var versionRE = regexp.MustCompile(`version (\d+)\.(\d+)\.(\d+)`)
+
+// findResourceDir looks for the resource directory of the libclang in
+// libDir, whose version string is version. isResourceDir tells whether a
+// directory holds the resources.
+func findResourceDir(libDir, version string, isResourceDir func(string) bool) (string, error) {
+	base := filepath.Join(libDir, "clang")
+	if m := versionRE.FindStringSubmatch(version); m != nil {
+		for _, v := range []string{m[1] + "." + m[2] + "." + m[3], m[1]} {
+			if dir := filepath.Join(base, v); isResourceDir(dir) {
+				return dir, nil
+			}
+		}
+	}
+
+	// Fall back on the most recent version found.
+	infos, err := ioutil.ReadDir(base)
+	if err != nil {
+		return "", fmt.Errorf("no resource directory for %s: %v", version, err)
+	}
+	var candidates []string
+	for _, fi := range infos {
+		if dir := filepath.Join(base, fi.Name()); isResourceDir(dir) {
+			candidates = append(candidates, fi.Name())
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no resource directory for %s in %s", version, base)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return versionLess(candidates[i], candidates[j]) })
+	return filepath.Join(base, candidates[len(candidates)-1]), nil
+}
+
+// versionLess compares dotted versions numerically.
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errx := strconv.Atoi(as[i])
+		y, erry := strconv.Atoi(bs[i])
+		if errx != nil || erry != nil {
+			if as[i] != bs[i] {
+				return as[i] < bs[i]
+			}
+			continue
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
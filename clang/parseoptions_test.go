@@ -0,0 +1,136 @@
+package clang_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+func TestParseOptionsArgs(t *testing.T) {
+	o := &clang.ParseOptions{
+		Language:           "c",
+		Standard:           "c11",
+		Target:             "x86_64-pc-linux-gnu",
+		IncludePaths:       []string{"include"},
+		SystemIncludePaths: []string{"/opt/include"},
+		Macros:             []clang.MacroOption{{Undefine: true, Macro: "DEBUG"}, {Macro: "LEVEL=2"}, {Macro: "DEBUG"}},
+		Warnings:           []string{"all", "no-unused"},
+		WorkingDirectory:   "/src",
+		Extra:              []string{"-fno-builtin"},
+	}
+	args, err := o.Args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-working-directory=/src", "-x", "c", "-std=c11", "--target=x86_64-pc-linux-gnu",
+		"-UDEBUG", "-DLEVEL=2", "-DDEBUG", "-Iinclude", "-isystem", "/opt/include",
+		"-Wall", "-Wno-unused", "-fno-builtin",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Args() = %q, want %q", args, want)
+	}
+}
+
+func TestParseOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		o        clang.ParseOptions
+		problems int
+	}{
+		{clang.ParseOptions{}, 0},
+		{clang.ParseOptions{Language: "c++", Standard: "gnu++17"}, 0},
+		{clang.ParseOptions{Language: "c++", Standard: "c99"}, 1},
+		{clang.ParseOptions{Language: "pascal"}, 1},
+		{clang.ParseOptions{Standard: "f77"}, 1},
+		// The later of the options for a macro or a warning wins.
+		{clang.ParseOptions{Macros: []clang.MacroOption{{Macro: "A=1"}, {Macro: "B"}, {Undefine: true, Macro: "A"}}}, 0},
+		{clang.ParseOptions{Warnings: []string{"shadow", "no-shadow", "no-unused"}}, 0},
+		{clang.ParseOptions{Macros: []clang.MacroOption{{Macro: "=1"}, {Undefine: true}}, IncludePaths: []string{""}}, 3},
+		{clang.ParseOptions{Warnings: []string{""}}, 1},
+		{clang.ParseOptions{Target: "x86 64"}, 1},
+	} {
+		err := tc.o.Validate()
+		problems, _ := err.(clang.ParseOptionsErr)
+		if len(problems) != tc.problems || (err == nil) != (tc.problems == 0) {
+			t.Errorf("Validate(%+v) = %v, want %d problems", tc.o, err, tc.problems)
+		}
+		if _, err := tc.o.Args(); (err == nil) != (tc.problems == 0) {
+			t.Errorf("Args() of %+v gives error %v", tc.o, err)
+		}
+	}
+}
+
+func TestCompileCommandParseOptions(t *testing.T) {
+	cmd := clang.CompileCommand{
+		Directory: "/src",
+		Filename:  "main.c",
+		Args: []string{"cc", "-c", "-o", "main.o", "-std=c99", "-Iinclude", "-I", "gen", "-isystem", "/opt/include",
+			"-UNDEBUG", "-DDEBUG", "-D", "LEVEL=2", "-DNDEBUG", "-target", "arm-none-eabi", "-Wall", "-Wl,--gc-sections",
+			"-xc", "-O2", "main.c"},
+	}
+	source, o := cmd.ParseOptions()
+	if source != "/src/main.c" {
+		t.Errorf("source %s", source)
+	}
+	want := &clang.ParseOptions{
+		Language:           "c",
+		Standard:           "c99",
+		Target:             "arm-none-eabi",
+		IncludePaths:       []string{"include", "gen"},
+		SystemIncludePaths: []string{"/opt/include"},
+		Macros: []clang.MacroOption{
+			{Undefine: true, Macro: "NDEBUG"},
+			{Macro: "DEBUG"},
+			{Macro: "LEVEL=2"},
+			{Macro: "NDEBUG"},
+		},
+		Warnings:         []string{"all"},
+		WorkingDirectory: "/src",
+		Extra:            []string{"-Wl,--gc-sections", "-O2"},
+	}
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("ParseOptions() = %+v, want %+v", o, want)
+	}
+
+	// The arguments to parse with keep the macros in order.
+	_, args := cmd.ParseArgs()
+	wantArgs := []string{"-working-directory=/src", "-x", "c", "-std=c99", "--target=arm-none-eabi",
+		"-UNDEBUG", "-DDEBUG", "-DLEVEL=2", "-DNDEBUG", "-Iinclude", "-Igen", "-isystem", "/opt/include",
+		"-Wall", "-Wl,--gc-sections", "-O2"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("ParseArgs() = %q, want %q", args, wantArgs)
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	o := &clang.ParseOptions{Language: "c", Standard: "c99", Macros: []clang.MacroOption{{Macro: "GREETING=1"}}}
+	tu, err := idx.ParseWithOptions("../testdata/hello.c", o, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tu.Dispose()
+	if filepath.Base(tu.Spelling()) != "hello.c" {
+		t.Errorf("parsed %s", tu.Spelling())
+	}
+
+	o.Standard = "c++17"
+	if _, err := idx.ParseWithOptions("../testdata/hello.c", o, nil); err == nil {
+		t.Error("no error parsing with contradictory options")
+	}
+}
+
+func TestResourceDir(t *testing.T) {
+	dir, err := clang.ResourceDir()
+	if err != nil {
+		t.Skip("no resource directory:", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "include", "stddef.h")); err != nil {
+		t.Errorf("resource directory %s: %v", dir, err)
+	}
+}
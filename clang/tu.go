@@ -327,6 +327,17 @@ func (tu TranslationUnit) Module_getTopLevelHeader(module Module, index uint32)
 	return File{C.clang_Module_getTopLevelHeader(tu.c, module.c, C.uint(index))}
 }
 
+// ModuleTopLevelHeaders returns all the top level headers associated with the
+// module.
+func (tu TranslationUnit) ModuleTopLevelHeaders(module Module) []File {
+	n := tu.Module_getNumTopLevelHeaders(module)
+	headers := make([]File, 0, n)
+	for i := uint32(0); i < n; i++ {
+		headers = append(headers, tu.Module_getTopLevelHeader(module, i))
+	}
+	return headers
+}
+
 /*
 	Determine the spelling of the given token.
 
@@ -2,6 +2,7 @@ package clang
 
 // #include "go-clang.h"
 import "C"
+import "unsafe"
 
 /*
 	Provides the contents of a file that has not yet been saved to disk.
@@ -42,3 +43,14 @@ func (uf UnsavedFile) Contents() string {
 func (uf UnsavedFile) Length() uint64 {
 	return uint64(uf.c.Length)
 }
+
+/*
+	Free the file name and contents allocated by NewUnsavedFile.
+
+	libclang copies unsaved files when it needs them, so they can be disposed
+	of once the call they are passed to returns.
+*/
+func (uf UnsavedFile) Dispose() {
+	C.free(unsafe.Pointer(uf.c.Filename))
+	C.free(unsafe.Pointer(uf.c.Contents))
+}
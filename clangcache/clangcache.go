@@ -0,0 +1,241 @@
+// Package clangcache caches parsed translation units on disk, so that
+// unchanged sources are loaded instead of parsed again.
+//
+// An entry is found by the source file name, the arguments and the parse
+// options. It records every file of the translation unit, the source and its
+// include closure, with the modification time libclang saw and a hash of the
+// contents it parsed. The translation unit is saved with SaveTranslationUnit
+// under a key hashing the arguments and all those contents, and loaded back
+// with Index.TranslationUnit2.
+//
+// An entry is used as long as none of its files changed. libclang checks the
+// modification times of the files of a saved translation unit when loading
+// it, so a file touched without changing its contents drops the entry as
+// well; the translation unit parsed again is stored under the same key. The
+// entry is also parsed again whenever it fails to load.
+package clangcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+// Cache is a directory of saved translation units.
+type Cache struct {
+	Dir string
+
+	// Options used to parse the translation units that are not cached.
+	Options clang.TranslationUnit_Flags
+
+	// Counts of the Parse calls that loaded a cached translation unit and of
+	// those that had to parse, updated atomically.
+	hits   int64
+	misses int64
+}
+
+// entry is the manifest of a cached translation unit, stored as JSON.
+type entry struct {
+	Source  string   `json:"source"`
+	Args    []string `json:"args"`
+	Options uint32   `json:"options"`
+	Key     string   `json:"key"` // names the saved translation unit
+	Files   []stamp  `json:"files"`
+}
+
+// stamp identifies the version of a file a translation unit was parsed from.
+type stamp struct {
+	Name string `json:"name"`
+	Time int64  `json:"time"` // seconds since the epoch, as File.Time
+	Hash string `json:"hash"` // SHA-256 of the contents
+}
+
+// New returns the cache stored in dir, creating the directory if needed.
+func New(dir string, options clang.TranslationUnit_Flags) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir, Options: options}, nil
+}
+
+// Parse returns the translation unit of source, loaded from the cache when
+// none of its files changed, or else parsed and stored in the cache. The
+// boolean is true when it was loaded.
+//
+// Translation units with errors are not stored, as SaveTranslationUnit
+// refuses them. When storing fails otherwise, the parsed translation unit is
+// still returned, along with the error.
+func (c *Cache) Parse(idx clang.Index, source string, args []string) (clang.TranslationUnit, bool, error) {
+	if tu, ok := c.Load(idx, source, args); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return tu, true, nil
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	var tu clang.TranslationUnit
+	if err := idx.ParseTranslationUnit2(source, args, nil, c.Options, &tu); err != nil {
+		return clang.TranslationUnit{}, false, fmt.Errorf("parsing %s: %v", source, err)
+	}
+	if err := c.Store(tu, source, args); err != nil && err != clang.TranslationErr {
+		return tu, false, err
+	}
+	return tu, false, nil
+}
+
+// Stats returns the number of Parse calls that loaded a cached translation
+// unit, and of those that had to parse. Parse may be called concurrently.
+func (c *Cache) Stats() (hits, misses int) {
+	return int(atomic.LoadInt64(&c.hits)), int(atomic.LoadInt64(&c.misses))
+}
+
+// Load returns the cached translation unit of source, or false when there is
+// none or one of its files changed. Outdated entries are removed.
+func (c *Cache) Load(idx clang.Index, source string, args []string) (clang.TranslationUnit, bool) {
+	name := c.entryName(source, args)
+	e, err := readEntry(name)
+	if err != nil {
+		return clang.TranslationUnit{}, false
+	}
+
+	if !check(e) {
+		c.remove(name, e)
+		return clang.TranslationUnit{}, false
+	}
+
+	var tu clang.TranslationUnit
+	if err := idx.TranslationUnit2(c.astName(e.Key), &tu); err != nil {
+		c.remove(name, e)
+		return clang.TranslationUnit{}, false
+	}
+	return tu, true
+}
+
+// Store saves the translation unit parsed from source with args, replacing
+// any previous entry.
+func (c *Cache) Store(tu clang.TranslationUnit, source string, args []string) error {
+	e := &entry{
+		Source:  source,
+		Args:    args,
+		Options: uint32(c.Options),
+	}
+
+	key := sha256.New()
+	writeKey(key, source, args, c.Options)
+	seen := make(map[string]bool)
+	for _, inc := range tu.Inclusions() {
+		name := inc.File.Name()
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		sum := sha256.Sum256(tu.FileContents(inc.File))
+		s := stamp{
+			Name: name,
+			Time: inc.File.Time().Unix(),
+			Hash: hex.EncodeToString(sum[:]),
+		}
+		e.Files = append(e.Files, s)
+		fmt.Fprintf(key, "%s\x00%s\x00", s.Name, s.Hash)
+	}
+	e.Key = hex.EncodeToString(key.Sum(nil))
+
+	name := c.entryName(source, args)
+	if old, err := readEntry(name); err == nil && old.Key != e.Key {
+		os.Remove(c.astName(old.Key))
+	}
+
+	ast := c.astName(e.Key)
+	tmp := ast + ".tmp"
+	if err := tu.SaveTranslationUnit(tmp, tu.DefaultSaveOptions()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, ast); err != nil {
+		return err
+	}
+	return writeEntry(name, e)
+}
+
+// Invalidate removes the entry of source parsed with args.
+func (c *Cache) Invalidate(source string, args []string) error {
+	name := c.entryName(source, args)
+	e, err := readEntry(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.remove(name, e)
+}
+
+func (c *Cache) remove(name string, e *entry) error {
+	os.Remove(c.astName(e.Key))
+	return os.Remove(name)
+}
+
+// entryName returns the file name of the manifest of source parsed with args.
+func (c *Cache) entryName(source string, args []string) string {
+	h := sha256.New()
+	writeKey(h, source, args, c.Options)
+	return filepath.Join(c.Dir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+func (c *Cache) astName(key string) string {
+	return filepath.Join(c.Dir, key+".ast")
+}
+
+func writeKey(w io.Writer, source string, args []string, options clang.TranslationUnit_Flags) {
+	fmt.Fprintf(w, "%s\x00%d\x00", source, options)
+	for _, arg := range args {
+		fmt.Fprintf(w, "%s\x00", arg)
+	}
+	w.Write([]byte{0})
+}
+
+// check reports whether the files of e are unchanged: they have the
+// modification times recorded, which libclang requires to load the saved
+// translation unit.
+func check(e *entry) bool {
+	for _, s := range e.Files {
+		fi, err := os.Stat(s.Name)
+		if err != nil || fi.ModTime().Unix() != s.Time {
+			return false
+		}
+	}
+	return true
+}
+
+func readEntry(name string) (*entry, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return e, nil
+}
+
+// writeEntry writes e atomically, so concurrent readers never see a partial
+// manifest.
+func writeEntry(name string, e *entry) error {
+	b, err := json.MarshalIndent(e, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
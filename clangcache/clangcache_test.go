@@ -0,0 +1,119 @@
+package clangcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+func write(t *testing.T, name, contents string, mtime time.Time) {
+	t.Helper()
+	if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.h")
+	mtime := time.Unix(1500000000, 0)
+	write(t, name, "int a;\n", mtime)
+	sum := sha256.Sum256([]byte("int a;\n"))
+	e := &entry{Files: []stamp{{Name: name, Time: mtime.Unix(), Hash: hex.EncodeToString(sum[:])}}}
+
+	if !check(e) {
+		t.Error("unchanged file not valid")
+	}
+
+	// libclang would not load the translation unit of a touched file.
+	write(t, name, "int a;\n", mtime.Add(time.Hour))
+	if check(e) {
+		t.Error("touched file still valid")
+	}
+
+	os.Remove(name)
+	if check(e) {
+		t.Error("removed file still valid")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "a.c")
+	header := filepath.Join(dir, "a.h")
+	mtime := time.Unix(1500000000, 0)
+	write(t, source, "#include \"a.h\"\nint f(void) { return A; }\n", mtime)
+	write(t, header, "#define A 1\n", mtime)
+
+	c, err := New(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	parse := func() bool {
+		t.Helper()
+		tu, hit, err := c.Parse(idx, source, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tu.Dispose()
+		found := false
+		tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+			found = found || cursor.Spelling() == "f"
+			return clang.ChildVisit_Continue
+		})
+		if !found {
+			t.Error("function f not found")
+		}
+		return hit
+	}
+	expect := func(wantHit bool) {
+		t.Helper()
+		if hit := parse(); hit != wantHit {
+			t.Errorf("hit = %v, want %v", hit, wantHit)
+		}
+	}
+
+	expect(false)
+	expect(true)
+
+	// Touching the header drops the entry, the same translation unit being
+	// stored again.
+	write(t, header, "#define A 1\n", mtime.Add(time.Hour))
+	expect(false)
+	expect(true)
+
+	// Changing it does not.
+	write(t, header, "#define A 2\n", mtime.Add(2*time.Hour))
+	expect(false)
+	expect(true)
+
+	// Other arguments are another entry.
+	if _, ok := c.Load(idx, source, []string{"-DB"}); ok {
+		t.Error("entry found for other arguments")
+	}
+
+	if err := c.Invalidate(source, nil); err != nil {
+		t.Fatal(err)
+	}
+	expect(false)
+
+	if hits, misses := c.Stats(); hits != 3 || misses != 4 {
+		t.Errorf("%d hits and %d misses, want 3 and 4", hits, misses)
+	}
+	asts, _ := filepath.Glob(filepath.Join(c.Dir, "*.ast"))
+	if len(asts) != 1 {
+		t.Errorf("%d saved translation units, want 1", len(asts))
+	}
+}
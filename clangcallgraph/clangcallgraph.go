@@ -0,0 +1,261 @@
+// Package clangcallgraph extracts the call graph of a set of translation
+// units.
+//
+// Every CallExpr in the body of a function definition is an edge from that
+// function. The callee is resolved with Cursor.Referenced. Calls through
+// function pointers, whose callee is only known at run time, are kept as
+// unresolved edges labelled with the expression called. Functions are
+// identified by USR, so the graphs of several translation units merge into
+// one.
+package clangcallgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Function is a node of the graph.
+type Function struct {
+	USR     string `json:"usr"`
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Line    uint32 `json:"line,omitempty"`
+	Defined bool   `json:"defined"` // whether the body of the function was seen
+}
+
+// Call is an edge of the graph.
+type Call struct {
+	Caller     string `json:"caller"`               // USR
+	Callee     string `json:"callee,omitempty"`     // USR, empty for an unresolved call
+	Unresolved string `json:"unresolved,omitempty"` // the expression called, for an unresolved call
+	File       string `json:"file"`
+	Line       uint32 `json:"line"`
+	Column     uint32 `json:"column"`
+}
+
+// Graph is a call graph.
+type Graph struct {
+	Functions map[string]*Function // keyed by USR
+	Calls     []Call               // sorted by caller and location
+
+	seen map[Call]bool
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{Functions: make(map[string]*Function)}
+}
+
+// Build parses every compile command, with args added, and returns the
+// merged call graph along with the problems met.
+func Build(cmds []clang.CompileCommand, args []string) (*Graph, []string) {
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	g := New()
+	var problems []string
+	for _, cmd := range cmds {
+		source, cmdArgs := cmd.ParseArgs()
+		tu := idx.ParseTranslationUnit(source, append(cmdArgs, args...), nil, 0)
+		if !tu.IsValid() {
+			problems = append(problems, "parsing "+source+" failed")
+			continue
+		}
+		for _, d := range tu.Diagnostics() {
+			if d.Severity() >= clang.Diagnostic_Error {
+				problems = append(problems, d.Spelling())
+			}
+		}
+		g.Add(tu)
+		tu.Dispose()
+	}
+	g.Sort()
+	return g, problems
+}
+
+// isFunction reports whether cursors of kind k have a body holding calls.
+func isFunction(k cursorkind.Kind) bool {
+	switch k {
+	case cursorkind.FunctionDecl, cursorkind.CXXMethod, cursorkind.Constructor, cursorkind.Destructor,
+		cursorkind.ConversionFunction, cursorkind.FunctionTemplate, cursorkind.ObjCInstanceMethodDecl,
+		cursorkind.ObjCClassMethodDecl:
+		return true
+	}
+	return false
+}
+
+// Add adds the functions defined by the translation unit and their calls.
+// Sort must be called once all translation units are added.
+func (g *Graph) Add(tu clang.TranslationUnit) {
+	if g.seen == nil {
+		g.seen = make(map[Call]bool)
+		for _, c := range g.Calls {
+			g.seen[c] = true
+		}
+	}
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if !isFunction(cursor.Kind()) {
+			// Look for the functions of namespaces, classes and the like.
+			return clang.ChildVisit_Recurse
+		}
+		if cursor.IsCursorDefinition() {
+			caller := g.function(cursor)
+			caller.Defined = true
+			g.addCalls(tu, cursor, caller.USR)
+		}
+		return clang.ChildVisit_Continue
+	})
+}
+
+// function returns the node of the function declared by c, adding it if
+// needed. The location of the definition is preferred.
+func (g *Graph) function(c clang.Cursor) *Function {
+	usr := c.USR()
+	f, ok := g.Functions[usr]
+	if !ok {
+		f = &Function{USR: usr, Name: c.Spelling()}
+		g.Functions[usr] = f
+	}
+	if def := c.Definition(); !def.IsNull() {
+		c = def
+	}
+	if f.File == "" || c.IsCursorDefinition() {
+		file, line, _, _ := c.Location().FileLocation()
+		f.File, f.Line = file.Name(), line
+	}
+	return f
+}
+
+// addCalls adds the calls found below fn.
+func (g *Graph) addCalls(tu clang.TranslationUnit, fn clang.Cursor, caller string) {
+	fn.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() != cursorkind.CallExpr {
+			return clang.ChildVisit_Recurse
+		}
+		file, line, column, _ := cursor.Location().FileLocation()
+		call := Call{
+			Caller: caller,
+			File:   file.Name(),
+			Line:   line,
+			Column: column,
+		}
+		if callee := cursor.Referenced(); !callee.IsNull() && isFunction(callee.Kind()) && callee.USR() != "" {
+			call.Callee = g.function(callee).USR
+		} else {
+			call.Unresolved = calleeText(tu, cursor)
+		}
+		if !g.seen[call] {
+			g.seen[call] = true
+			g.Calls = append(g.Calls, call)
+		}
+		// Arguments may hold calls too.
+		return clang.ChildVisit_Recurse
+	})
+}
+
+// calleeText returns the source of the expression called by the CallExpr
+// call, e.g. "ops->open".
+func calleeText(tu clang.TranslationUnit, call clang.Cursor) string {
+	var callee clang.Cursor
+	call.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		callee = cursor
+		return clang.ChildVisit_Break
+	})
+	if callee.IsNull() {
+		return "?"
+	}
+	var b strings.Builder
+	for _, t := range tu.Tokenize(callee.Extent()) {
+		b.WriteString(tu.TokenSpelling(t))
+	}
+	if b.Len() == 0 {
+		return "?"
+	}
+	return b.String()
+}
+
+// Sort orders the calls by caller and location. It is to be called after
+// adding translation units with Add.
+func (g *Graph) Sort() {
+	sort.Slice(g.Calls, func(i, j int) bool {
+		a, b := g.Calls[i], g.Calls[j]
+		if a.Caller != b.Caller {
+			return a.Caller < b.Caller
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Callee < b.Callee
+	})
+	g.seen = nil
+}
+
+// Lookup returns the USRs of the functions whose USR or name is the given
+// one, sorted.
+func (g *Graph) Lookup(usrOrName string) []string {
+	if _, ok := g.Functions[usrOrName]; ok {
+		return []string{usrOrName}
+	}
+	var r []string
+	for usr, f := range g.Functions {
+		if f.Name == usrOrName {
+			r = append(r, usr)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+// Filter returns the part of the graph reachable from the roots, given by
+// name or USR, following at most depth calls. There is no limit when depth
+// is negative. All the graph is kept when there are no roots.
+func (g *Graph) Filter(roots []string, depth int) *Graph {
+	if len(roots) == 0 {
+		return g
+	}
+	calls := make(map[string][]Call)
+	for _, c := range g.Calls {
+		calls[c.Caller] = append(calls[c.Caller], c)
+	}
+
+	r := New()
+	level := make(map[string]int)
+	var queue []string
+	for _, root := range roots {
+		for _, usr := range g.Lookup(root) {
+			if _, ok := level[usr]; !ok {
+				level[usr] = 0
+				queue = append(queue, usr)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		usr := queue[0]
+		queue = queue[1:]
+		r.Functions[usr] = g.Functions[usr]
+		if depth >= 0 && level[usr] >= depth {
+			continue
+		}
+		for _, c := range calls[usr] {
+			r.Calls = append(r.Calls, c)
+			if c.Callee == "" {
+				continue
+			}
+			if _, ok := level[c.Callee]; !ok {
+				level[c.Callee] = level[usr] + 1
+				queue = append(queue, c.Callee)
+			}
+		}
+	}
+	r.Sort()
+	return r
+}
@@ -0,0 +1,138 @@
+package clangcallgraph
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+func testGraph() *Graph {
+	g := New()
+	for _, name := range []string{"main", "a", "b", "c"} {
+		g.Functions["c:@F@"+name] = &Function{USR: "c:@F@" + name, Name: name, File: "x.c", Defined: name != "c"}
+	}
+	g.Calls = []Call{
+		{Caller: "c:@F@main", Callee: "c:@F@a", File: "x.c", Line: 10, Column: 2},
+		{Caller: "c:@F@main", Callee: "c:@F@a", File: "x.c", Line: 11, Column: 2},
+		{Caller: "c:@F@main", Unresolved: "ops->run", File: "x.c", Line: 12, Column: 2},
+		{Caller: "c:@F@a", Callee: "c:@F@b", File: "x.c", Line: 3, Column: 2},
+		{Caller: "c:@F@b", Callee: "c:@F@c", File: "x.c", Line: 6, Column: 2},
+		{Caller: "c:@F@b", Callee: "c:@F@b", File: "x.c", Line: 7, Column: 2},
+	}
+	g.Sort()
+	return g
+}
+
+func names(g *Graph) []string {
+	var r []string
+	for _, f := range g.sortedFunctions() {
+		r = append(r, f.Name)
+	}
+	return r
+}
+
+func TestFilter(t *testing.T) {
+	g := testGraph()
+	for _, tc := range []struct {
+		roots []string
+		depth int
+		want  []string
+		calls int
+	}{
+		{nil, 0, []string{"a", "b", "c", "main"}, 6},
+		{[]string{"main"}, -1, []string{"a", "b", "c", "main"}, 6},
+		{[]string{"main"}, 0, []string{"main"}, 0},
+		{[]string{"main"}, 1, []string{"a", "main"}, 3},
+		{[]string{"c:@F@a"}, 1, []string{"a", "b"}, 1},
+		{[]string{"b"}, 5, []string{"b", "c"}, 2},
+		{[]string{"nothing"}, -1, nil, 0},
+	} {
+		f := g.Filter(tc.roots, tc.depth)
+		if got := names(f); !reflect.DeepEqual(got, tc.want) || len(f.Calls) != tc.calls {
+			t.Errorf("Filter(%v, %d) = %v with %d calls, want %v with %d calls",
+				tc.roots, tc.depth, got, len(f.Calls), tc.want, tc.calls)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	g := testGraph()
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"c:@F@c" [label="c", style=dashed];`,
+		`"c:@F@main" -> "c:@F@a";`,
+		`"unresolved1" [label="ops->run", shape=ellipse, style=dotted];`,
+		`"c:@F@main" -> "unresolved1" [style=dotted];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output lacks %s:\n%s", want, dot.String())
+		}
+	}
+	if n := strings.Count(dot.String(), `"c:@F@main" -> "c:@F@a"`); n != 1 {
+		t.Errorf("%d edges from main to a", n)
+	}
+
+	var b bytes.Buffer
+	if err := g.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	h, err := ReadJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, h) {
+		t.Errorf("read %+v, want %+v", h, g)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testdata, err := filepath.Abs("../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := []clang.CompileCommand{{
+		Directory: testdata,
+		Filename:  "callgraph.c",
+		Args:      []string{"cc", "-c", "callgraph.c"},
+	}}
+	g, problems := Build(cmds, nil)
+	for _, p := range problems {
+		t.Error("PROBLEM:", p)
+	}
+
+	var got []string
+	for _, c := range g.Calls {
+		callee := "?" + c.Unresolved
+		if c.Callee != "" {
+			callee = g.Functions[c.Callee].Name
+		}
+		got = append(got, g.Functions[c.Caller].Name+" -> "+callee)
+	}
+	// Calls are sorted by the USR of their caller, which is not the order of
+	// the names.
+	sort.Strings(got)
+	want := []string{
+		"apply -> ?fn",
+		"fact -> fact",
+		"main -> ?o.op",
+		"main -> apply",
+		"main -> fact",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("calls %q, want %q", got, want)
+	}
+	for _, name := range []string{"twice", "apply", "fact", "main"} {
+		if usrs := g.Lookup(name); len(usrs) != 1 || !g.Functions[usrs[0]].Defined {
+			t.Errorf("Lookup(%s) = %v", name, usrs)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package clangcallgraph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WriteDOT writes the graph in the Graphviz DOT language. Functions whose
+// body was not seen are dashed, and every unresolved call gets a dotted node
+// of its own, labelled with the expression called. Several calls from one
+// function to another make a single edge.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph callgraph {\n")
+	fmt.Fprintf(bw, "\tnode [shape=box];\n")
+	for _, f := range g.sortedFunctions() {
+		style := ""
+		if !f.Defined {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(bw, "\t%q [label=%q%s];\n", f.USR, f.Name, style)
+	}
+	edges := make(map[[2]string]bool)
+	unresolved := 0
+	for _, c := range g.Calls {
+		if c.Callee == "" {
+			unresolved++
+			node := fmt.Sprintf("unresolved%d", unresolved)
+			fmt.Fprintf(bw, "\t%q [label=%q, shape=ellipse, style=dotted];\n", node, c.Unresolved)
+			fmt.Fprintf(bw, "\t%q -> %q [style=dotted];\n", c.Caller, node)
+			continue
+		}
+		e := [2]string{c.Caller, c.Callee}
+		if !edges[e] {
+			edges[e] = true
+			fmt.Fprintf(bw, "\t%q -> %q;\n", c.Caller, c.Callee)
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// jsonGraph is the form of the graph written by WriteJSON.
+type jsonGraph struct {
+	Functions []*Function `json:"functions"`
+	Calls     []Call      `json:"calls"`
+}
+
+// WriteJSON writes the functions, sorted by name, and every call.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	j := jsonGraph{
+		Functions: g.sortedFunctions(),
+		Calls:     g.Calls,
+	}
+	if j.Calls == nil {
+		j.Calls = []Call{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(j)
+}
+
+// ReadJSON reads a graph written by WriteJSON.
+func ReadJSON(r io.Reader) (*Graph, error) {
+	var j jsonGraph
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return nil, err
+	}
+	g := New()
+	for _, f := range j.Functions {
+		g.Functions[f.USR] = f
+	}
+	g.Calls = j.Calls
+	g.Sort()
+	return g, nil
+}
+
+func (g *Graph) sortedFunctions() []*Function {
+	fs := []*Function{}
+	for _, f := range g.Functions {
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool {
+		if fs[i].Name != fs[j].Name {
+			return fs[i].Name < fs[j].Name
+		}
+		return fs[i].USR < fs[j].USR
+	})
+	return fs
+}
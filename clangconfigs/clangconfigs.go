@@ -0,0 +1,255 @@
+// Package clangconfigs explores how the preprocessor configurations of a
+// source file, sets of -D definitions, change what is compiled.
+//
+// The file is parsed once per configuration. The ranges libclang reports as
+// skipped by the preprocessor give the lines that are dead under each
+// configuration, and the declarations of the file are compared across the
+// configurations to find those whose signature changes, or that only exist
+// under some of them.
+package clangconfigs
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Config is a named set of preprocessor definitions.
+type Config struct {
+	Name    string   `json:"name"`
+	Defines []string `json:"defines"` // NAME or NAME=VALUE, as for -D
+}
+
+// ParseConfig parses a configuration written name:A,B=1, or just A,B=1 in
+// which case the definitions name it.
+func ParseConfig(s string) (Config, error) {
+	c := Config{Defines: []string{}}
+	defines := s
+	if i := strings.Index(s, ":"); i >= 0 {
+		c.Name, defines = s[:i], s[i+1:]
+		if c.Name == "" {
+			return c, fmt.Errorf("configuration %q has an empty name", s)
+		}
+	}
+	for _, d := range strings.Split(defines, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		if strings.HasPrefix(d, "=") {
+			return c, fmt.Errorf("configuration %q defines a macro without a name", s)
+		}
+		c.Defines = append(c.Defines, d)
+	}
+	if c.Name == "" {
+		c.Name = strings.Join(c.Defines, ",")
+	}
+	if c.Name == "" {
+		return c, fmt.Errorf("configuration %q is empty, name it to use no definitions", s)
+	}
+	return c, nil
+}
+
+// Args returns the -D arguments of the configuration.
+func (c Config) Args() []string {
+	var r []string
+	for _, d := range c.Defines {
+		r = append(r, "-D"+d)
+	}
+	return r
+}
+
+// Range is a range of lines, both included.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (r Range) String() string {
+	if r.Start == r.End {
+		return fmt.Sprint(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// Difference is a declaration whose signature is not the same under every
+// configuration.
+type Difference struct {
+	USR        string   `json:"usr"`
+	Name       string   `json:"name"`
+	Signatures []string `json:"signatures"` // one per configuration, empty where it is not declared
+}
+
+// Report is the outcome of parsing a file under several configurations.
+type Report struct {
+	File        string       `json:"file"`
+	Lines       int          `json:"lines"`
+	Configs     []Config     `json:"configs"`
+	Dead        [][]bool     `json:"-"`           // per configuration, per line from 1, whether the line is skipped
+	DeadRanges  [][]Range    `json:"deadRanges"`  // per configuration
+	AlwaysDead  []Range      `json:"alwaysDead"`  // the lines skipped under every configuration
+	Differences []Difference `json:"differences"` // sorted by name and USR
+}
+
+// Live reports whether line, from 1, is compiled under the configuration
+// with index config.
+func (r *Report) Live(config, line int) bool {
+	return line >= 1 && line <= r.Lines && !r.Dead[config][line]
+}
+
+// Explore parses source with args under every configuration.
+func Explore(idx clang.Index, source string, args []string, configs []Config) (*Report, error) {
+	r := &Report{
+		File:        source,
+		Configs:     configs,
+		Differences: []Difference{},
+	}
+	signatures := make([]map[string]string, len(configs))
+	names := make(map[string]string)
+	for i, c := range configs {
+		var tu clang.TranslationUnit
+		if err := idx.ParseTranslationUnit2(source, append(append([]string{}, args...), c.Args()...), nil,
+			clang.TranslationUnit_DetailedPreprocessingRecord, &tu); err != nil {
+			return nil, fmt.Errorf("parsing %s under %s: %v", source, c.Name, err)
+		}
+		file := tu.File(source)
+		if r.Lines == 0 {
+			r.Lines = countLines(tu.FileContents(file))
+		}
+		dead := make([]bool, r.Lines+1)
+		for _, sr := range tu.SkippedRanges(file) {
+			_, start, _, _ := sr.Start().FileLocation()
+			_, end, _, _ := sr.End().FileLocation()
+			markDead(dead, int(start), int(end))
+		}
+		r.Dead = append(r.Dead, dead)
+		signatures[i] = declarations(tu, names)
+		tu.Dispose()
+	}
+	r.summarize(signatures, names)
+	return r, nil
+}
+
+func countLines(contents []byte) int {
+	n := bytes.Count(contents, []byte("\n"))
+	if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// markDead marks the lines of a skipped range, from the line of the directive
+// starting it to the line of the directive ending it. The directives
+// themselves are live.
+func markDead(dead []bool, start, end int) {
+	for line := start + 1; line < end && line < len(dead); line++ {
+		dead[line] = true
+	}
+}
+
+// summarize computes the ranges and the differences from the dead lines and
+// the signatures of every configuration.
+func (r *Report) summarize(signatures []map[string]string, names map[string]string) {
+	r.DeadRanges = make([][]Range, len(r.Dead))
+	always := make([]bool, r.Lines+1)
+	for line := 1; line <= r.Lines; line++ {
+		always[line] = len(r.Dead) > 0
+	}
+	for i, dead := range r.Dead {
+		r.DeadRanges[i] = ranges(dead)
+		for line := 1; line <= r.Lines; line++ {
+			always[line] = always[line] && dead[line]
+		}
+	}
+	r.AlwaysDead = ranges(always)
+
+	usrs := make(map[string]bool)
+	for _, sigs := range signatures {
+		for usr := range sigs {
+			usrs[usr] = true
+		}
+	}
+	for usr := range usrs {
+		d := Difference{USR: usr, Name: names[usr]}
+		same := true
+		for _, sigs := range signatures {
+			d.Signatures = append(d.Signatures, sigs[usr])
+			same = same && sigs[usr] == d.Signatures[0]
+		}
+		if !same {
+			r.Differences = append(r.Differences, d)
+		}
+	}
+	sort.Slice(r.Differences, func(i, j int) bool {
+		a, b := r.Differences[i], r.Differences[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.USR < b.USR
+	})
+}
+
+// ranges returns the ranges of the lines set, from 1.
+func ranges(lines []bool) []Range {
+	r := []Range{}
+	for line := 1; line < len(lines); line++ {
+		if !lines[line] {
+			continue
+		}
+		if n := len(r); n > 0 && r[n-1].End == line-1 {
+			r[n-1].End = line
+		} else {
+			r = append(r, Range{line, line})
+		}
+	}
+	return r
+}
+
+// declarations returns the signatures of the declarations of the main file
+// of the translation unit, keyed by USR, and records their names.
+func declarations(tu clang.TranslationUnit, names map[string]string) map[string]string {
+	r := make(map[string]string)
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if !cursor.Location().IsFromMainFile() {
+			return clang.ChildVisit_Continue
+		}
+		kind := cursor.Kind()
+		if !kind.IsDeclaration() || kind == cursorkind.ParmDecl {
+			return clang.ChildVisit_Continue
+		}
+		if usr := cursor.USR(); usr != "" {
+			if _, ok := r[usr]; !ok {
+				r[usr] = signature(cursor)
+				names[usr] = cursor.Spelling()
+			}
+		}
+		switch kind {
+		case cursorkind.FunctionDecl, cursorkind.CXXMethod, cursorkind.Constructor, cursorkind.Destructor,
+			cursorkind.ConversionFunction, cursorkind.FunctionTemplate:
+			// The locals of a function are not part of its signature.
+			return clang.ChildVisit_Continue
+		}
+		return clang.ChildVisit_Recurse
+	})
+	return r
+}
+
+// signature returns what identifies the interface of a declaration.
+func signature(c clang.Cursor) string {
+	switch c.Kind() {
+	case cursorkind.FunctionDecl, cursorkind.CXXMethod, cursorkind.Constructor, cursorkind.Destructor,
+		cursorkind.ConversionFunction, cursorkind.FunctionTemplate:
+		return c.ResultType().Spelling() + " " + c.DisplayName()
+	case cursorkind.VarDecl, cursorkind.FieldDecl:
+		return c.Type().Spelling() + " " + c.Spelling()
+	case cursorkind.TypedefDecl:
+		return "typedef " + c.TypedefDeclUnderlyingType().Spelling() + " " + c.Spelling()
+	case cursorkind.EnumConstantDecl:
+		return fmt.Sprintf("%s = %d", c.Spelling(), c.EnumConstantDeclValue())
+	}
+	return c.Kind().String() + " " + c.DisplayName()
+}
@@ -0,0 +1,114 @@
+package clangconfigs
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+func TestParseConfig(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want Config
+		err  bool
+	}{
+		{"debug:DEBUG,LEVEL=2", Config{"debug", []string{"DEBUG", "LEVEL=2"}}, false},
+		{"DEBUG, LEVEL=2", Config{"DEBUG,LEVEL=2", []string{"DEBUG", "LEVEL=2"}}, false},
+		{"base:", Config{"base", []string{}}, false},
+		{"", Config{}, true},
+		{":DEBUG", Config{}, true},
+		{"bad:=1", Config{}, true},
+	} {
+		c, err := ParseConfig(tc.s)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ParseConfig(%q) = %+v, want an error", tc.s, c)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(c, tc.want) {
+			t.Errorf("ParseConfig(%q) = %+v, %v, want %+v", tc.s, c, err, tc.want)
+		}
+	}
+	c := Config{"x", []string{"A", "B=1"}}
+	if got := c.Args(); !reflect.DeepEqual(got, []string{"-DA", "-DB=1"}) {
+		t.Errorf("Args() = %q", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	r := &Report{Lines: 10}
+	for _, skipped := range [][]Range{
+		{{1, 4}, {6, 8}},
+		{{1, 3}, {6, 10}},
+	} {
+		dead := make([]bool, r.Lines+1)
+		for _, s := range skipped {
+			markDead(dead, s.Start, s.End)
+		}
+		r.Dead = append(r.Dead, dead)
+	}
+	r.summarize([]map[string]string{
+		{"c:@F@f": "int f(int)", "c:@F@g": "void g()", "c:@T@t": "typedef int t"},
+		{"c:@F@f": "int f(int)", "c:@F@h": "void h()", "c:@T@t": "typedef long t"},
+	}, map[string]string{"c:@F@f": "f", "c:@F@g": "g", "c:@F@h": "h", "c:@T@t": "t"})
+
+	want := [][]Range{{{2, 3}, {7, 7}}, {{2, 2}, {7, 9}}}
+	if !reflect.DeepEqual(r.DeadRanges, want) {
+		t.Errorf("dead ranges %v, want %v", r.DeadRanges, want)
+	}
+	if want := []Range{{2, 2}, {7, 7}}; !reflect.DeepEqual(r.AlwaysDead, want) {
+		t.Errorf("always dead %v, want %v", r.AlwaysDead, want)
+	}
+	if r.Live(0, 3) || !r.Live(1, 3) || r.Live(0, 11) {
+		t.Error("Live is wrong")
+	}
+	wantDiffs := []Difference{
+		{"c:@F@g", "g", []string{"void g()", ""}},
+		{"c:@F@h", "h", []string{"", "void h()"}},
+		{"c:@T@t", "t", []string{"typedef int t", "typedef long t"}},
+	}
+	if !reflect.DeepEqual(r.Differences, wantDiffs) {
+		t.Errorf("differences %+v, want %+v", r.Differences, wantDiffs)
+	}
+}
+
+func TestExplore(t *testing.T) {
+	source, err := filepath.Abs("../testdata/configs.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+	configs := []Config{
+		{"base", []string{}},
+		{"debug", []string{"DEBUG"}},
+		{"wide", []string{"WIDE"}},
+	}
+	r, err := Explore(idx, source, nil, configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]Range{
+		{{4, 4}, {12, 12}, {17, 17}, {21, 21}},
+		{{4, 4}, {21, 21}},
+		{{6, 6}, {12, 12}, {17, 17}, {21, 21}},
+	}
+	if !reflect.DeepEqual(r.DeadRanges, want) {
+		t.Errorf("dead ranges %v, want %v", r.DeadRanges, want)
+	}
+	if want := []Range{{21, 21}}; !reflect.DeepEqual(r.AlwaysDead, want) {
+		t.Errorf("always dead %v, want %v", r.AlwaysDead, want)
+	}
+
+	var names []string
+	for _, d := range r.Differences {
+		names = append(names, d.Name)
+	}
+	if want := []string{"name", "trace", "value"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("differences %+v", r.Differences)
+	}
+}
@@ -0,0 +1,265 @@
+// Package clangincludes builds the include graph of a translation unit: which
+// file includes which, at which line and through which search path.
+//
+// The graph is made of the inclusion directives libclang records when the
+// translation unit is parsed with TranslationUnit_DetailedPreprocessingRecord.
+// A directive whose file was skipped, because of an include guard or #pragma
+// once, still makes an edge, so include cycles show up even when the guards
+// break them.
+package clangincludes
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Include is an inclusion directive.
+type Include struct {
+	Includer string `json:"includer"` // the file holding the directive
+	Line     uint32 `json:"line"`
+	Written  string `json:"written"`        // the name as written, without quotes or brackets
+	Angled   bool   `json:"angled"`         // written with angle brackets
+	File     string `json:"file,omitempty"` // the file included, empty when it was not found
+	Dir      string `json:"dir,omitempty"`  // the search path the file was found in
+}
+
+// Header is a file of the translation unit, the main file included.
+type Header struct {
+	Name    string `json:"name"`
+	System  bool   `json:"system"`  // in a system header directory
+	Guarded bool   `json:"guarded"` // protected by an include guard or #pragma once
+	Depth   int    `json:"depth"`   // fewest directives followed from the main file, -1 when not reached
+	FanIn   int    `json:"fanIn"`   // number of files including it
+}
+
+// Graph is the include graph of a translation unit.
+type Graph struct {
+	Main     string             `json:"main"`
+	Files    map[string]*Header `json:"files"`    // keyed by name
+	Includes []Include          `json:"includes"` // sorted by includer and line
+	Cycles   [][]string         `json:"cycles"`   // each starts and ends with the same file
+
+	included  map[string][]string // by includer, in the order of first directive
+	includers map[string][]string // by file included, sorted
+}
+
+// Explore returns the include graph of the translation unit.
+func Explore(tu clang.TranslationUnit) *Graph {
+	g := &Graph{
+		Main:     tu.Spelling(),
+		Files:    make(map[string]*Header),
+		Includes: []Include{},
+	}
+	add := func(file clang.File) {
+		name := file.Name()
+		if _, ok := g.Files[name]; ok || name == "" {
+			return
+		}
+		g.Files[name] = &Header{
+			Name:    name,
+			System:  tu.LocationForOffset(file, 0).IsInSystemHeader(),
+			Guarded: tu.IsFileMultipleIncludeGuarded(file),
+			Depth:   -1,
+		}
+	}
+
+	for _, inc := range tu.Inclusions() {
+		if len(inc.Stack) == 0 {
+			g.Main = inc.File.Name()
+		}
+		add(inc.File)
+	}
+
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() != cursorkind.InclusionDirective {
+			// The preprocessing entities of every file are found at the top level.
+			return clang.ChildVisit_Continue
+		}
+		includer, line, _, _ := cursor.Location().FileLocation()
+		in := Include{
+			Includer: includer.Name(),
+			Line:     line,
+			Written:  cursor.Spelling(),
+		}
+		for _, t := range tu.Tokenize(cursor.Extent()) {
+			if tu.TokenSpelling(t) == "<" {
+				in.Angled = true
+				break
+			}
+		}
+		if file := cursor.IncludedFile(); file.Name() != "" {
+			add(file)
+			in.File = file.Name()
+			in.Dir = searchPath(in.File, in.Written)
+		}
+		g.Includes = append(g.Includes, in)
+		return clang.ChildVisit_Continue
+	})
+
+	sort.SliceStable(g.Includes, func(i, j int) bool {
+		a, b := g.Includes[i], g.Includes[j]
+		if a.Includer != b.Includer {
+			return a.Includer < b.Includer
+		}
+		return a.Line < b.Line
+	})
+	g.link()
+	g.measure()
+	g.Cycles = g.cycles()
+	return g
+}
+
+// searchPath returns the directory that, joined with the name as written,
+// gives the file included.
+func searchPath(file, written string) string {
+	if dir := strings.TrimSuffix(file, "/"+written); dir != file {
+		return dir
+	}
+	return filepath.Dir(file)
+}
+
+// link builds the adjacency maps of the include edges.
+func (g *Graph) link() {
+	g.included = make(map[string][]string)
+	g.includers = make(map[string][]string)
+	seen := make(map[Include]bool)
+	for _, in := range g.Includes {
+		if in.File == "" {
+			continue
+		}
+		edge := Include{Includer: in.Includer, File: in.File}
+		if seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		g.included[in.Includer] = append(g.included[in.Includer], in.File)
+		g.includers[in.File] = append(g.includers[in.File], in.Includer)
+	}
+	for _, r := range g.includers {
+		sort.Strings(r)
+	}
+}
+
+// Included returns the distinct files directly included by the named file,
+// in the order of their first directive.
+func (g *Graph) Included(name string) []string {
+	if g.included == nil {
+		g.link()
+	}
+	return append([]string(nil), g.included[name]...)
+}
+
+// Includers returns the distinct files directly including the named file,
+// sorted.
+func (g *Graph) Includers(name string) []string {
+	if g.includers == nil {
+		g.link()
+	}
+	return append([]string(nil), g.includers[name]...)
+}
+
+// Closure returns the files the named file includes, directly or not,
+// sorted. The file itself is only part of it when it is in a cycle.
+func (g *Graph) Closure(name string) []string {
+	seen := make(map[string]bool)
+	queue := g.Included(name)
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		queue = append(queue, g.Included(f)...)
+	}
+	var r []string
+	for f := range seen {
+		r = append(r, f)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// measure sets the depth and fan-in of every file.
+func (g *Graph) measure() {
+	for name, h := range g.Files {
+		h.FanIn = len(g.Includers(name))
+	}
+	main, ok := g.Files[g.Main]
+	if !ok {
+		return
+	}
+	main.Depth = 0
+	queue := []string{g.Main}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		for _, inc := range g.Included(f) {
+			if h := g.Files[inc]; h != nil && h.Depth < 0 {
+				h.Depth = g.Files[f].Depth + 1
+				queue = append(queue, inc)
+			}
+		}
+	}
+}
+
+// cycles returns the cycles closed by the back edges of a depth first search
+// from the main file.
+func (g *Graph) cycles() [][]string {
+	r := [][]string{}
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(f string)
+	visit = func(f string) {
+		state[f] = active
+		stack = append(stack, f)
+		for _, inc := range g.Included(f) {
+			switch state[inc] {
+			case unvisited:
+				visit(inc)
+			case active:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == inc {
+						cycle := append([]string{}, stack[i:]...)
+						r = append(r, append(cycle, inc))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[f] = done
+	}
+	visit(g.Main)
+	for _, f := range g.sortedFiles() {
+		if state[f.Name] == unvisited {
+			visit(f.Name)
+		}
+	}
+	return r
+}
+
+// sortedFiles returns the files by depth, the unreached last, then name.
+func (g *Graph) sortedFiles() []*Header {
+	var r []*Header
+	for _, h := range g.Files {
+		r = append(r, h)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		a, b := r[i], r[j]
+		if a.Depth != b.Depth {
+			return uint(a.Depth) < uint(b.Depth)
+		}
+		return a.Name < b.Name
+	})
+	return r
+}
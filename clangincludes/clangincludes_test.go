@@ -0,0 +1,248 @@
+package clangincludes
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+func testGraph() *Graph {
+	g := &Graph{
+		Main: "main.c",
+		Files: map[string]*Header{
+			"main.c":           {Name: "main.c"},
+			"inc/a.h":          {Name: "inc/a.h", Guarded: true},
+			"inc/b.h":          {Name: "inc/b.h", Guarded: true},
+			"inc/c.h":          {Name: "inc/c.h"},
+			"/usr/include/d.h": {Name: "/usr/include/d.h", System: true},
+		},
+		Includes: []Include{
+			{Includer: "inc/a.h", Line: 3, Written: "b.h", File: "inc/b.h", Dir: "inc"},
+			{Includer: "inc/a.h", Line: 4, Written: "c.h", Angled: true, File: "inc/c.h", Dir: "inc"},
+			{Includer: "inc/b.h", Line: 3, Written: "a.h", File: "inc/a.h", Dir: "inc"},
+			{Includer: "main.c", Line: 1, Written: "a.h", Angled: true, File: "inc/a.h", Dir: "inc"},
+			{Includer: "main.c", Line: 2, Written: "c.h", Angled: true, File: "inc/c.h", Dir: "inc"},
+			{Includer: "main.c", Line: 3, Written: "d.h", Angled: true, File: "/usr/include/d.h", Dir: "/usr/include"},
+			{Includer: "main.c", Line: 4, Written: "none.h", Angled: true},
+		},
+	}
+	for _, h := range g.Files {
+		h.Depth = -1
+	}
+	g.measure()
+	g.Cycles = g.cycles()
+	return g
+}
+
+func TestGraph(t *testing.T) {
+	g := testGraph()
+
+	for _, tc := range []struct {
+		name         string
+		depth, fanIn int
+	}{
+		{"main.c", 0, 0},
+		{"inc/a.h", 1, 2},
+		{"inc/b.h", 2, 1},
+		{"inc/c.h", 1, 2},
+		{"/usr/include/d.h", 1, 1},
+	} {
+		if h := g.Files[tc.name]; h.Depth != tc.depth || h.FanIn != tc.fanIn {
+			t.Errorf("%s has depth %d and fan-in %d, want %d and %d", tc.name, h.Depth, h.FanIn, tc.depth, tc.fanIn)
+		}
+	}
+	if want := [][]string{{"inc/a.h", "inc/b.h", "inc/a.h"}}; !reflect.DeepEqual(g.Cycles, want) {
+		t.Errorf("cycles %q, want %q", g.Cycles, want)
+	}
+	if got, want := g.Closure("inc/b.h"), []string{"inc/a.h", "inc/b.h", "inc/c.h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Closure(inc/b.h) = %q, want %q", got, want)
+	}
+	if got, want := g.Includers("inc/c.h"), []string{"inc/a.h", "main.c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Includers(inc/c.h) = %q, want %q", got, want)
+	}
+
+	var text bytes.Buffer
+	if err := g.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"main.c (depth 0, fan-in 0)\n\tline 1: <a.h> -> inc/a.h (in inc)\n",
+		"\tline 4: <none.h> not found\n",
+		"/usr/include/d.h (depth 1, fan-in 1, system)\n",
+		"\tline 3: \"b.h\" -> inc/b.h (in inc)\n",
+		"cycle: inc/a.h -> inc/b.h -> inc/a.h\n",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output lacks %q:\n%s", want, text.String())
+		}
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"main.c" [label="main.c", peripheries=2];`,
+		`"inc/b.h" -> "inc/a.h" [label="3", color=red];`,
+		`"main.c" -> "inc/c.h" [label="2"];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output lacks %s:\n%s", want, dot.String())
+		}
+	}
+}
+
+func TestDotID(t *testing.T) {
+	for _, tc := range []struct{ name, want string }{
+		{"inc/a.h", `"inc/a.h"`},
+		{"inc/größe.h", `"inc/größe.h"`},
+		{`inc/"q".h`, `"inc/\"q\".h"`},
+		{`C:\inc\a.h`, `"C:\\inc\\a.h"`},
+	} {
+		if got := dotID(tc.name); got != tc.want {
+			t.Errorf("dotID(%q) = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestExplore(t *testing.T) {
+	dir, err := filepath.Abs("../testdata/includes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+	main := filepath.Join(dir, "main.c")
+	inc := filepath.Join(dir, "inc")
+	tu := idx.ParseTranslationUnit(main, []string{"-I" + inc}, nil, clang.TranslationUnit_DetailedPreprocessingRecord)
+	if !tu.IsValid() {
+		t.Fatal("parsing failed")
+	}
+	defer tu.Dispose()
+	for _, d := range tu.Diagnostics() {
+		t.Error("PROBLEM:", d.Spelling())
+	}
+
+	g := Explore(tu)
+	if g.Main != main {
+		t.Errorf("main file %s, want %s", g.Main, main)
+	}
+	var got []string
+	for _, in := range g.Includes {
+		got = append(got, filepath.Base(in.Includer)+" -> "+filepath.Base(in.File))
+		if in.Dir != dir && in.Dir != inc {
+			t.Errorf("%s found in %s", in.Written, in.Dir)
+		}
+	}
+	want := []string{"a.h -> b.h", "a.h -> c.h", "b.h -> a.h", "main.c -> local.h", "main.c -> a.h", "main.c -> c.h"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("includes %q, want %q", got, want)
+	}
+	if len(g.Cycles) != 1 || len(g.Cycles[0]) != 3 {
+		t.Errorf("cycles %q", g.Cycles)
+	}
+	c := g.Files[filepath.Join(inc, "c.h")]
+	if c == nil || c.Depth != 1 || c.FanIn != 2 || !c.Guarded || c.System {
+		t.Errorf("c.h %+v", c)
+	}
+}
+
+func TestReport(t *testing.T) {
+	g := testGraph()
+	uses := map[string]Use{
+		"a": {Name: "A", Header: "inc/a.h", Line: 7},
+		"b": {Name: "B", Header: "inc/b.h", Line: 8},
+		"d": {Name: "D", Header: "/usr/include/d.h", Line: 9},
+	}
+	r := report(g, uses, []string{"a", "b", "d"})
+
+	var unused []string
+	for _, u := range r.Includes {
+		if u.Unused() {
+			unused = append(unused, u.Include.Written)
+		}
+	}
+	if want := []string{"c.h", "none.h"}; !reflect.DeepEqual(unused, want) {
+		t.Errorf("unused %q, want %q", unused, want)
+	}
+	if a := r.Includes[0]; len(a.Direct) != 1 || len(a.Transitive) != 1 || a.Transitive[0].Name != "B" {
+		t.Errorf("usage of a.h %+v", a)
+	}
+	if len(r.Indirect) != 1 || r.Indirect[0].Name != "B" {
+		t.Errorf("indirect uses %+v", r.Indirect)
+	}
+	want := []FixIt{
+		{File: "main.c", Line: 2, Remove: true},
+		{File: "main.c", Line: 4, Remove: true},
+		{File: "main.c", Line: 5, Text: `#include "b.h"`},
+	}
+	if !reflect.DeepEqual(r.FixIts, want) {
+		t.Errorf("fix-its %+v, want %+v", r.FixIts, want)
+	}
+
+	contents := "#include <a.h>\n#include <c.h>\n#include <d.h>\n#include <none.h>\nint x;\n"
+	fixed := "#include <a.h>\n#include <d.h>\n#include \"b.h\"\nint x;\n"
+	if got := string(Apply([]byte(contents), r.FixIts)); got != fixed {
+		t.Errorf("Apply gives %q, want %q", got, fixed)
+	}
+	if got := string(Apply([]byte("#include <a.h>"), []FixIt{{Line: 2, Text: "#include <b.h>"}})); got != "#include <a.h>\n#include <b.h>\n" {
+		t.Errorf("Apply after the last line gives %q", got)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	dir, err := filepath.Abs("../testdata/includes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+	tu := idx.ParseTranslationUnit(filepath.Join(dir, "unused.c"), []string{"-I" + filepath.Join(dir, "inc")}, nil,
+		clang.TranslationUnit_DetailedPreprocessingRecord)
+	if !tu.IsValid() {
+		t.Fatal("parsing failed")
+	}
+	defer tu.Dispose()
+
+	r := Analyze(tu)
+	var got []string
+	for _, f := range r.FixIts {
+		got = append(got, fmt.Sprintf("%d %v %s", f.Line, f.Remove, f.Text))
+	}
+	want := []string{"4 true ", `6 false #include "b.h"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fix-its %q, want %q", got, want)
+	}
+	if len(r.Includes) != 3 || len(r.Includes[2].Direct) != 1 || r.Includes[2].Direct[0].Name != "LOCAL" {
+		t.Errorf("includes %+v", r.Includes)
+	}
+}
+
+func TestAnalyzeDefinition(t *testing.T) {
+	dir, err := filepath.Abs("../testdata/includes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+	tu := idx.ParseTranslationUnit(filepath.Join(dir, "defined.c"), nil, nil,
+		clang.TranslationUnit_DetailedPreprocessingRecord)
+	if !tu.IsValid() {
+		t.Fatal("parsing failed")
+	}
+	defer tu.Dispose()
+
+	// The header declaring what the main file defines is used.
+	r := Analyze(tu)
+	if len(r.FixIts) != 0 {
+		t.Errorf("fix-its %v", r.FixIts)
+	}
+	if len(r.Includes) != 1 || len(r.Includes[0].Direct) != 1 || r.Includes[0].Direct[0].Name != "g" {
+		t.Errorf("includes %+v", r.Includes)
+	}
+}
@@ -0,0 +1,96 @@
+package clangincludes
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteText writes every file, the main file first and the others by depth,
+// with its directives, followed by the cycles.
+func (g *Graph) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, h := range g.sortedFiles() {
+		fmt.Fprintf(bw, "%s (depth %d, fan-in %d", h.Name, h.Depth, h.FanIn)
+		if h.System {
+			fmt.Fprintf(bw, ", system")
+		}
+		if h.Guarded {
+			fmt.Fprintf(bw, ", guarded")
+		}
+		fmt.Fprintf(bw, ")\n")
+		for _, in := range g.Includes {
+			if in.Includer != h.Name {
+				continue
+			}
+			written := `"` + in.Written + `"`
+			if in.Angled {
+				written = "<" + in.Written + ">"
+			}
+			if in.File == "" {
+				fmt.Fprintf(bw, "\tline %d: %s not found\n", in.Line, written)
+				continue
+			}
+			fmt.Fprintf(bw, "\tline %d: %s -> %s (in %s)\n", in.Line, written, in.File, in.Dir)
+		}
+	}
+	for _, c := range g.Cycles {
+		fmt.Fprintf(bw, "cycle: %s\n", strings.Join(c, " -> "))
+	}
+	return bw.Flush()
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. The main file has a
+// double border, system headers are grey and the edges of cycles red. Every
+// directive is an edge, labelled with its line.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	inCycle := make(map[[2]string]bool)
+	for _, c := range g.Cycles {
+		for i := 1; i < len(c); i++ {
+			inCycle[[2]string{c[i-1], c[i]}] = true
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph includes {\n")
+	fmt.Fprintf(bw, "\tnode [shape=box];\n")
+	for _, h := range g.sortedFiles() {
+		attrs := ""
+		if h.Name == g.Main {
+			attrs += ", peripheries=2"
+		}
+		if h.System {
+			attrs += ", color=grey, fontcolor=grey"
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s%s];\n", dotID(h.Name), dotID(h.Name), attrs)
+	}
+	for _, in := range g.Includes {
+		if in.File == "" {
+			continue
+		}
+		attrs := ""
+		if inCycle[[2]string{in.Includer, in.File}] {
+			attrs = ", color=red"
+		}
+		fmt.Fprintf(bw, "\t%s -> %s [label=\"%d\"%s];\n", dotID(in.Includer), dotID(in.File), in.Line, attrs)
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// dotID returns s as a quoted DOT identifier. Only double quotes and
+// backslashes are escaped, other characters stand for themselves.
+func dotID(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// WriteJSON writes the graph as JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(g)
+}
@@ -0,0 +1,244 @@
+package clangincludes
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Use is a declaration or macro of a header used by the main file.
+type Use struct {
+	Name   string `json:"name"`
+	Header string `json:"header"` // the file declaring it
+	System bool   `json:"system"` // whether the header is a system header
+	Line   uint32 `json:"line"`   // first use in the main file
+	Column uint32 `json:"column"`
+}
+
+// Usage tells what an #include of the main file contributes.
+type Usage struct {
+	Include    Include `json:"include"`
+	Direct     []Use   `json:"direct"`     // declared by the included file itself
+	Transitive []Use   `json:"transitive"` // declared by the files it includes, directly or not
+}
+
+// Unused reports whether nothing the main file uses comes from the include.
+func (u *Usage) Unused() bool {
+	return len(u.Direct) == 0 && len(u.Transitive) == 0
+}
+
+// FixIt is a change to the includes of a file. It either removes the
+// directive at Line, or inserts Text before Line.
+type FixIt struct {
+	File   string `json:"file"`
+	Line   uint32 `json:"line"`
+	Remove bool   `json:"remove,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
+
+func (f FixIt) String() string {
+	if f.Remove {
+		return fmt.Sprintf("%s:%d: remove the #include", f.File, f.Line)
+	}
+	return fmt.Sprintf("%s:%d: add %s", f.File, f.Line, f.Text)
+}
+
+// Report tells which includes of the main file are used.
+type Report struct {
+	Main     string  `json:"main"`
+	Includes []Usage `json:"includes"` // one per #include of the main file, in order
+	Indirect []Use   `json:"indirect"` // used from headers the main file does not include itself
+	FixIts   []FixIt `json:"fixIts"`   // removals by line, then additions
+}
+
+// Analyze finds what the main file of the translation unit uses from its
+// headers: the declarations its references lead to, the declarations of the
+// types it uses, those it declares or defines again and the macros it
+// expands. The translation unit must be parsed with
+// TranslationUnit_DetailedPreprocessingRecord for the macros and the includes
+// to be known.
+//
+// An include is unused when nothing used comes from it or from the files it
+// includes. A use of a header the main file reaches only through other
+// headers is indirect, and gets a fix-it adding the header, unless it is a
+// system header: those are often internal to the one meant to be included.
+func Analyze(tu clang.TranslationUnit) *Report {
+	g := Explore(tu)
+	uses := make(map[string]Use)
+	var order []string
+	use := func(decl clang.Cursor, at clang.SourceLocation) {
+		if decl.IsNull() || decl.Kind().IsInvalid() {
+			return
+		}
+		if decl.Kind() != cursorkind.MacroDefinition {
+			// The first declaration is the one a header provides, even when
+			// the main file declares or defines it again.
+			decl = decl.CanonicalCursor()
+		}
+		file, _, _, offset := decl.Location().FileLocation()
+		header := file.Name()
+		if header == "" || header == g.Main {
+			return
+		}
+		key := fmt.Sprintf("%s:%d", header, offset)
+		if _, ok := uses[key]; ok {
+			return
+		}
+		_, line, column, _ := at.FileLocation()
+		uses[key] = Use{
+			Name:   decl.Spelling(),
+			Header: header,
+			System: decl.Location().IsInSystemHeader(),
+			Line:   line,
+			Column: column,
+		}
+		order = append(order, key)
+	}
+
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		loc := cursor.Location()
+		if !loc.IsFromMainFile() {
+			return clang.ChildVisit_Continue
+		}
+		kind := cursor.Kind()
+		switch {
+		case kind == cursorkind.MacroExpansion, kind.IsReference(), kind == cursorkind.DeclRefExpr,
+			kind == cursorkind.MemberRefExpr:
+			use(cursor.Referenced(), loc)
+		case kind.IsDeclaration():
+			// A declaration or definition of what a header declares first.
+			use(cursor, loc)
+			use(cursor.Type().Declaration(), loc)
+		case kind.IsExpression():
+			use(cursor.Type().Declaration(), loc)
+		}
+		return clang.ChildVisit_Recurse
+	})
+
+	return report(g, uses, order)
+}
+
+// report attributes the uses, keyed in order, to the includes of the main
+// file of g.
+func report(g *Graph, uses map[string]Use, order []string) *Report {
+	r := &Report{
+		Main:     g.Main,
+		Includes: []Usage{},
+		Indirect: []Use{},
+		FixIts:   []FixIt{},
+	}
+	closures := make(map[string]map[string]bool)
+	direct := make(map[string]bool)
+	last := uint32(0)
+	for _, in := range g.Includes {
+		if in.Includer != g.Main {
+			continue
+		}
+		r.Includes = append(r.Includes, Usage{Include: in, Direct: []Use{}, Transitive: []Use{}})
+		direct[in.File] = true
+		if _, ok := closures[in.File]; !ok && in.File != "" {
+			closures[in.File] = make(map[string]bool)
+			for _, f := range g.Closure(in.File) {
+				closures[in.File][f] = true
+			}
+		}
+		if in.Line > last {
+			last = in.Line
+		}
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, key := range order {
+		u := uses[key]
+		if direct[u.Header] {
+			for i := range r.Includes {
+				if r.Includes[i].Include.File == u.Header {
+					r.Includes[i].Direct = append(r.Includes[i].Direct, u)
+				}
+			}
+			continue
+		}
+		for i := range r.Includes {
+			if closures[r.Includes[i].Include.File][u.Header] {
+				r.Includes[i].Transitive = append(r.Includes[i].Transitive, u)
+			}
+		}
+		r.Indirect = append(r.Indirect, u)
+		if !u.System && !seen[u.Header] {
+			seen[u.Header] = true
+			missing = append(missing, u.Header)
+		}
+	}
+
+	for _, u := range r.Includes {
+		if u.Unused() {
+			r.FixIts = append(r.FixIts, FixIt{File: g.Main, Line: u.Include.Line, Remove: true})
+		}
+	}
+	for _, header := range missing {
+		r.FixIts = append(r.FixIts, FixIt{File: g.Main, Line: last + 1, Text: g.directive(header)})
+	}
+	return r
+}
+
+// directive returns an #include of the named header, written as the first
+// directive including it.
+func (g *Graph) directive(header string) string {
+	for _, in := range g.Includes {
+		if in.File != header {
+			continue
+		}
+		if in.Angled {
+			return "#include <" + in.Written + ">"
+		}
+		return `#include "` + in.Written + `"`
+	}
+	return `#include "` + header + `"`
+}
+
+// Apply returns contents, the contents of a file, with the fix-its applied.
+// Lines are numbered from 1, as fix-its are.
+func Apply(contents []byte, fixIts []FixIt) []byte {
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+	remove := make(map[uint32]bool)
+	insert := make(map[uint32][]string)
+	for _, f := range fixIts {
+		if f.Remove {
+			remove[f.Line] = true
+		} else {
+			insert[f.Line] = append(insert[f.Line], f.Text)
+		}
+	}
+	var b bytes.Buffer
+	for i, line := range lines {
+		n := uint32(i + 1)
+		for _, text := range insert[n] {
+			b.WriteString(text + "\n")
+		}
+		if !remove[n] {
+			b.Write(line)
+		}
+	}
+	// Text inserted after the last line.
+	var after []uint32
+	for n := range insert {
+		if n > uint32(len(lines)) {
+			after = append(after, n)
+		}
+	}
+	sort.Slice(after, func(i, j int) bool { return after[i] < after[j] })
+	for _, n := range after {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		for _, text := range insert[n] {
+			b.WriteString(text + "\n")
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,291 @@
+// Package clangindex builds a symbol index across the translation units of a
+// compilation database, keyed by USR.
+//
+// Every declaration, definition and reference found by walking the
+// translation units, macros included, is recorded as an occurrence of the symbol it refers to,
+// with its location and the function containing it. Headers included by
+// several translation units contribute their occurrences once. The index is
+// saved to and loaded from a compact file, so it can be queried without
+// parsing anything again.
+package clangindex
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Role tells how an occurrence refers to its symbol.
+type Role uint8
+
+const (
+	Declaration Role = iota
+	Definition
+	Reference
+)
+
+func (r Role) String() string {
+	switch r {
+	case Declaration:
+		return "declaration"
+	case Definition:
+		return "definition"
+	case Reference:
+		return "reference"
+	}
+	return "unknown"
+}
+
+// Occurrence is a location where a symbol appears.
+type Occurrence struct {
+	Role      Role
+	File      string
+	Line      uint32
+	Column    uint32
+	Container string // USR of the function containing the occurrence, if any
+}
+
+// Symbol is an entity of the program, identified by its USR.
+type Symbol struct {
+	USR         string
+	Name        string
+	Kind        cursorkind.Kind
+	Linkage     clang.LinkageKind
+	Occurrences []Occurrence // sorted by file, line and column
+}
+
+// Index holds the symbols of a set of translation units.
+type Index struct {
+	Symbols map[string]*Symbol // keyed by USR
+	Files   []string           // the sources indexed, in order
+
+	seen map[*Symbol]map[Occurrence]bool
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{Symbols: make(map[string]*Symbol)}
+}
+
+// Options control what is indexed.
+type Options struct {
+	// Additional arguments passed to clang for every parse.
+	Args []string
+
+	// Occurrences located in system headers are only recorded when this is
+	// set. References from other files to symbols of system headers are
+	// always recorded.
+	System bool
+}
+
+// Build parses every compile command and returns the index of their
+// symbols, along with the problems met.
+func Build(cmds []clang.CompileCommand, opts Options) (*Index, []string) {
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	x := New()
+	var problems []string
+	for _, cmd := range cmds {
+		source, args := cmd.ParseArgs()
+		args = append(args, opts.Args...)
+		// The macro definitions and expansions are only in the detailed
+		// preprocessing record.
+		tu := idx.ParseTranslationUnit(source, args, nil, clang.TranslationUnit_DetailedPreprocessingRecord)
+		if !tu.IsValid() {
+			problems = append(problems, "parsing "+source+" failed")
+			continue
+		}
+		for _, d := range tu.Diagnostics() {
+			if d.Severity() >= clang.Diagnostic_Error {
+				problems = append(problems, d.Spelling())
+			}
+		}
+		x.Add(tu, opts)
+		x.Files = append(x.Files, source)
+		tu.Dispose()
+	}
+	x.Sort()
+	return x, problems
+}
+
+// Add records the occurrences of the translation unit. Sort must be called
+// once all translation units are added.
+func (x *Index) Add(tu clang.TranslationUnit, opts Options) {
+	if x.seen == nil {
+		x.seen = make(map[*Symbol]map[Occurrence]bool)
+		for _, s := range x.Symbols {
+			x.seen[s] = make(map[Occurrence]bool)
+			for _, o := range s.Occurrences {
+				x.seen[s][o] = true
+			}
+		}
+	}
+	x.visit(tu.TranslationUnitCursor(), "", opts)
+}
+
+// visit records the occurrences below cursor, which are contained in the
+// function with the USR container.
+func (x *Index) visit(cursor clang.Cursor, container string, opts Options) {
+	cursor.Visit(func(c, parent clang.Cursor) clang.ChildVisitResult {
+		loc := c.Location()
+		if !opts.System && loc.IsInSystemHeader() {
+			return clang.ChildVisit_Continue
+		}
+
+		kind := c.Kind()
+		inner := container
+		switch {
+		case kind.IsDeclaration():
+			role := Declaration
+			if c.IsCursorDefinition() {
+				role = Definition
+			}
+			x.record(c, role, loc, container)
+			switch kind {
+			case cursorkind.FunctionDecl, cursorkind.CXXMethod, cursorkind.Constructor, cursorkind.Destructor,
+				cursorkind.ConversionFunction, cursorkind.FunctionTemplate, cursorkind.ObjCInstanceMethodDecl,
+				cursorkind.ObjCClassMethodDecl:
+				inner = c.USR()
+			}
+		case kind == cursorkind.MacroDefinition:
+			x.record(c, Definition, loc, container)
+		case kind.IsReference(), kind == cursorkind.DeclRefExpr, kind == cursorkind.MemberRefExpr,
+			kind == cursorkind.MacroExpansion:
+			if ref := c.Referenced(); !ref.IsNull() {
+				x.record(ref, Reference, loc, container)
+			}
+		}
+		x.visit(c, inner, opts)
+		return clang.ChildVisit_Continue
+	})
+}
+
+// record adds an occurrence at loc of the symbol declared by decl.
+func (x *Index) record(decl clang.Cursor, role Role, loc clang.SourceLocation, container string) {
+	usr := decl.USR()
+	if usr == "" {
+		return
+	}
+	file, line, column, _ := loc.FileLocation()
+	name := file.Name()
+	if name == "" {
+		return
+	}
+
+	s, ok := x.Symbols[usr]
+	if !ok {
+		s = &Symbol{
+			USR:     usr,
+			Name:    decl.Spelling(),
+			Kind:    decl.Kind(),
+			Linkage: decl.Linkage(),
+		}
+		x.Symbols[usr] = s
+		x.seen[s] = make(map[Occurrence]bool)
+	}
+	o := Occurrence{
+		Role:      role,
+		File:      name,
+		Line:      line,
+		Column:    column,
+		Container: container,
+	}
+	if !x.seen[s][o] {
+		x.seen[s][o] = true
+		s.Occurrences = append(s.Occurrences, o)
+	}
+}
+
+// Sort orders the occurrences of every symbol by location. It is to be
+// called after adding translation units with Add.
+func (x *Index) Sort() {
+	for _, s := range x.Symbols {
+		sortOccurrences(s.Occurrences)
+	}
+	x.seen = nil
+}
+
+func sortOccurrences(occurrences []Occurrence) {
+	sort.Slice(occurrences, func(i, j int) bool {
+		a, b := occurrences[i], occurrences[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Role < b.Role
+	})
+}
+
+// Lookup returns the symbols whose USR or name is the given one, sorted by
+// USR.
+func (x *Index) Lookup(usrOrName string) []*Symbol {
+	if s, ok := x.Symbols[usrOrName]; ok {
+		return []*Symbol{s}
+	}
+	var r []*Symbol
+	for _, s := range x.Symbols {
+		if s.Name == usrOrName {
+			r = append(r, s)
+		}
+	}
+	sortSymbols(r)
+	return r
+}
+
+// Match returns the symbols whose name matches the regular expression,
+// sorted by name and USR.
+func (x *Index) Match(re *regexp.Regexp) []*Symbol {
+	var r []*Symbol
+	for _, s := range x.Symbols {
+		if re.MatchString(s.Name) {
+			r = append(r, s)
+		}
+	}
+	sortSymbols(r)
+	return r
+}
+
+func sortSymbols(ss []*Symbol) {
+	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].Name != ss[j].Name {
+			return ss[i].Name < ss[j].Name
+		}
+		return ss[i].USR < ss[j].USR
+	})
+}
+
+// Filter returns the occurrences of the symbol with one of the roles.
+func (s *Symbol) Filter(roles ...Role) []Occurrence {
+	var r []Occurrence
+	for _, o := range s.Occurrences {
+		for _, role := range roles {
+			if o.Role == role {
+				r = append(r, o)
+				break
+			}
+		}
+	}
+	return r
+}
+
+// Definitions returns where the symbol is defined, or declared when it has no
+// definition in the index.
+func (s *Symbol) Definitions() []Occurrence {
+	if r := s.Filter(Definition); len(r) > 0 {
+		return r
+	}
+	return s.Filter(Declaration)
+}
+
+// References returns where the symbol is referred to.
+func (s *Symbol) References() []Occurrence {
+	return s.Filter(Reference)
+}
@@ -0,0 +1,118 @@
+package clangindex
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+func TestWriteRead(t *testing.T) {
+	x := New()
+	x.Files = []string{"a.c", "b.c"}
+	x.Symbols["c:@F@main"] = &Symbol{
+		USR:     "c:@F@main",
+		Name:    "main",
+		Kind:    cursorkind.FunctionDecl,
+		Linkage: clang.Linkage_External,
+		Occurrences: []Occurrence{
+			{Role: Definition, File: "a.c", Line: 3, Column: 5},
+		},
+	}
+	x.Symbols["c:@F@foo"] = &Symbol{
+		USR:     "c:@F@foo",
+		Name:    "foo",
+		Kind:    cursorkind.FunctionDecl,
+		Linkage: clang.Linkage_External,
+		Occurrences: []Occurrence{
+			{Role: Declaration, File: "a.h", Line: 1, Column: 6},
+			{Role: Reference, File: "a.c", Line: 4, Column: 2, Container: "c:@F@main"},
+			{Role: Definition, File: "b.c", Line: 1, Column: 6},
+		},
+	}
+
+	var b bytes.Buffer
+	if err := x.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	y, err := Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(x, y) {
+		t.Errorf("read %+v, want %+v", y, x)
+	}
+
+	if _, err := Read(bytes.NewBufferString("something else")); err == nil {
+		t.Error("no error reading something else")
+	}
+
+	foo := y.Lookup("foo")
+	if len(foo) != 1 || !reflect.DeepEqual(foo, y.Lookup("c:@F@foo")) {
+		t.Fatalf("Lookup(foo) = %v", foo)
+	}
+	if defs := foo[0].Definitions(); len(defs) != 1 || defs[0].File != "b.c" {
+		t.Errorf("definitions of foo %v", defs)
+	}
+	if refs := foo[0].References(); len(refs) != 1 || refs[0].Container != "c:@F@main" {
+		t.Errorf("references to foo %v", refs)
+	}
+	if m := y.Match(regexp.MustCompile("o")); len(m) != 1 || m[0].Name != "foo" {
+		t.Errorf("Match(o) = %v", m)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testdata, err := filepath.Abs("../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cmds []clang.CompileCommand
+	for _, file := range []string{"globals.c", "struct.c", "macros.c"} {
+		cmds = append(cmds, clang.CompileCommand{
+			Directory: testdata,
+			Filename:  file,
+			Args:      []string{"cc", "-c", file},
+		})
+	}
+	x, problems := Build(cmds, Options{})
+	for _, p := range problems {
+		t.Log("PROBLEM:", p)
+	}
+
+	bar := x.Lookup("bar")
+	if len(bar) != 1 {
+		t.Fatalf("Lookup(bar) = %v", bar)
+	}
+	if bar[0].Linkage != clang.Linkage_Internal {
+		t.Errorf("bar has linkage %v", bar[0].Linkage)
+	}
+	refs := bar[0].References()
+	if len(refs) != 1 || refs[0].Line != 20 || x.Symbols[refs[0].Container].Name != "main" {
+		t.Errorf("references to bar %+v", refs)
+	}
+
+	add := x.Lookup("add")
+	if len(add) != 1 || len(add[0].Filter(Declaration)) != 1 || len(add[0].Definitions()) != 1 {
+		t.Errorf("Lookup(add) = %+v", add)
+	}
+	if len(x.Lookup("printf")) != 1 {
+		t.Error("reference to printf not indexed")
+	}
+
+	// Macros are defined and expanded.
+	size := x.Lookup("DOUBLE_SIZE")
+	if len(size) != 1 || size[0].Kind != cursorkind.MacroDefinition {
+		t.Fatalf("Lookup(DOUBLE_SIZE) = %+v", size)
+	}
+	if defs := size[0].Definitions(); len(defs) != 1 || defs[0].Line != 4 {
+		t.Errorf("definitions of DOUBLE_SIZE %+v", defs)
+	}
+	if refs := size[0].References(); len(refs) != 1 || refs[0].Line != 11 || filepath.Base(refs[0].File) != "macros.c" {
+		t.Errorf("references to DOUBLE_SIZE %+v", refs)
+	}
+}
@@ -0,0 +1,191 @@
+package clangindex
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// magic starts every index file, followed by the gzip compressed gob encoding
+// of a stored value.
+const magic = "go-clang-index 1\n"
+
+// stored is the index as written to disk. USRs, names and file names are
+// kept once in a string table, and the occurrences of a symbol are stored as
+// columns, which gob encodes as compact varints.
+type stored struct {
+	Strings []string
+	Files   []int // into Strings
+	Symbols []storedSymbol
+}
+
+type storedSymbol struct {
+	USR     int // into Strings
+	Name    int // into Strings
+	Kind    cursorkind.Kind
+	Linkage clang.LinkageKind
+
+	// One entry per occurrence.
+	Roles      []byte
+	Files      []int // into Strings
+	Lines      []uint32
+	Columns    []uint32
+	Containers []int // 1 + index into Strings, 0 for none
+}
+
+// Write writes the index in its compact form.
+func (x *Index) Write(w io.Writer) error {
+	var s stored
+	ids := make(map[string]int)
+	id := func(str string) int {
+		i, ok := ids[str]
+		if !ok {
+			i = len(s.Strings)
+			ids[str] = i
+			s.Strings = append(s.Strings, str)
+		}
+		return i
+	}
+
+	for _, f := range x.Files {
+		s.Files = append(s.Files, id(f))
+	}
+	var usrs []string
+	for usr := range x.Symbols {
+		usrs = append(usrs, usr)
+	}
+	sort.Strings(usrs)
+	for _, usr := range usrs {
+		sym := x.Symbols[usr]
+		ss := storedSymbol{
+			USR:     id(sym.USR),
+			Name:    id(sym.Name),
+			Kind:    sym.Kind,
+			Linkage: sym.Linkage,
+		}
+		for _, o := range sym.Occurrences {
+			ss.Roles = append(ss.Roles, byte(o.Role))
+			ss.Files = append(ss.Files, id(o.File))
+			ss.Lines = append(ss.Lines, o.Line)
+			ss.Columns = append(ss.Columns, o.Column)
+			container := 0
+			if o.Container != "" {
+				container = 1 + id(o.Container)
+			}
+			ss.Containers = append(ss.Containers, container)
+		}
+		s.Symbols = append(s.Symbols, ss)
+	}
+
+	if _, err := io.WriteString(w, magic); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(&s); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Read reads an index written by Write.
+func Read(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, header); err != nil || string(header) != magic {
+		return nil, fmt.Errorf("not a go-clang-index file")
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	var s stored
+	if err := gob.NewDecoder(zr).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	str := func(i int) (string, error) {
+		if i < 0 || i >= len(s.Strings) {
+			return "", fmt.Errorf("string %d out of range", i)
+		}
+		return s.Strings[i], nil
+	}
+
+	x := New()
+	for _, i := range s.Files {
+		f, err := str(i)
+		if err != nil {
+			return nil, err
+		}
+		x.Files = append(x.Files, f)
+	}
+	for _, ss := range s.Symbols {
+		n := len(ss.Roles)
+		if len(ss.Files) != n || len(ss.Lines) != n || len(ss.Columns) != n || len(ss.Containers) != n {
+			return nil, fmt.Errorf("inconsistent occurrence columns")
+		}
+		sym := &Symbol{Kind: ss.Kind, Linkage: ss.Linkage}
+		if sym.USR, err = str(ss.USR); err != nil {
+			return nil, err
+		}
+		if sym.Name, err = str(ss.Name); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			o := Occurrence{
+				Role:   Role(ss.Roles[i]),
+				Line:   ss.Lines[i],
+				Column: ss.Columns[i],
+			}
+			if o.File, err = str(ss.Files[i]); err != nil {
+				return nil, err
+			}
+			if c := ss.Containers[i]; c != 0 {
+				if o.Container, err = str(c - 1); err != nil {
+					return nil, err
+				}
+			}
+			sym.Occurrences = append(sym.Occurrences, o)
+		}
+		x.Symbols[sym.USR] = sym
+	}
+	return x, nil
+}
+
+// WriteFile writes the index to the named file.
+func (x *Index) WriteFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if err := x.Write(bw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFile reads the index from the named file.
+func ReadFile(name string) (*Index, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	x, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return x, nil
+}
@@ -0,0 +1,255 @@
+// Package clangmacros reports on the macros of a set of translation units:
+// where each is defined and expanded, which are never used, which macros the
+// definition of another one uses, and which function-like macros could be
+// inline functions instead.
+//
+// libclang only exposes macro definitions and expansions when the translation
+// units are parsed with TranslationUnit_DetailedPreprocessingRecord, which
+// Build does. Expansions nested in the expansion of another macro are not
+// always recorded by libclang; the dependency graph, made from the definition
+// tokens, accounts for them. A macro only tested by #ifdef or defined() is
+// not expanded, so it shows up as unused, except for include guards.
+package clangmacros
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Site is a location in a file.
+type Site struct {
+	File   string `json:"file"`
+	Line   uint32 `json:"line"`
+	Column uint32 `json:"column"`
+}
+
+func (s Site) String() string {
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Line, s.Column)
+}
+
+// Macro is a macro definition. A macro defined several times has a Macro
+// per definition.
+type Macro struct {
+	Name         string   `json:"name"`
+	Site         Site     `json:"site"` // of the name in the definition
+	FunctionLike bool     `json:"functionLike"`
+	Params       []string `json:"params,omitempty"`
+	Body         []string `json:"body"`  // the tokens of the replacement list
+	Guard        bool     `json:"guard"` // an include guard
+	Expansions   []Site   `json:"expansions"`
+	Uses         []string `json:"uses"`   // macros named in the body, sorted
+	UsedBy       []string `json:"usedBy"` // macros whose body names this one, sorted
+
+	// Whether a function-like macro could be an inline function, and why
+	// not or what makes it worth it, e.g. "evaluates x more than once".
+	Inline bool   `json:"inline"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Unused reports whether the macro is neither expanded nor named by another
+// macro, and is not an include guard.
+func (m *Macro) Unused() bool {
+	return !m.Guard && len(m.Expansions) == 0 && len(m.UsedBy) == 0
+}
+
+// Set holds the macros of a set of translation units.
+type Set struct {
+	Macros map[Site]*Macro // keyed by the site of their definition
+
+	seen map[Site]bool // expansions
+}
+
+// New returns an empty set.
+func New() *Set {
+	return &Set{Macros: make(map[Site]*Macro)}
+}
+
+// Options control what is reported.
+type Options struct {
+	// Additional arguments passed to clang for every parse.
+	Args []string
+
+	// The macros of system headers, and the expansions located in them, are
+	// only recorded when this is set.
+	System bool
+}
+
+// Build parses every compile command and returns the set of their macros,
+// along with the problems met.
+func Build(cmds []clang.CompileCommand, opts Options) (*Set, []string) {
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	s := New()
+	var problems []string
+	for _, cmd := range cmds {
+		source, args := cmd.ParseArgs()
+		args = append(args, opts.Args...)
+		tu := idx.ParseTranslationUnit(source, args, nil, clang.TranslationUnit_DetailedPreprocessingRecord)
+		if !tu.IsValid() {
+			problems = append(problems, "parsing "+source+" failed")
+			continue
+		}
+		for _, d := range tu.Diagnostics() {
+			if d.Severity() >= clang.Diagnostic_Error {
+				problems = append(problems, d.Spelling())
+			}
+		}
+		s.Add(tu, opts)
+		tu.Dispose()
+	}
+	s.Link()
+	return s, problems
+}
+
+func site(loc clang.SourceLocation) Site {
+	file, line, column, _ := loc.FileLocation()
+	return Site{File: file.Name(), Line: line, Column: column}
+}
+
+// Add records the macro definitions and expansions of the translation unit.
+// Link must be called once all translation units are added.
+func (s *Set) Add(tu clang.TranslationUnit, opts Options) {
+	if s.seen == nil {
+		s.seen = make(map[Site]bool)
+		for _, m := range s.Macros {
+			for _, e := range m.Expansions {
+				s.seen[e] = true
+			}
+		}
+	}
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		loc := cursor.Location()
+		if !opts.System && loc.IsInSystemHeader() {
+			return clang.ChildVisit_Continue
+		}
+		switch cursor.Kind() {
+		case cursorkind.MacroDefinition:
+			s.define(tu, cursor)
+		case cursorkind.MacroExpansion:
+			def := cursor.Referenced()
+			if def.IsNull() || def.IsMacroBuiltin() {
+				break
+			}
+			if !opts.System && def.Location().IsInSystemHeader() {
+				break
+			}
+			m := s.define(tu, def)
+			e := site(loc)
+			if m != nil && !s.seen[e] {
+				s.seen[e] = true
+				m.Expansions = append(m.Expansions, e)
+			}
+		}
+		// Preprocessing entities are all found at the top level.
+		return clang.ChildVisit_Continue
+	})
+}
+
+// define returns the macro defined by the MacroDefinition cursor, adding it if
+// needed.
+func (s *Set) define(tu clang.TranslationUnit, def clang.Cursor) *Macro {
+	if def.IsMacroBuiltin() {
+		return nil
+	}
+	at := site(def.Location())
+	if at.File == "" {
+		// Defined on the command line.
+		return nil
+	}
+	if m, ok := s.Macros[at]; ok {
+		return m
+	}
+	var tokens []string
+	for _, t := range tu.Tokenize(def.Extent()) {
+		tokens = append(tokens, tu.TokenSpelling(t))
+	}
+	m := &Macro{
+		Name:         def.Spelling(),
+		Site:         at,
+		FunctionLike: def.IsMacroFunctionLike(),
+		Expansions:   []Site{},
+	}
+	m.Params, m.Body = split(tokens, m.FunctionLike)
+	if len(m.Body) == 0 && !m.FunctionLike {
+		file := tu.File(at.File)
+		m.Guard = tu.IsFileMultipleIncludeGuarded(file) && isGuard(tu.FileContents(file), m.Name)
+	}
+	s.Macros[at] = m
+	return m
+}
+
+// Link relates the macros to the macros named in their bodies, sorts the
+// expansions and decides which macros could be inline functions. It is to be
+// called after adding translation units with Add.
+func (s *Set) Link() {
+	byName := make(map[string][]*Macro)
+	for _, m := range s.Macros {
+		byName[m.Name] = append(byName[m.Name], m)
+	}
+	usedBy := make(map[string]map[string]bool)
+	for _, m := range s.Macros {
+		uses := make(map[string]bool)
+		for _, name := range identifiers(m.Body, m.Params) {
+			if _, ok := byName[name]; ok && name != m.Name {
+				uses[name] = true
+				if usedBy[name] == nil {
+					usedBy[name] = make(map[string]bool)
+				}
+				usedBy[name][m.Name] = true
+			}
+		}
+		m.Uses = sortedKeys(uses)
+		m.Inline, m.Reason = inline(m)
+	}
+	for _, m := range s.Macros {
+		m.UsedBy = sortedKeys(usedBy[m.Name])
+		sort.Slice(m.Expansions, func(i, j int) bool {
+			return less(m.Expansions[i], m.Expansions[j])
+		})
+	}
+	s.seen = nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	r := []string{}
+	for k := range set {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func less(a, b Site) bool {
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// Sorted returns the macros by site.
+func (s *Set) Sorted() []*Macro {
+	var r []*Macro
+	for _, m := range s.Macros {
+		r = append(r, m)
+	}
+	sort.Slice(r, func(i, j int) bool { return less(r[i].Site, r[j].Site) })
+	return r
+}
+
+// Lookup returns the definitions of the named macro, sorted by site.
+func (s *Set) Lookup(name string) []*Macro {
+	var r []*Macro
+	for _, m := range s.Sorted() {
+		if m.Name == name {
+			r = append(r, m)
+		}
+	}
+	return r
+}
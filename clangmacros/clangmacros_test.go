@@ -0,0 +1,157 @@
+package clangmacros
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+// macro returns the macro defined at line of m.h by definition, whose tokens
+// are separated by spaces.
+func macro(line uint32, definition string, functionLike bool) *Macro {
+	tokens := strings.Fields(definition)
+	m := &Macro{Name: tokens[0], FunctionLike: functionLike, Site: Site{File: "m.h", Line: line}}
+	m.Params, m.Body = split(tokens, functionLike)
+	return m
+}
+
+func TestDefinitions(t *testing.T) {
+	for _, tc := range []struct {
+		definition   string
+		functionLike bool
+		params       []string
+		inline       bool
+		reason       string
+	}{
+		{"SIZE 16", false, nil, false, ""},
+		{"SQUARE ( x ) ( ( x ) * ( x ) )", true, []string{"x"}, true, "evaluates x more than once"},
+		{"MAX ( a , b ) ( ( a ) > ( b ) ? ( a ) : ( b ) )", true, []string{"a", "b"}, true, "evaluates a, b more than once"},
+		{"NEG ( a ) ( - ( a ) )", true, []string{"a"}, true, ""},
+		{"NOW ( ) time ( 0 )", true, nil, true, ""},
+		{"NAME ( x ) # x", true, []string{"x"}, false, "stringizes or pastes tokens"},
+		{"LOG ( ... ) printf ( __VA_ARGS__ )", true, []string{"..."}, false, "is variadic"},
+		{"CHECK ( x ) do { if ( ! ( x ) ) return - 1 ; } while ( 0 )", true, []string{"x"}, false, "changes the control flow of the caller with return"},
+		{"WHERE ( ) __LINE__", true, nil, false, "uses __LINE__ of the caller"},
+		{"NOTHING ( x )", true, []string{"x"}, false, "has an empty replacement list"},
+		{"UINT ( ) unsigned int", true, nil, false, "expands to a type"},
+		{"OPEN ( x ) { ( x", true, []string{"x"}, false, "has an unbalanced replacement list"},
+	} {
+		m := macro(1, tc.definition, tc.functionLike)
+		inline, reason := inline(m)
+		if !reflect.DeepEqual(m.Params, tc.params) || inline != tc.inline || reason != tc.reason {
+			t.Errorf("%s: params %q, inline %v, %q, want %q, %v, %q",
+				tc.definition, m.Params, inline, reason, tc.params, tc.inline, tc.reason)
+		}
+	}
+}
+
+func TestIsGuard(t *testing.T) {
+	for _, tc := range []struct {
+		contents string
+		want     bool
+	}{
+		{"#ifndef A_H\n#define A_H\n#endif\n", true},
+		{"// a.h\n/* some\n   comment */\n\n#  ifndef A_H\n#define A_H\n#endif\n", true},
+		{"#ifndef B_H\n#define A_H\n#endif\n", false},
+		{"int x;\n#ifndef A_H\n#define A_H\n#endif\n", false},
+	} {
+		if got := isGuard([]byte(tc.contents), "A_H"); got != tc.want {
+			t.Errorf("isGuard(%q) = %v", tc.contents, got)
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	s := New()
+	for _, m := range []*Macro{
+		macro(1, "SIZE 16", false),
+		macro(2, "DOUBLE ( 2 * SIZE )", false),
+		macro(3, "SQUARE ( x ) ( ( x ) * ( x ) )", true),
+		macro(4, "UNUSED 1", false),
+		macro(5, "AREA ( x ) SQUARE ( x ) * SIZE", true),
+	} {
+		s.Macros[m.Site] = m
+	}
+	s.Lookup("DOUBLE")[0].Expansions = []Site{{File: "a.c", Line: 3}, {File: "a.c", Line: 1}}
+	s.Link()
+
+	if d := s.Lookup("DOUBLE")[0]; !reflect.DeepEqual(d.Uses, []string{"SIZE"}) || d.Expansions[0].Line != 1 {
+		t.Errorf("DOUBLE %+v", d)
+	}
+	if size := s.Lookup("SIZE")[0]; !reflect.DeepEqual(size.UsedBy, []string{"AREA", "DOUBLE"}) || size.Unused() {
+		t.Errorf("SIZE %+v", size)
+	}
+	var unused []string
+	for _, m := range s.Sorted() {
+		if m.Unused() {
+			unused = append(unused, m.Name)
+		}
+	}
+	if want := []string{"UNUSED", "AREA"}; !reflect.DeepEqual(unused, want) {
+		t.Errorf("unused %q, want %q", unused, want)
+	}
+
+	var dot bytes.Buffer
+	if err := s.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"AREA" -> "SQUARE";`,
+		`"DOUBLE" [label="DOUBLE\n2 expansions"];`,
+		`"SQUARE" [label="SQUARE\n0 expansions", color=blue];`,
+		`"UNUSED" [label="UNUSED\n0 expansions", style=dashed];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output lacks %s:\n%s", want, dot.String())
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testdata, err := filepath.Abs("../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := []clang.CompileCommand{{
+		Directory: testdata,
+		Filename:  "macros.c",
+		Args:      []string{"cc", "-c", "macros.c"},
+	}}
+	s, problems := Build(cmds, Options{})
+	for _, p := range problems {
+		t.Error("PROBLEM:", p)
+	}
+
+	var names []string
+	for _, m := range s.Sorted() {
+		names = append(names, m.Name)
+	}
+	if want := []string{"SIZE", "DOUBLE_SIZE", "UNUSED", "SQUARE", "NAME", "CHECK", "MAX"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("macros %q, want %q", names, want)
+	}
+	for _, tc := range []struct {
+		name       string
+		expansions int
+		unused     bool
+		inline     bool
+	}{
+		{"DOUBLE_SIZE", 1, false, false},
+		{"UNUSED", 0, true, false},
+		{"SQUARE", 1, false, true},
+		{"NAME", 1, false, false},
+		{"CHECK", 1, false, false},
+		{"MAX", 1, false, true},
+	} {
+		m := s.Lookup(tc.name)[0]
+		if len(m.Expansions) != tc.expansions || m.Unused() != tc.unused || m.Inline != tc.inline {
+			t.Errorf("%s has %d expansions, unused %v, inline %v", tc.name, len(m.Expansions), m.Unused(), m.Inline)
+		}
+	}
+	if size := s.Lookup("SIZE")[0]; !reflect.DeepEqual(size.UsedBy, []string{"DOUBLE_SIZE"}) || len(size.Expansions) == 0 {
+		t.Errorf("SIZE %+v", size)
+	}
+}
@@ -0,0 +1,158 @@
+package clangmacros
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// split separates the tokens of a macro definition, starting with the name of
+// the macro, into the parameters and the replacement list.
+func split(tokens []string, functionLike bool) ([]string, []string) {
+	if len(tokens) == 0 {
+		return nil, []string{}
+	}
+	tokens = tokens[1:]
+	var params []string
+	if functionLike && len(tokens) > 0 && tokens[0] == "(" {
+		i := 1
+		for ; i < len(tokens) && tokens[i] != ")"; i++ {
+			if tokens[i] != "," {
+				params = append(params, tokens[i])
+			}
+		}
+		if i < len(tokens) {
+			i++
+		}
+		tokens = tokens[i:]
+	}
+	body := append([]string{}, tokens...)
+	return params, body
+}
+
+func isIdentifier(token string) bool {
+	if token == "" {
+		return false
+	}
+	c := token[0]
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// identifiers returns the identifiers of the body that are not parameters,
+// in order, without duplicates.
+func identifiers(body, params []string) []string {
+	skip := make(map[string]bool)
+	for _, p := range params {
+		skip[p] = true
+	}
+	var r []string
+	for _, t := range body {
+		if isIdentifier(t) && !skip[t] {
+			skip[t] = true
+			r = append(r, t)
+		}
+	}
+	return r
+}
+
+// isGuard reports whether the contents of a file start, comments and blank
+// lines aside, with #ifndef name, as include guards do.
+func isGuard(contents []byte, name string) bool {
+	sc := bufio.NewScanner(bytes.NewReader(contents))
+	comment := false
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if comment {
+			end := strings.Index(line, "*/")
+			if end < 0 {
+				continue
+			}
+			comment = false
+			line = strings.TrimSpace(line[end+2:])
+		}
+		if strings.HasPrefix(line, "/*") && !strings.Contains(line, "*/") {
+			comment = true
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "/*") {
+			continue
+		}
+		f := strings.Fields(strings.TrimPrefix(line, "#"))
+		return strings.HasPrefix(line, "#") && len(f) >= 2 && f[0] == "ifndef" && f[1] == name
+	}
+	return false
+}
+
+// inline decides whether a function-like macro could be an inline function.
+// It tells why not, or what would be gained, as a phrase about the macro such
+// as "is variadic".
+func inline(m *Macro) (bool, string) {
+	if !m.FunctionLike {
+		return false, ""
+	}
+	if len(m.Body) == 0 {
+		return false, "has an empty replacement list"
+	}
+	for _, p := range m.Params {
+		if p == "..." || strings.HasSuffix(p, "...") {
+			return false, "is variadic"
+		}
+	}
+	depth := 0
+	for _, t := range m.Body {
+		switch t {
+		case "#", "##":
+			return false, "stringizes or pastes tokens"
+		case "return", "break", "continue", "goto":
+			return false, "changes the control flow of the caller with " + t
+		case "__FILE__", "__LINE__", "__func__", "__FUNCTION__":
+			return false, "uses " + t + " of the caller"
+		case "(", "{", "[":
+			depth++
+		case ")", "}", "]":
+			depth--
+			if depth < 0 {
+				return false, "has an unbalanced replacement list"
+			}
+		}
+	}
+	if depth != 0 {
+		return false, "has an unbalanced replacement list"
+	}
+	// A statement is fine as the body of a void function.
+	statement := m.Body[0] == "{" || len(m.Body) > 1 && m.Body[0] == "do" && m.Body[1] == "{"
+	if !statement && isType(m.Body) {
+		return false, "expands to a type"
+	}
+	if len(m.Params) == 0 {
+		return true, ""
+	}
+	var twice []string
+	counts := make(map[string]int)
+	for _, t := range m.Body {
+		counts[t]++
+	}
+	for _, p := range m.Params {
+		if counts[p] > 1 {
+			twice = append(twice, p)
+		}
+	}
+	if len(twice) > 0 {
+		return true, "evaluates " + strings.Join(twice, ", ") + " more than once"
+	}
+	return true, ""
+}
+
+// isType reports whether the body looks like a type rather than an
+// expression: made of type keywords, qualifiers and stars only.
+func isType(body []string) bool {
+	for _, t := range body {
+		switch t {
+		case "void", "char", "short", "int", "long", "float", "double", "signed", "unsigned",
+			"const", "volatile", "struct", "union", "enum", "*":
+		default:
+			return false
+		}
+	}
+	return true
+}
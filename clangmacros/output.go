@@ -0,0 +1,57 @@
+package clangmacros
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteDOT writes the dependency graph of the macros in the Graphviz DOT
+// language: an edge goes from a macro to every macro its body names. Macros
+// are identified by name, so the definitions of a macro defined several times
+// make a single node. Unused macros are dashed and the macros that could be
+// inline functions are blue.
+func (s *Set) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph macros {\n")
+	fmt.Fprintf(bw, "\tnode [shape=box];\n")
+	done := make(map[string]bool)
+	for _, m := range s.Sorted() {
+		if done[m.Name] || m.Guard {
+			continue
+		}
+		done[m.Name] = true
+		attrs := ""
+		if m.Unused() {
+			attrs += ", style=dashed"
+		}
+		if m.Inline {
+			attrs += ", color=blue"
+		}
+		fmt.Fprintf(bw, "\t%q [label=\"%s\\n%d expansions\"%s];\n", m.Name, m.Name, s.expansions(m.Name), attrs)
+	}
+	edges := make(map[[2]string]bool)
+	for _, m := range s.Sorted() {
+		for _, u := range m.Uses {
+			e := [2]string{m.Name, u}
+			if !edges[e] {
+				edges[e] = true
+				fmt.Fprintf(bw, "\t%q -> %q;\n", m.Name, u)
+			}
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// expansions returns the number of expansions of all the definitions of the
+// named macro.
+func (s *Set) expansions(name string) int {
+	n := 0
+	for _, m := range s.Macros {
+		if m.Name == name {
+			n += len(m.Expansions)
+		}
+	}
+	return n
+}
@@ -0,0 +1,383 @@
+// Package clangmatch matches cursors with combinators in the manner of the
+// AST matchers of clang, so checks can be written as declarations rather than
+// as visitors:
+//
+//	m := clangmatch.FunctionDecl(
+//		clangmatch.HasName("foo"),
+//		clangmatch.HasDescendant(clangmatch.Bind("call",
+//			clangmatch.CallExpr(clangmatch.Callee(clangmatch.HasName("malloc"))))))
+//	for _, r := range clangmatch.Find(m, tu.TranslationUnitCursor()) {
+//		call := r.Bindings["call"]
+//		...
+//	}
+//
+// A matcher built from others matches when they all match. Bind records the
+// cursor a matcher matched under a name; the bindings of the matchers that
+// end up not matching are dropped, so a result only holds the cursors of the
+// successful match.
+//
+// The parent and ancestors of a cursor are those of the traversal when the
+// cursor is reached by Find, HasChild or HasDescendant, and its semantic
+// parents otherwise, as for the cursor References or Callee refers to. The
+// argument HasArgument reaches has the call for its parent.
+package clangmatch
+
+import (
+	"regexp"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+// Matcher matches a cursor.
+type Matcher func(s *state, c clang.Cursor) bool
+
+// Bindings holds the cursors bound by Bind, by name.
+type Bindings map[string]clang.Cursor
+
+// Result is a cursor matched by Find, along with the cursors bound.
+type Result struct {
+	Cursor   clang.Cursor
+	Bindings Bindings
+}
+
+type binding struct {
+	name   string
+	cursor clang.Cursor
+}
+
+// state is the state of a match: the cursors bound so far and the cursors
+// of the traversal leading to the cursor matched.
+type state struct {
+	bound []binding
+	path  []clang.Cursor
+}
+
+// try matches c, dropping what m bound if it does not match.
+func (s *state) try(m Matcher, c clang.Cursor) bool {
+	n := len(s.bound)
+	if m(s, c) {
+		return true
+	}
+	s.bound = s.bound[:n]
+	return false
+}
+
+func (s *state) bindings() Bindings {
+	b := make(Bindings, len(s.bound))
+	for _, x := range s.bound {
+		b[x.name] = x.cursor
+	}
+	return b
+}
+
+// Match reports whether m matches the cursor, with what it bound.
+func Match(m Matcher, c clang.Cursor) (Bindings, bool) {
+	s := &state{}
+	if !s.try(m, c) {
+		return nil, false
+	}
+	return s.bindings(), true
+}
+
+// Find returns the matches of m among root and its descendants, in the
+// order of a depth first traversal.
+func Find(m Matcher, root clang.Cursor) []Result {
+	var r []Result
+	s := &state{}
+	var visit func(c clang.Cursor)
+	visit = func(c clang.Cursor) {
+		s.bound = s.bound[:0]
+		if s.try(m, c) {
+			r = append(r, Result{Cursor: c, Bindings: s.bindings()})
+		}
+		s.path = append(s.path, c)
+		c.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+			visit(cursor)
+			return clang.ChildVisit_Continue
+		})
+		s.path = s.path[:len(s.path)-1]
+	}
+	visit(root)
+	return r
+}
+
+// Func returns a matcher of the cursors f returns true for.
+func Func(f func(c clang.Cursor) bool) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return f(c)
+	}
+}
+
+// Bind returns a matcher of the cursors m matches, which binds them to name.
+func Bind(name string, m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		if !m(s, c) {
+			return false
+		}
+		s.bound = append(s.bound, binding{name, c})
+		return true
+	}
+}
+
+// Anything matches every cursor.
+func Anything() Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return true
+	}
+}
+
+// AllOf matches the cursors every matcher matches.
+func AllOf(ms ...Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		n := len(s.bound)
+		for _, m := range ms {
+			if !m(s, c) {
+				s.bound = s.bound[:n]
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf matches the cursors one of the matchers matches, binding what the
+// first one matching binds.
+func AnyOf(ms ...Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		for _, m := range ms {
+			if s.try(m, c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the cursors m does not match. It binds nothing.
+func Not(m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		n := len(s.bound)
+		matched := m(s, c)
+		s.bound = s.bound[:n]
+		return !matched
+	}
+}
+
+// Kind matches the cursors of the kind that all the matchers match.
+func Kind(kind cursorkind.Kind, ms ...Matcher) Matcher {
+	all := AllOf(ms...)
+	return func(s *state, c clang.Cursor) bool {
+		return c.Kind() == kind && all(s, c)
+	}
+}
+
+// HasName matches the cursors spelled name.
+func HasName(name string) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return c.Spelling() == name
+	}
+}
+
+// MatchesName matches the cursors whose spelling matches the regular
+// expression. It panics if the expression does not compile.
+func MatchesName(expr string) Matcher {
+	re := regexp.MustCompile(expr)
+	return func(s *state, c clang.Cursor) bool {
+		return re.MatchString(c.Spelling())
+	}
+}
+
+// HasType matches the cursors whose type, or canonical type, is spelled
+// spelling.
+func HasType(spelling string) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		t := c.Type()
+		return t.Spelling() == spelling || t.CanonicalType().Spelling() == spelling
+	}
+}
+
+// HasTypeKind matches the cursors whose canonical type is of the kind.
+func HasTypeKind(kind typekind.Kind) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return c.Type().CanonicalType().Kind() == kind
+	}
+}
+
+// IsDefinition matches the cursors that are definitions.
+func IsDefinition() Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return c.IsCursorDefinition()
+	}
+}
+
+// IsInMainFile matches the cursors located in the main file.
+func IsInMainFile() Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return c.Location().IsFromMainFile()
+	}
+}
+
+// References matches the cursors that refer to a cursor m matches, as the
+// DeclRefExpr of a variable refers to the variable.
+func References(m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		r := c.Referenced()
+		if r.IsNull() || r.Equal(c) {
+			return false
+		}
+		return s.elsewhere(m, r)
+	}
+}
+
+// Callee matches the calls of a function m matches.
+func Callee(m Matcher) Matcher {
+	refs := References(m)
+	return func(s *state, c clang.Cursor) bool {
+		return c.Kind() == cursorkind.CallExpr && refs(s, c)
+	}
+}
+
+// ArgumentCountIs matches the calls and function declarations with n
+// arguments.
+func ArgumentCountIs(n int) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return int(c.NumArguments()) == n
+	}
+}
+
+// HasArgument matches the calls and function declarations whose argument i
+// m matches.
+func HasArgument(i int, m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		if i < 0 || i >= int(c.NumArguments()) {
+			return false
+		}
+		return s.under(m, c.Argument(uint32(i)), c)
+	}
+}
+
+// HasChild matches the cursors with a child m matches, binding what it binds
+// for the first one.
+func HasChild(m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return s.search(m, c, false)
+	}
+}
+
+// HasDescendant matches the cursors with a descendant m matches, binding what
+// it binds for the first one, in the order of a depth first traversal.
+func HasDescendant(m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		return s.search(m, c, true)
+	}
+}
+
+// search looks for a child, or a descendant when deep, of c that m matches.
+func (s *state) search(m Matcher, c clang.Cursor, deep bool) bool {
+	found := false
+	depth := len(s.path)
+	s.path = append(s.path, c)
+	var visit func(cursor, parent clang.Cursor) clang.ChildVisitResult
+	visit = func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if s.try(m, cursor) {
+			found = true
+			return clang.ChildVisit_Break
+		}
+		if deep {
+			s.path = append(s.path, cursor)
+			cursor.Visit(visit)
+			s.path = s.path[:len(s.path)-1]
+			if found {
+				return clang.ChildVisit_Break
+			}
+		}
+		return clang.ChildVisit_Continue
+	}
+	c.Visit(visit)
+	s.path = s.path[:depth]
+	return found
+}
+
+// parents returns the parent of c, its parent and so on.
+func (s *state) parents(c clang.Cursor) []clang.Cursor {
+	if n := len(s.path); n > 0 {
+		r := make([]clang.Cursor, n)
+		for i := range s.path {
+			r[i] = s.path[n-1-i]
+		}
+		return r
+	}
+	var r []clang.Cursor
+	for p := c.SemanticParent(); !p.IsNull() && !p.Kind().IsInvalid(); p = p.SemanticParent() {
+		r = append(r, p)
+		if p.Kind() == cursorkind.TranslationUnit {
+			break
+		}
+	}
+	return r
+}
+
+// HasParent matches the cursors whose parent m matches.
+func HasParent(m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		p := s.parents(c)
+		if len(p) == 0 {
+			return false
+		}
+		return s.outside(m, p[0], len(p)-1)
+	}
+}
+
+// HasAncestor matches the cursors with an ancestor m matches, binding what it
+// binds for the nearest one.
+func HasAncestor(m Matcher) Matcher {
+	return func(s *state, c clang.Cursor) bool {
+		p := s.parents(c)
+		for i, a := range p {
+			if s.outside(m, a, len(p)-1-i) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// outside matches a, an ancestor of the cursor matched, with the path cut
+// to its depth.
+func (s *state) outside(m Matcher, a clang.Cursor, depth int) bool {
+	if len(s.path) == 0 {
+		return s.try(m, a)
+	}
+	path := s.path
+	s.path = path[:depth]
+	matched := s.try(m, a)
+	s.path = path
+	return matched
+}
+
+// elsewhere matches r, a cursor the traversal does not lead to, with its
+// semantic parents for its parents.
+func (s *state) elsewhere(m Matcher, r clang.Cursor) bool {
+	path := s.path
+	s.path = nil
+	matched := s.try(m, r)
+	s.path = path
+	return matched
+}
+
+// under matches a, a cursor under c the traversal does not lead to, with c
+// for its parent.
+func (s *state) under(m Matcher, a, c clang.Cursor) bool {
+	path := s.path
+	p := s.parents(c)
+	s.path = make([]clang.Cursor, 0, len(p)+1)
+	for i := len(p) - 1; i >= 0; i-- {
+		s.path = append(s.path, p[i])
+	}
+	s.path = append(s.path, c)
+	matched := s.try(m, a)
+	s.path = path
+	return matched
+}
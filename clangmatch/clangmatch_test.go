@@ -0,0 +1,147 @@
+package clangmatch
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+func parse(t *testing.T) (clang.Index, clang.TranslationUnit) {
+	source, err := filepath.Abs("../testdata/match.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := clang.NewIndex(0, 0)
+	tu := idx.ParseTranslationUnit(source, nil, nil, 0)
+	if !tu.IsValid() {
+		idx.Dispose()
+		t.Fatalf("parsing %s failed", source)
+	}
+	return idx, tu
+}
+
+// describe returns the spelling and line of the cursors found, and the names
+// bound.
+func describe(results []Result) []string {
+	var r []string
+	for _, res := range results {
+		_, line, _, _ := res.Cursor.Location().FileLocation()
+		s := fmt.Sprintf("%s:%02d", res.Cursor.Spelling(), line)
+		var names []string
+		for name := range res.Bindings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s += " " + name + "=" + res.Bindings[name].Kind().String()
+		}
+		r = append(r, s)
+	}
+	return r
+}
+
+func TestFind(t *testing.T) {
+	idx, tu := parse(t)
+	defer idx.Dispose()
+	defer tu.Dispose()
+	root := tu.TranslationUnitCursor()
+
+	for _, tc := range []struct {
+		name string
+		m    Matcher
+		want []string
+	}{
+		{
+			"calls to malloc",
+			FunctionDecl(HasName("foo"), HasDescendant(Bind("call", CallExpr(Callee(HasName("malloc")))))),
+			[]string{"foo:08 call=CallExpr"},
+		},
+		{
+			"no calls",
+			FunctionDecl(IsDefinition(), Not(HasDescendant(CallExpr()))),
+			[]string{"bar:14"},
+		},
+		{
+			"conditional free",
+			CallExpr(Callee(HasName("free")), HasAncestor(Bind("if", IfStmt()))),
+			[]string{"free:11 if=IfStmt"},
+		},
+		{
+			"parent",
+			CallExpr(HasParent(IfStmt())),
+			[]string{"free:11"},
+		},
+		{
+			"callees have their semantic parents",
+			CallExpr(Callee(HasAncestor(FunctionDecl()))),
+			nil,
+		},
+		{
+			"callees in the translation unit",
+			CallExpr(HasName("free"), Callee(HasParent(TranslationUnitDecl()))),
+			[]string{"free:11", "free:20"},
+		},
+		{
+			"arguments have the call for their parent",
+			CallExpr(HasArgument(0, HasParent(CallExpr()))),
+			[]string{"malloc:09", "free:11", "malloc:19", "free:20"},
+		},
+		{
+			"arguments have the ancestors of the call",
+			CallExpr(HasArgument(0, HasAncestor(Bind("if", IfStmt())))),
+			[]string{"free:11 if=IfStmt"},
+		},
+		{
+			"allocations",
+			CallExpr(Callee(MatchesName("^(malloc|free)$")), ArgumentCountIs(1)),
+			[]string{"malloc:09", "free:11", "malloc:19", "free:20"},
+		},
+		{
+			"char pointers",
+			VarDecl(HasType("char *"), IsInMainFile()),
+			[]string{"buffer:06", "p:19"},
+		},
+		{
+			"bindings of failed matches are dropped",
+			FunctionDecl(AnyOf(
+				AllOf(HasDescendant(Bind("x", ReturnStmt())), HasName("nope")),
+				Bind("f", HasName("bar")))),
+			[]string{"bar:14 f=FunctionDecl"},
+		},
+		{
+			"custom",
+			ParmDecl(Func(func(c clang.Cursor) bool { return c.Spelling() == "n" })),
+			[]string{"n:08"},
+		},
+	} {
+		if got := describe(Find(tc.m, root)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	idx, tu := parse(t)
+	defer idx.Dispose()
+	defer tu.Dispose()
+
+	foo := Find(FunctionDecl(HasName("foo")), tu.TranslationUnitCursor())
+	if len(foo) != 1 {
+		t.Fatalf("found %d foo", len(foo))
+	}
+	b, ok := Match(HasDescendant(Bind("n", DeclRefExpr(References(ParmDecl())))), foo[0].Cursor)
+	if !ok || b["n"].Spelling() != "n" {
+		t.Errorf("Match = %v, %v", b, ok)
+	}
+	// Outside of a traversal, the parents are the semantic ones.
+	if _, ok := Match(HasParent(TranslationUnitDecl()), foo[0].Cursor); !ok {
+		t.Errorf("foo has no translation unit parent")
+	}
+	if _, ok := Match(Not(Anything()), foo[0].Cursor); ok {
+		t.Errorf("Not(Anything()) matched")
+	}
+}
@@ -0,0 +1,104 @@
+package clangmatch
+
+import "github.com/frankreh/go-clang/clang/cursorkind"
+
+// TranslationUnitDecl matches the translation unit when all the matchers match it.
+func TranslationUnitDecl(ms ...Matcher) Matcher { return Kind(cursorkind.TranslationUnit, ms...) }
+
+// StructDecl matches the struct declarations that all the matchers match.
+func StructDecl(ms ...Matcher) Matcher { return Kind(cursorkind.StructDecl, ms...) }
+
+// UnionDecl matches the union declarations that all the matchers match.
+func UnionDecl(ms ...Matcher) Matcher { return Kind(cursorkind.UnionDecl, ms...) }
+
+// EnumDecl matches the enum declarations that all the matchers match.
+func EnumDecl(ms ...Matcher) Matcher { return Kind(cursorkind.EnumDecl, ms...) }
+
+// FieldDecl matches the field declarations that all the matchers match.
+func FieldDecl(ms ...Matcher) Matcher { return Kind(cursorkind.FieldDecl, ms...) }
+
+// EnumConstantDecl matches the enumerator declarations that all the matchers match.
+func EnumConstantDecl(ms ...Matcher) Matcher { return Kind(cursorkind.EnumConstantDecl, ms...) }
+
+// FunctionDecl matches the function declarations that all the matchers match.
+func FunctionDecl(ms ...Matcher) Matcher { return Kind(cursorkind.FunctionDecl, ms...) }
+
+// VarDecl matches the variable declarations that all the matchers match.
+func VarDecl(ms ...Matcher) Matcher { return Kind(cursorkind.VarDecl, ms...) }
+
+// ParmDecl matches the parameter declarations that all the matchers match.
+func ParmDecl(ms ...Matcher) Matcher { return Kind(cursorkind.ParmDecl, ms...) }
+
+// TypedefDecl matches the typedef declarations that all the matchers match.
+func TypedefDecl(ms ...Matcher) Matcher { return Kind(cursorkind.TypedefDecl, ms...) }
+
+// CXXMethodDecl matches the C++ method declarations that all the matchers match.
+func CXXMethodDecl(ms ...Matcher) Matcher { return Kind(cursorkind.CXXMethod, ms...) }
+
+// CXXRecordDecl matches the C++ class declarations that all the matchers match.
+func CXXRecordDecl(ms ...Matcher) Matcher { return Kind(cursorkind.ClassDecl, ms...) }
+
+// DeclRefExpr matches the references to declarations in expressions that all the matchers match.
+func DeclRefExpr(ms ...Matcher) Matcher { return Kind(cursorkind.DeclRefExpr, ms...) }
+
+// MemberRefExpr matches the references to members in expressions that all the matchers match.
+func MemberRefExpr(ms ...Matcher) Matcher { return Kind(cursorkind.MemberRefExpr, ms...) }
+
+// CallExpr matches the calls that all the matchers match.
+func CallExpr(ms ...Matcher) Matcher { return Kind(cursorkind.CallExpr, ms...) }
+
+// IntegerLiteral matches the integer literals that all the matchers match.
+func IntegerLiteral(ms ...Matcher) Matcher { return Kind(cursorkind.IntegerLiteral, ms...) }
+
+// StringLiteral matches the string literals that all the matchers match.
+func StringLiteral(ms ...Matcher) Matcher { return Kind(cursorkind.StringLiteral, ms...) }
+
+// UnaryOperator matches the unary operators that all the matchers match.
+func UnaryOperator(ms ...Matcher) Matcher { return Kind(cursorkind.UnaryOperator, ms...) }
+
+// BinaryOperator matches the binary operators that all the matchers match.
+func BinaryOperator(ms ...Matcher) Matcher { return Kind(cursorkind.BinaryOperator, ms...) }
+
+// CompoundAssignOperator matches the compound assignments such as += that all the matchers match.
+func CompoundAssignOperator(ms ...Matcher) Matcher {
+	return Kind(cursorkind.CompoundAssignOperator, ms...)
+}
+
+// ConditionalOperator matches the conditional operators that all the matchers match.
+func ConditionalOperator(ms ...Matcher) Matcher { return Kind(cursorkind.ConditionalOperator, ms...) }
+
+// CStyleCastExpr matches the C style casts that all the matchers match.
+func CStyleCastExpr(ms ...Matcher) Matcher { return Kind(cursorkind.CStyleCastExpr, ms...) }
+
+// ArraySubscriptExpr matches the array subscripts that all the matchers match.
+func ArraySubscriptExpr(ms ...Matcher) Matcher { return Kind(cursorkind.ArraySubscriptExpr, ms...) }
+
+// CompoundStmt matches the compound statements that all the matchers match.
+func CompoundStmt(ms ...Matcher) Matcher { return Kind(cursorkind.CompoundStmt, ms...) }
+
+// IfStmt matches the if statements that all the matchers match.
+func IfStmt(ms ...Matcher) Matcher { return Kind(cursorkind.IfStmt, ms...) }
+
+// SwitchStmt matches the switch statements that all the matchers match.
+func SwitchStmt(ms ...Matcher) Matcher { return Kind(cursorkind.SwitchStmt, ms...) }
+
+// WhileStmt matches the while statements that all the matchers match.
+func WhileStmt(ms ...Matcher) Matcher { return Kind(cursorkind.WhileStmt, ms...) }
+
+// DoStmt matches the do statements that all the matchers match.
+func DoStmt(ms ...Matcher) Matcher { return Kind(cursorkind.DoStmt, ms...) }
+
+// ForStmt matches the for statements that all the matchers match.
+func ForStmt(ms ...Matcher) Matcher { return Kind(cursorkind.ForStmt, ms...) }
+
+// GotoStmt matches the goto statements that all the matchers match.
+func GotoStmt(ms ...Matcher) Matcher { return Kind(cursorkind.GotoStmt, ms...) }
+
+// ReturnStmt matches the return statements that all the matchers match.
+func ReturnStmt(ms ...Matcher) Matcher { return Kind(cursorkind.ReturnStmt, ms...) }
+
+// DeclStmt matches the declaration statements that all the matchers match.
+func DeclStmt(ms ...Matcher) Matcher { return Kind(cursorkind.DeclStmt, ms...) }
+
+// MacroExpansion matches the macro expansions that all the matchers match.
+func MacroExpansion(ms ...Matcher) Matcher { return Kind(cursorkind.MacroExpansion, ms...) }
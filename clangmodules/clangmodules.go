@@ -0,0 +1,209 @@
+// Package clangmodules browses the clang modules used by a translation unit
+// built with -fmodules, and writes module maps with the clang package's
+// ModuleMapDescriptor.
+//
+// libclang only offers a module's parent, not its submodules, so the module
+// tree is assembled from the modules the translation unit imports and the
+// modules owning the files it includes. Submodules that are never used do not
+// show up.
+package clangmodules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Module describes a module or submodule.
+type Module struct {
+	Name       string // e.g. "vector"
+	FullName   string // e.g. "std.vector"
+	IsSystem   bool
+	ASTFile    string   // the module file it was loaded from
+	Headers    []string // top-level headers
+	Imported   bool     // imported by the translation unit, directly or for an #include
+	Parent     *Module
+	Submodules []*Module // sorted by name
+}
+
+// Modules holds the modules of a translation unit.
+type Modules struct {
+	Roots  []*Module          // top-level modules, sorted by name
+	ByName map[string]*Module // keyed by full name
+	Files  map[string]*Module // included files, keyed by name, with their owning module or nil
+}
+
+// Explore returns the modules of the translation unit. Included files are
+// only found when the translation unit was parsed with
+// TranslationUnit_DetailedPreprocessingRecord.
+func Explore(tu clang.TranslationUnit) *Modules {
+	ms := &Modules{
+		ByName: make(map[string]*Module),
+		Files:  make(map[string]*Module),
+	}
+
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		switch cursor.Kind() {
+		case cursorkind.ModuleImportDecl:
+			if m := cursor.Module(); !m.IsNull() {
+				ms.add(tu, m).Imported = true
+			}
+		case cursorkind.InclusionDirective:
+			file := cursor.IncludedFile()
+			name := file.Name()
+			if name == "" {
+				break
+			}
+			var owner *Module
+			if m := tu.ModuleForFile(file); !m.IsNull() {
+				owner = ms.add(tu, m)
+			}
+			ms.Files[name] = owner
+		}
+		return clang.ChildVisit_Continue
+	})
+
+	// The top-level headers of every module found are owned by it too, even
+	// when the translation unit does not include them itself.
+	for _, m := range ms.ByName {
+		for _, h := range m.Headers {
+			if _, ok := ms.Files[h]; !ok {
+				ms.Files[h] = m
+			}
+		}
+	}
+
+	sortModules(ms.Roots)
+	for _, m := range ms.ByName {
+		sortModules(m.Submodules)
+	}
+	return ms
+}
+
+// add returns the Module for m, adding it and its ancestors when not seen
+// before.
+func (ms *Modules) add(tu clang.TranslationUnit, m clang.Module) *Module {
+	fullName := m.FullName()
+	if r, ok := ms.ByName[fullName]; ok {
+		return r
+	}
+	r := &Module{
+		Name:     m.Name(),
+		FullName: fullName,
+		IsSystem: m.IsSystem(),
+		ASTFile:  m.ASTFile().Name(),
+	}
+	for _, h := range tu.ModuleTopLevelHeaders(m) {
+		r.Headers = append(r.Headers, h.Name())
+	}
+	sort.Strings(r.Headers)
+	ms.ByName[fullName] = r
+
+	if parent := m.Parent(); parent.IsNull() {
+		ms.Roots = append(ms.Roots, r)
+	} else {
+		r.Parent = ms.add(tu, parent)
+		r.Parent.Submodules = append(r.Parent.Submodules, r)
+	}
+	return r
+}
+
+func sortModules(ms []*Module) {
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+}
+
+// Walk calls fn for each module of the tree in depth first order, with the
+// depth of the module, 0 for the roots.
+func (ms *Modules) Walk(fn func(m *Module, depth int)) {
+	var walk func(m *Module, depth int)
+	walk = func(m *Module, depth int) {
+		fn(m, depth)
+		for _, sub := range m.Submodules {
+			walk(sub, depth+1)
+		}
+	}
+	for _, m := range ms.Roots {
+		walk(m, 0)
+	}
+}
+
+// ModuleMap returns the module map text describing the framework module name
+// with the given umbrella header, as written by the clang package's
+// ModuleMapDescriptor. Clang only reads a framework module from the
+// Modules/module.modulemap of a name.framework directory, with the umbrella
+// header in its Headers directory; WriteModuleMap makes it a plain module.
+func ModuleMap(name, umbrella string) (string, error) {
+	mmd := clang.NewModuleMapDescriptor(0)
+	defer mmd.Dispose()
+
+	if err := mmd.SetFrameworkModuleName(name); err != nil {
+		return "", err
+	}
+	if err := mmd.SetUmbrellaHeader(umbrella); err != nil {
+		return "", err
+	}
+	text, _, err := mmd.WriteToBuffer(0)
+	return text, err
+}
+
+// WriteModuleMap writes a module.modulemap file to dir, describing the
+// headers of the directory as the module name, for clang to find when dir
+// is searched with -I. An existing module.modulemap is only overwritten when
+// force is set.
+//
+// The module is described by its umbrella header, each header it includes
+// being a submodule. When umbrella is empty, the header of dir named after
+// the module is used, or the only header when there is just one.
+func WriteModuleMap(dir, name, umbrella string, force bool) (string, error) {
+	path := filepath.Join(dir, "module.modulemap")
+	if _, err := os.Stat(path); err == nil && !force {
+		return "", fmt.Errorf("%s exists, not overwritten", path)
+	}
+
+	if umbrella == "" {
+		headers, err := filepath.Glob(filepath.Join(dir, "*.h"))
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case contains(headers, filepath.Join(dir, name+".h")):
+			umbrella = name + ".h"
+		case len(headers) == 1:
+			umbrella = filepath.Base(headers[0])
+		case len(headers) == 0:
+			return "", fmt.Errorf("no headers found in %s", dir)
+		default:
+			return "", fmt.Errorf("%d headers found in %s and none is named %s.h, an umbrella header must be given", len(headers), dir, name)
+		}
+	} else if _, err := os.Stat(filepath.Join(dir, umbrella)); err != nil {
+		return "", err
+	}
+
+	text, err := ModuleMap(name, umbrella)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(text, "framework module ") {
+		return "", fmt.Errorf("unexpected module map for %s:\n%s", name, text)
+	}
+	text = strings.TrimPrefix(text, "framework ")
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
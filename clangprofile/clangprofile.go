@@ -0,0 +1,180 @@
+// Package clangprofile measures what parsing the entries of a compilation
+// database costs, and attributes that cost to the headers they include.
+//
+// Every translation unit is parsed once, recording its wall time, its
+// resource usage and its include closure. Every header found is then parsed on
+// its own, with the arguments of the first translation unit including it. A
+// header's estimated cost is its standalone parse time multiplied by the
+// number of translation units including it, which is what would be saved if
+// nothing included it anymore.
+package clangprofile
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// Usage is the result of parsing one file.
+type Usage struct {
+	Duration time.Duration
+	Memory   uint64            // total of the resource usage entries, in bytes
+	Entries  map[string]uint64 // resource usage, keyed by TUResourceUsageKind name
+	Closure  []string          // files included directly or indirectly, in the order first included
+	Problems []string          // error diagnostics
+}
+
+// TU is the profile of one compile command.
+type TU struct {
+	Filename string
+	Usage
+}
+
+// Header is the profile of one header, parsed standalone.
+type Header struct {
+	Name      string
+	System    bool
+	Includers int // number of translation units including the header
+	Usage
+}
+
+// Cost estimates the parse time all the translation units spend on the
+// header.
+func (h *Header) Cost() time.Duration {
+	return time.Duration(h.Includers) * h.Duration
+}
+
+// Report holds the profiles of a compilation database.
+type Report struct {
+	TUs     []TU      // in compilation database order
+	Headers []*Header // most expensive first
+}
+
+// Profiler parses compile commands and measures them.
+type Profiler struct {
+	// Options used for every parse. DetailedPreprocessingRecord is always
+	// added as the include closures depend on it.
+	Options clang.TranslationUnit_Flags
+
+	// Additional arguments passed to clang for every parse.
+	Args []string
+
+	// System headers are only parsed standalone when this is set.
+	System bool
+
+	// When non zero, only the headers included by the most translation units
+	// are parsed standalone.
+	MaxHeaders int
+}
+
+// Profile parses every compile command and the headers they include.
+func (p *Profiler) Profile(cmds []clang.CompileCommand) *Report {
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	r := &Report{}
+	headers := make(map[string]*Header)
+	headerArgs := make(map[string][]string)
+	for _, cmd := range cmds {
+		source, args := cmd.ParseArgs()
+		args = append(args, p.Args...)
+		system := make(map[string]bool)
+		tu := TU{
+			Filename: source,
+			Usage:    p.parse(idx, source, args, system),
+		}
+		r.TUs = append(r.TUs, tu)
+
+		for _, name := range tu.Closure {
+			h, ok := headers[name]
+			if !ok {
+				h = &Header{Name: name, System: system[name]}
+				headers[name] = h
+				headerArgs[name] = append(args[:len(args):len(args)], "-x", headerLanguage(source))
+			}
+			h.Includers++
+		}
+	}
+
+	var candidates []*Header
+	for _, h := range headers {
+		if p.System || !h.System {
+			candidates = append(candidates, h)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Includers != candidates[j].Includers {
+			return candidates[i].Includers > candidates[j].Includers
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	if p.MaxHeaders > 0 && len(candidates) > p.MaxHeaders {
+		candidates = candidates[:p.MaxHeaders]
+	}
+	for _, h := range candidates {
+		h.Usage = p.parse(idx, h.Name, headerArgs[h.Name], nil)
+	}
+
+	r.Headers = candidates
+	sort.SliceStable(r.Headers, func(i, j int) bool {
+		return r.Headers[i].Cost() > r.Headers[j].Cost()
+	})
+	return r
+}
+
+// parse measures the parse of source. The files of the include closure that
+// are system headers are recorded in system when it is not nil.
+func (p *Profiler) parse(idx clang.Index, source string, args []string, system map[string]bool) Usage {
+	var u Usage
+
+	start := time.Now()
+	tu := idx.ParseTranslationUnit(source, args, nil, p.Options|clang.TranslationUnit_DetailedPreprocessingRecord)
+	u.Duration = time.Since(start)
+	if !tu.IsValid() {
+		u.Problems = append(u.Problems, "parsing "+source+" failed")
+		return u
+	}
+	defer tu.Dispose()
+
+	u.Problems = errorDiagnostics(tu)
+
+	u.Entries = make(map[string]uint64)
+	for _, e := range tu.ResourceUsage() {
+		u.Entries[e.Kind().Name()] += e.Amount()
+		u.Memory += e.Amount()
+	}
+
+	seen := make(map[string]bool)
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() == cursorkind.InclusionDirective {
+			file := cursor.IncludedFile()
+			name := file.Name()
+			if name != "" && !seen[name] {
+				seen[name] = true
+				u.Closure = append(u.Closure, name)
+				if system != nil {
+					system[name] = tu.Location(file, 1, 1).IsInSystemHeader()
+				}
+			}
+		}
+		return clang.ChildVisit_Continue
+	})
+	return u
+}
+
+// headerLanguage returns the -x language for parsing the headers included by
+// source.
+func headerLanguage(source string) string {
+	switch filepath.Ext(source) {
+	case ".cc", ".cp", ".cpp", ".cxx", ".c++", ".C":
+		return "c++-header"
+	case ".m":
+		return "objective-c-header"
+	case ".mm":
+		return "objective-c++-header"
+	}
+	return "c-header"
+}
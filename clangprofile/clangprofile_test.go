@@ -0,0 +1,81 @@
+package clangprofile
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+func TestProfile(t *testing.T) {
+	dir, err := filepath.Abs("../testdata/includes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cmds []clang.CompileCommand
+	for _, file := range []string{"main.c", "unused.c"} {
+		cmds = append(cmds, clang.CompileCommand{
+			Directory: dir,
+			Filename:  file,
+			Args:      []string{"cc", "-Iinc", "-c", "-o", file + ".o", file},
+		})
+	}
+
+	p := Profiler{MaxHeaders: 3}
+	r := p.Profile(cmds)
+	if len(r.TUs) != 2 {
+		t.Fatalf("%d TUs, want 2", len(r.TUs))
+	}
+	closure := []string{"a.h", "b.h", "c.h", "local.h"}
+	for i, file := range []string{"main.c", "unused.c"} {
+		tu := r.TUs[i]
+		if tu.Filename != filepath.Join(dir, file) {
+			t.Errorf("TU %d is %s, want %s", i, tu.Filename, file)
+		}
+		if len(tu.Problems) > 0 {
+			t.Errorf("%s: %v", file, tu.Problems)
+		}
+		if tu.Duration <= 0 || tu.Memory == 0 {
+			t.Errorf("%s: parse %v, memory %d", file, tu.Duration, tu.Memory)
+		}
+		var names []string
+		for _, name := range tu.Closure {
+			names = append(names, filepath.Base(name))
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, closure) {
+			t.Errorf("%s: closure %v, want %v", file, names, closure)
+		}
+	}
+
+	// The four headers are included by both, only three are parsed.
+	var names []string
+	for i, h := range r.Headers {
+		names = append(names, filepath.Base(h.Name))
+		if h.Includers != 2 || h.System || len(h.Problems) > 0 {
+			t.Errorf("header %s: %d includers, system %v, problems %v", h.Name, h.Includers, h.System, h.Problems)
+		}
+		if i > 0 && h.Cost() > r.Headers[i-1].Cost() {
+			t.Errorf("header %s costs more than the one before it", h.Name)
+		}
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, closure[:3]) {
+		t.Errorf("headers %v, want %v", names, closure[:3])
+	}
+}
+
+func TestHeaderLanguage(t *testing.T) {
+	for source, want := range map[string]string{
+		"a.c":   "c-header",
+		"a.cpp": "c++-header",
+		"a.m":   "objective-c-header",
+		"a.mm":  "objective-c++-header",
+	} {
+		if got := headerLanguage(source); got != want {
+			t.Errorf("headerLanguage(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
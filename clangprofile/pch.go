@@ -0,0 +1,198 @@
+package clangprofile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+// ProposePCH returns the headers worth precompiling: those included by at
+// least the given share of the translation units, from 0 to 1, and that parse
+// on their own. The most expensive come first and at most max are returned
+// when max is not zero.
+func (r *Report) ProposePCH(share float64, max int) []*Header {
+	var headers []*Header
+	for _, h := range r.Headers {
+		if len(h.Problems) > 0 || h.Entries == nil {
+			continue
+		}
+		if float64(h.Includers) >= share*float64(len(r.TUs)) {
+			headers = append(headers, h)
+		}
+	}
+	if max > 0 && len(headers) > max {
+		headers = headers[:max]
+	}
+	return headers
+}
+
+// PCHSource returns the text of a header including the given headers, in the
+// order the translation units first include them.
+func (r *Report) PCHSource(headers []*Header) string {
+	order := make(map[string]int)
+	for _, tu := range r.TUs {
+		for _, name := range tu.Closure {
+			if _, ok := order[name]; !ok {
+				order[name] = len(order)
+			}
+		}
+	}
+	sorted := append([]*Header(nil), headers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order[sorted[i].Name] < order[sorted[j].Name]
+	})
+
+	var b strings.Builder
+	b.WriteString("// Precompiled header proposed by go-clang-pch.\n")
+	for _, h := range sorted {
+		fmt.Fprintf(&b, "#include %q\n", h.Name)
+	}
+	return b.String()
+}
+
+// Verification compares parsing the translation units with and without the
+// proposed precompiled header.
+type Verification struct {
+	Header string        // the header including the proposed set
+	PCH    string        // the precompiled header built from it
+	Build  time.Duration // time to parse and save the precompiled header
+	TUs    []Verified
+}
+
+// Verified holds the measures of one translation unit.
+type Verified struct {
+	Filename string
+	Parse    time.Duration // without a precompiled header
+	PCHParse time.Duration // with -include-pch
+	Reparse  time.Duration // reparse with a precompiled preamble
+	Problems []string      // error diagnostics of the parses using the precompiled header
+}
+
+// Speedup returns how many times faster the translation units parse in total
+// with the precompiled header, and reparse with a precompiled preamble.
+func (v *Verification) Speedup() (float64, float64) {
+	var parse, pch, reparse time.Duration
+	for _, tu := range v.TUs {
+		parse += tu.Parse
+		pch += tu.PCHParse
+		reparse += tu.Reparse
+	}
+	return ratio(parse, pch), ratio(parse, reparse)
+}
+
+func ratio(a, b time.Duration) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// VerifyPCH writes the header including the proposed headers to dir, builds a
+// precompiled header from it with the arguments of the first compile command
+// and saves it with SaveTranslationUnit. Each compile command is then parsed
+// three ways: as is, with the precompiled header, and reparsed with a
+// precompiled preamble.
+func (p *Profiler) VerifyPCH(cmds []clang.CompileCommand, r *Report, headers []*Header, dir string) (*Verification, error) {
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("no compile commands")
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("no headers to precompile")
+	}
+
+	v := &Verification{
+		Header: filepath.Join(dir, "pch.h"),
+		PCH:    filepath.Join(dir, "pch.h.pch"),
+	}
+	if err := ioutil.WriteFile(v.Header, []byte(r.PCHSource(headers)), 0644); err != nil {
+		return nil, err
+	}
+
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	source, args := cmds[0].ParseArgs()
+	args = append(args, p.Args...)
+	args = append(args, "-x", headerLanguage(source))
+	start := time.Now()
+	tu := idx.ParseTranslationUnit(v.Header, args, nil, p.Options|clang.TranslationUnit_ForSerialization|clang.TranslationUnit_Incomplete)
+	if !tu.IsValid() {
+		return nil, fmt.Errorf("parsing %s failed", v.Header)
+	}
+	if problems := errorDiagnostics(tu); len(problems) > 0 {
+		tu.Dispose()
+		return nil, fmt.Errorf("parsing %s: %s", v.Header, problems[0])
+	}
+	err := tu.SaveTranslationUnit(v.PCH, tu.DefaultSaveOptions())
+	tu.Dispose()
+	if err != nil {
+		return nil, fmt.Errorf("saving %s: %v", v.PCH, err)
+	}
+	v.Build = time.Since(start)
+
+	for _, cmd := range cmds {
+		source, args := cmd.ParseArgs()
+		args = append(args, p.Args...)
+		t := Verified{Filename: source}
+
+		t.Parse, _ = p.timeParse(idx, source, args, p.Options)
+
+		var problems []string
+		t.PCHParse, problems = p.timeParse(idx, source, append(args, "-include-pch", v.PCH), p.Options)
+		t.Problems = append(t.Problems, problems...)
+
+		t.Reparse, problems = p.timeReparse(idx, source, args)
+		t.Problems = append(t.Problems, problems...)
+
+		v.TUs = append(v.TUs, t)
+	}
+	return v, nil
+}
+
+// timeParse returns the time it takes to parse source and its error
+// diagnostics.
+func (p *Profiler) timeParse(idx clang.Index, source string, args []string, options clang.TranslationUnit_Flags) (time.Duration, []string) {
+	start := time.Now()
+	tu := idx.ParseTranslationUnit(source, args, nil, options)
+	d := time.Since(start)
+	if !tu.IsValid() {
+		return d, []string{"parsing " + source + " failed"}
+	}
+	defer tu.Dispose()
+	return d, errorDiagnostics(tu)
+}
+
+// timeReparse parses source with a precompiled preamble and returns the time
+// a reparse takes once the preamble is built.
+func (p *Profiler) timeReparse(idx clang.Index, source string, args []string) (time.Duration, []string) {
+	tu := idx.ParseTranslationUnit(source, args, nil, p.Options|clang.TranslationUnit_PrecompiledPreamble)
+	if !tu.IsValid() {
+		return 0, []string{"parsing " + source + " failed"}
+	}
+	defer tu.Dispose()
+
+	// The preamble is built by the first reparse.
+	if err := tu.ReparseTranslationUnit(nil, tu.DefaultReparseOptions()); err != nil {
+		return 0, []string{fmt.Sprintf("reparsing %s: %v", source, err)}
+	}
+	start := time.Now()
+	if err := tu.ReparseTranslationUnit(nil, tu.DefaultReparseOptions()); err != nil {
+		return 0, []string{fmt.Sprintf("reparsing %s: %v", source, err)}
+	}
+	return time.Since(start), errorDiagnostics(tu)
+}
+
+func errorDiagnostics(tu clang.TranslationUnit) []string {
+	var r []string
+	for _, d := range tu.Diagnostics() {
+		if d.Severity() >= clang.Diagnostic_Error {
+			r = append(r, d.Spelling())
+		}
+	}
+	return r
+}
@@ -0,0 +1,445 @@
+// Package clangsession keeps a translation unit up to date with the buffer of
+// an editor.
+//
+// A Session owns an Index and the translation unit of one file. Edits are
+// applied to its copy of the buffer the way the Language Server Protocol
+// describes them, and the translation unit is reparsed with the new contents
+// once no edit came in for Delay. After each parse the diagnostics, tokens and
+// top-level cursors of the file are copied out of the translation unit, so
+// they can be read at any time without holding on to libclang objects.
+//
+// When a reparse fails, libclang leaves the translation unit unusable. The
+// session then disposes of it and parses the file from scratch.
+package clangsession
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/tokenkind"
+)
+
+// DefaultDelay is the Delay of new sessions.
+var DefaultDelay = 200 * time.Millisecond
+
+// reparse reparses a translation unit, or fails to when a test makes it.
+var reparse = clang.TranslationUnit.ReparseTranslationUnit
+
+// Position is a zero-based position in the buffer. As in the Language Server
+// Protocol, Character counts UTF-16 code units.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a half-open range of the buffer.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Edit replaces the text in Range with Text. A nil Range replaces the whole
+// buffer.
+type Edit struct {
+	Range *Range
+	Text  string
+}
+
+// Diagnostic is a diagnostic of the last parse.
+type Diagnostic struct {
+	Severity clang.DiagnosticSeverity
+	Message  string
+	Option   string // the command line option enabling the diagnostic, e.g. -Wunused
+	File     string
+	Range    Range // positions outside of the session's file count bytes, not UTF-16 code units
+}
+
+// Token is a token of the session's file, with the cursor it was annotated
+// with.
+type Token struct {
+	Kind     tokenkind.Kind
+	Spelling string
+	Range    Range
+	Cursor   cursorkind.Kind
+}
+
+// Cursor is a top-level cursor of the session's file.
+type Cursor struct {
+	Kind     cursorkind.Kind
+	Spelling string
+	USR      string
+	Range    Range
+}
+
+// Session holds the translation unit of one file being edited.
+type Session struct {
+	Filename string
+	Args     []string
+
+	// The time to wait after an edit before reparsing, so a burst of edits
+	// causes a single reparse.
+	Delay time.Duration
+
+	// When not nil, OnParse is called after every parse, from the goroutine
+	// that parsed. It may call the methods of the session.
+	OnParse func(s *Session)
+
+	// parseMu serializes the use of the translation unit and guards the
+	// buffer it was parsed from.
+	parseMu sync.Mutex
+	idx     clang.Index
+	tu      clang.TranslationUnit
+	options clang.TranslationUnit_Flags
+	text    string
+	starts  []int // the offsets at which the lines of text start
+
+	// mu guards the fields below.
+	mu          sync.Mutex
+	contents    string
+	version     int // incremented by every Apply
+	parsed      int // the version of the last parse
+	timer       *time.Timer
+	closed      bool
+	err         error
+	diagnostics []Diagnostic
+	tokens      []Token
+	cursors     []Cursor
+}
+
+// New parses filename with the given arguments, contents and options and
+// returns its session. The options are usually those recommended for editing,
+// clang.DefaultEditingTranslationUnitOptions, which include a precompiled
+// preamble.
+func New(filename string, args []string, contents string, options clang.TranslationUnit_Flags) (*Session, error) {
+	s := &Session{
+		Filename: filename,
+		Args:     args,
+		Delay:    DefaultDelay,
+		idx:      clang.NewIndex(0, 0),
+		options:  options,
+		contents: contents,
+	}
+	if err := s.Reparse(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close stops any pending reparse and disposes of the translation unit and
+// the index.
+func (s *Session) Close() {
+	s.mu.Lock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.mu.Unlock()
+
+	s.parseMu.Lock()
+	defer s.parseMu.Unlock()
+	if s.tu.IsValid() {
+		s.tu.Dispose()
+		s.tu = clang.TranslationUnit{}
+	}
+	s.idx.Dispose()
+}
+
+// Apply applies the edits in order to the buffer and schedules a reparse.
+// Positions past the end of a line or of the buffer are moved back to it.
+// When the range of an edit ends before it starts, none of the edits are
+// applied.
+func (s *Session) Apply(edits ...Edit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("session of %s is closed", s.Filename)
+	}
+
+	contents := s.contents
+	for _, e := range edits {
+		if e.Range == nil {
+			contents = e.Text
+			continue
+		}
+		starts := lineStarts(contents)
+		start := offset(contents, starts, e.Range.Start)
+		end := offset(contents, starts, e.Range.End)
+		if end < start {
+			return fmt.Errorf("edit range %v ends before it starts", *e.Range)
+		}
+		contents = contents[:start] + e.Text + contents[end:]
+	}
+	s.contents = contents
+	s.version++
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(s.Delay, func() { s.Flush() })
+	return nil
+}
+
+// Flush reparses right away when the buffer changed since the last parse,
+// instead of waiting for the delay, and returns the error of the last parse.
+func (s *Session) Flush() error {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	changed := s.version != s.parsed
+	err := s.err
+	s.mu.Unlock()
+
+	if !changed {
+		return err
+	}
+	return s.Reparse()
+}
+
+// Reparse parses the current buffer. The translation unit is reparsed with
+// its default reparse options, or parsed from scratch when there is none yet
+// or reparsing it failed.
+func (s *Session) Reparse() error {
+	s.parseMu.Lock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		s.parseMu.Unlock()
+		return fmt.Errorf("session of %s is closed", s.Filename)
+	}
+	contents, version := s.contents, s.version
+	s.mu.Unlock()
+
+	uf := clang.NewUnsavedFile(s.Filename, contents)
+	unsaved := []clang.UnsavedFile{uf}
+	var err error
+	if s.tu.IsValid() {
+		if err = reparse(s.tu, unsaved, s.tu.DefaultReparseOptions()); err != nil {
+			s.tu.Dispose()
+			s.tu = clang.TranslationUnit{}
+		}
+	}
+	if !s.tu.IsValid() {
+		err = s.idx.ParseTranslationUnit2(s.Filename, s.Args, unsaved, s.options, &s.tu)
+		if err != nil {
+			s.tu = clang.TranslationUnit{}
+			err = fmt.Errorf("parsing %s: %v", s.Filename, err)
+		}
+	}
+	uf.Dispose()
+
+	var diagnostics []Diagnostic
+	var tokens []Token
+	var cursors []Cursor
+	if err == nil {
+		s.text, s.starts = contents, lineStarts(contents)
+		diagnostics, tokens, cursors = s.collect()
+	}
+
+	// Stored before another parse may start, so the results are those of
+	// the text the translation unit holds, in the order of the parses.
+	s.mu.Lock()
+	s.parsed = version
+	s.err = err
+	s.diagnostics, s.tokens, s.cursors = diagnostics, tokens, cursors
+	onParse := s.OnParse
+	s.mu.Unlock()
+	s.parseMu.Unlock()
+
+	if onParse != nil {
+		onParse(s)
+	}
+	return err
+}
+
+// Do calls fn with the translation unit of the last parse, which is not
+// reparsed until fn returns. Nothing obtained from it may be kept after fn
+// returns. The translation unit is not valid when the last parse failed.
+//
+// Location and Range may only be called from fn.
+func (s *Session) Do(fn func(tu clang.TranslationUnit)) {
+	s.parseMu.Lock()
+	defer s.parseMu.Unlock()
+	fn(s.tu)
+}
+
+// Location returns the location of p in the buffer of the last parse.
+func (s *Session) Location(p Position) clang.SourceLocation {
+	return s.tu.LocationForOffset(s.tu.File(s.Filename), uint32(offset(s.text, s.starts, p)))
+}
+
+// Range returns the file name and range of sr. Positions in the session's
+// file are those of the buffer of the last parse.
+func (s *Session) Range(sr clang.SourceRange) (string, Range) {
+	file := s.tu.File(s.Filename)
+	var r Range
+	var name string
+	for i, loc := range []clang.SourceLocation{sr.Start(), sr.End()} {
+		f, line, column, off := loc.FileLocation()
+		p := &r.Start
+		if i == 1 {
+			p = &r.End
+		}
+		if f.IsEqual(file) {
+			*p = position(s.text, s.starts, int(off))
+		} else if line > 0 && column > 0 {
+			*p = Position{Line: int(line) - 1, Character: int(column) - 1}
+		}
+		if i == 0 {
+			name = f.Name()
+		}
+	}
+	return name, r
+}
+
+// Contents returns the current buffer and its version, the number of times
+// Apply was called.
+func (s *Session) Contents() (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.contents, s.version
+}
+
+// Version returns the version of the buffer the last parse was of.
+func (s *Session) Version() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parsed
+}
+
+// Err returns the error of the last parse.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Diagnostics returns the diagnostics of the last parse.
+func (s *Session) Diagnostics() []Diagnostic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.diagnostics
+}
+
+// Tokens returns the tokens of the session's file at the last parse.
+func (s *Session) Tokens() []Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens
+}
+
+// Cursors returns the top-level cursors of the session's file at the last
+// parse.
+func (s *Session) Cursors() []Cursor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursors
+}
+
+// collect copies the diagnostics, tokens and cursors out of the translation
+// unit.
+func (s *Session) collect() ([]Diagnostic, []Token, []Cursor) {
+	var diagnostics []Diagnostic
+	for _, d := range s.tu.Diagnostics() {
+		loc := d.Location()
+		sr := loc.Range(loc)
+		if d.NumRanges() > 0 {
+			sr = d.Range(0)
+		}
+		option, _ := d.Option()
+		r := Diagnostic{
+			Severity: d.Severity(),
+			Message:  d.Spelling(),
+			Option:   option,
+		}
+		r.File, r.Range = s.Range(sr)
+		diagnostics = append(diagnostics, r)
+	}
+
+	file := s.tu.File(s.Filename)
+	whole := s.tu.LocationForOffset(file, 0).Range(s.tu.LocationForOffset(file, uint32(len(s.text))))
+	tokens := s.tu.Tokenize(whole)
+	annotations := s.tu.AnnotateTokens(tokens)
+	var rtokens []Token
+	for i, t := range tokens {
+		r := Token{
+			Kind:     t.Kind(),
+			Spelling: s.tu.TokenSpelling(t),
+			Cursor:   annotations[i].Kind(),
+		}
+		_, r.Range = s.Range(s.tu.TokenExtent(t))
+		rtokens = append(rtokens, r)
+	}
+
+	var cursors []Cursor
+	s.tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if !cursor.Location().IsFromMainFile() {
+			return clang.ChildVisit_Continue
+		}
+		r := Cursor{
+			Kind:     cursor.Kind(),
+			Spelling: cursor.Spelling(),
+			USR:      cursor.USR(),
+		}
+		_, r.Range = s.Range(cursor.Extent())
+		cursors = append(cursors, r)
+		return clang.ChildVisit_Continue
+	})
+	return diagnostics, rtokens, cursors
+}
+
+// lineStarts returns the offsets at which the lines of text start.
+func lineStarts(text string) []int {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// offset returns the byte offset of p in text, whose lines start at starts.
+func offset(text string, starts []int, p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(starts) {
+		return len(text)
+	}
+	i := starts[p.Line]
+	end := len(text)
+	if p.Line+1 < len(starts) {
+		end = starts[p.Line+1] - 1
+	}
+	for units := 0; i < end && units < p.Character; {
+		r, size := utf8.DecodeRuneInString(text[i:end])
+		units += len(utf16.Encode([]rune{r}))
+		i += size
+	}
+	return i
+}
+
+// position returns the position of the byte offset off in text, whose lines
+// start at starts.
+func position(text string, starts []int, off int) Position {
+	if off > len(text) {
+		off = len(text)
+	}
+	line := 0
+	for line+1 < len(starts) && starts[line+1] <= off {
+		line++
+	}
+	units := 0
+	for _, r := range text[starts[line]:off] {
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return Position{Line: line, Character: units}
+}
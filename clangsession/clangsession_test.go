@@ -0,0 +1,175 @@
+package clangsession
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+func TestPositions(t *testing.T) {
+	text := "int a;\n// é𝄞x\n\nb"
+	starts := lineStarts(text)
+	for _, tc := range []struct {
+		p   Position
+		off int
+	}{
+		{Position{0, 0}, 0},
+		{Position{0, 4}, 4},
+		{Position{0, 6}, 6},
+		{Position{1, 3}, 10},
+		{Position{1, 4}, 12},  // after é, two bytes
+		{Position{1, 6}, 16},  // after 𝄞, two UTF-16 code units and four bytes
+		{Position{1, 7}, 17},  // after x
+		{Position{1, 60}, 17}, // past the end of the line
+		{Position{2, 0}, 18},
+		{Position{3, 1}, 20},
+		{Position{9, 0}, 20}, // past the end of the buffer
+	} {
+		if got := offset(text, starts, tc.p); got != tc.off {
+			t.Errorf("offset(%v) = %d, want %d", tc.p, got, tc.off)
+		}
+		if tc.p.Line < 3 && tc.p.Character < 10 {
+			if got := position(text, starts, tc.off); got != tc.p {
+				t.Errorf("position(%d) = %v, want %v", tc.off, got, tc.p)
+			}
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	s, err := New("../testdata/hello.c", nil, "int main(void) { return x; }\n", clang.DefaultEditingTranslationUnitOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if len(s.Diagnostics()) == 0 {
+		t.Error("no diagnostic for the undeclared identifier")
+	}
+
+	// Declare x, in two edits.
+	err = s.Apply(
+		Edit{Range: &Range{Position{0, 0}, Position{0, 0}}, Text: "int y;\n"},
+		Edit{Range: &Range{Position{0, 4}, Position{0, 5}}, Text: "x"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, version := s.Contents()
+	if want := "int x;\nint main(void) { return x; }\n"; contents != want {
+		t.Fatalf("contents = %q, want %q", contents, want)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version() != version {
+		t.Errorf("parsed version %d, want %d", s.Version(), version)
+	}
+	for _, d := range s.Diagnostics() {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+
+	var names []string
+	for _, c := range s.Cursors() {
+		names = append(names, c.Spelling)
+	}
+	if len(names) != 2 || names[0] != "x" || names[1] != "main" {
+		t.Errorf("cursors %v, want [x main]", names)
+	}
+
+	tokens := s.Tokens()
+	if len(tokens) == 0 {
+		t.Fatal("no tokens")
+	}
+	last := tokens[len(tokens)-1]
+	if last.Spelling != "}" || last.Range != (Range{Position{1, 28}, Position{1, 29}}) {
+		t.Errorf("last token %v", last)
+	}
+	for _, tok := range tokens {
+		if tok.Spelling == "x" && tok.Range.Start.Line == 1 && tok.Cursor != cursorkind.DeclRefExpr {
+			t.Errorf("x in main annotated with %v", tok.Cursor)
+		}
+	}
+
+	if err := s.Apply(Edit{Range: &Range{Position{1, 2}, Position{1, 1}}}); err == nil {
+		t.Error("no error for a backward range")
+	}
+	if got, _ := s.Contents(); got != contents {
+		t.Errorf("contents changed by a failed edit")
+	}
+}
+
+func TestSessionDelay(t *testing.T) {
+	s, err := New("../testdata/hello.c", nil, "int x;\n", clang.DefaultEditingTranslationUnitOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Delay = 50 * time.Millisecond
+	parsed := make(chan int, 10)
+	s.OnParse = func(s *Session) { parsed <- s.Version() }
+
+	// A burst of edits, without a Flush, is parsed once after the delay.
+	for _, text := range []string{"int y;\n", "int z;\n"} {
+		if err := s.Apply(Edit{Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case version := <-parsed:
+		if version != 2 {
+			t.Errorf("parsed version %d, want 2", version)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no parse after the delay")
+	}
+	select {
+	case version := <-parsed:
+		t.Errorf("parsed again, version %d", version)
+	case <-time.After(5 * s.Delay):
+	}
+	if cursors := s.Cursors(); len(cursors) != 1 || cursors[0].Spelling != "z" {
+		t.Errorf("cursors %v, want [z]", cursors)
+	}
+}
+
+func TestSessionReparseFailure(t *testing.T) {
+	s, err := New("../testdata/hello.c", nil, "int x;\n", clang.DefaultEditingTranslationUnitOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	failed := 0
+	saved := reparse
+	defer func() { reparse = saved }()
+	reparse = func(clang.TranslationUnit, []clang.UnsavedFile, clang.Reparse_Flags) error {
+		failed++
+		return errors.New("reparse failed")
+	}
+
+	// The translation unit is parsed from scratch instead.
+	if err := s.Apply(Edit{Text: "int y;\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 {
+		t.Errorf("%d reparses, want 1", failed)
+	}
+	if s.Err() != nil {
+		t.Errorf("Err() = %v", s.Err())
+	}
+	if cursors := s.Cursors(); len(cursors) != 1 || cursors[0].Spelling != "y" {
+		t.Errorf("cursors %v, want [y]", cursors)
+	}
+	s.Do(func(tu clang.TranslationUnit) {
+		if !tu.IsValid() {
+			t.Error("no translation unit after parsing from scratch")
+		}
+	})
+}
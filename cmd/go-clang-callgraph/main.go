@@ -0,0 +1,90 @@
+// go-clang-callgraph writes the call graph of every entry of a compilation
+// database, as Graphviz DOT or JSON.
+//
+// Calls through function pointers cannot be resolved statically and show up
+// as unresolved edges, labelled with the expression called. Functions are
+// merged across translation units by USR.
+//
+// $ go-clang-callgraph /path/to/build > callgraph.dot
+// or, to keep what main reaches in at most two calls, as JSON
+// $ go-clang-callgraph -root main -depth 2 -format json /path/to/build
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangcallgraph"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-callgraph", flag.ContinueOnError)
+	format := flags.String("format", "dot", "output format, dot or json")
+	roots := flags.String("root", "", "comma separated names or USRs of the functions to start from")
+	depth := flags.Int("depth", -1, "maximum number of calls followed from the roots, -1 for no limit")
+	output := flags.String("o", "", "write the graph to this file instead of the standard output")
+	cflags := flags.String("cflags", "", "space separated flags to pass to clang")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "dot" && *format != "json" {
+		fmt.Printf("**error: unknown format %q\n", *format)
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Printf("**error: you need to give a directory containing a 'compile_commands.json' file\n")
+		return 1
+	}
+
+	db, err := clang.FromDirectory(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("**error: could not open compilation database at [%s]: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	g, problems := clangcallgraph.Build(db.AllCompileCommands(), strings.Fields(*cflags))
+	db.Dispose()
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, "PROBLEM:", p)
+	}
+
+	var names []string
+	if *roots != "" {
+		names = strings.Split(*roots, ",")
+		for _, name := range names {
+			if len(g.Lookup(name)) == 0 {
+				fmt.Printf("**error: no function %s\n", name)
+				return 1
+			}
+		}
+	}
+	g = g.Filter(names, *depth)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Printf("**error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		err = g.WriteJSON(w)
+	} else {
+		err = g.WriteDOT(w)
+	}
+	if err != nil {
+		fmt.Printf("**error: %v\n", err)
+		return 1
+	}
+	return 0
+}
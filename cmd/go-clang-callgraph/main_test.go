@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoClangCallgraph(t *testing.T) {
+	testdata, err := filepath.Abs("../../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]string
+	for _, file := range []string{"hello.c", "callgraph.c"} {
+		entries = append(entries, map[string]string{
+			"directory": testdata,
+			"command":   "cc -c -o " + file + ".o " + file,
+			"file":      file,
+		})
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "callgraph.dot")
+
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-cflags", *cflags, "-o", output, dir}, 0},
+		{[]string{"-cflags", *cflags, "-format", "json", "-root", "main", "-depth", "1", dir}, 0},
+		{[]string{"-cflags", *cflags, "-root", "nothing", dir}, 1},
+		{[]string{"-format", "svg", dir}, 2},
+		{[]string{}, 1},
+	} {
+		if r := cmd(tc.args); r != tc.want {
+			t.Errorf("cmd(%v) = %d, want %d", tc.args, r, tc.want)
+		}
+	}
+
+	dot, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(dot), "digraph callgraph {") {
+		t.Errorf("unexpected output:\n%s", dot)
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
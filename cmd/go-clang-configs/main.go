@@ -0,0 +1,152 @@
+// go-clang-configs parses a source file under several preprocessor
+// configurations and reports the lines each one compiles out, the lines no
+// configuration compiles, and the declarations whose signature differs
+// between configurations.
+//
+// A configuration is given with -config as a name and the macros to define,
+// name:A,B=1, or just the macros. The source file follows the flags, and the
+// arguments after it are passed to libclang for every configuration.
+//
+// $ go-clang-configs -config base: -config debug:DEBUG,LEVEL=2 file.c -Iinclude
+// or, to see which lines every configuration compiles
+// $ go-clang-configs -lines -config base: -config DEBUG file.c
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangconfigs"
+)
+
+// configs is the value of the repeated -config flag.
+type configs []clangconfigs.Config
+
+func (cs *configs) String() string {
+	var names []string
+	for _, c := range *cs {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func (cs *configs) Set(s string) error {
+	c, err := clangconfigs.ParseConfig(s)
+	if err != nil {
+		return err
+	}
+	*cs = append(*cs, c)
+	return nil
+}
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-configs", flag.ContinueOnError)
+	var cs configs
+	flags.Var(&cs, "config", "a configuration, name:A,B=1, may be repeated")
+	lines := flags.Bool("lines", false, "print the source with the configurations compiling every line")
+	asJSON := flags.Bool("json", false, "write the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if len(cs) == 0 {
+		fmt.Printf("**error: you need to give at least one configuration with -config\n")
+		return 2
+	}
+	if flags.NArg() < 1 {
+		fmt.Printf("**error: you need to give a source file\n")
+		return 1
+	}
+	source := flags.Arg(0)
+
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+	r, err := clangconfigs.Explore(idx, source, flags.Args()[1:], cs)
+	if err != nil {
+		fmt.Printf("**error: %v\n", err)
+		return 1
+	}
+
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(r)
+	case *lines:
+		err = printLines(r)
+	default:
+		printReport(r)
+	}
+	if err != nil {
+		fmt.Printf("**error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printReport(r *clangconfigs.Report) {
+	fmt.Printf("%s, %d lines\n", r.File, r.Lines)
+	for i, c := range r.Configs {
+		fmt.Printf("%s (%s): dead %s\n", c.Name, strings.Join(c.Args(), " "), join(r.DeadRanges[i]))
+	}
+	fmt.Printf("dead under every configuration: %s\n", join(r.AlwaysDead))
+	if len(r.Differences) == 0 {
+		return
+	}
+	fmt.Printf("declarations differing between configurations:\n")
+	for _, d := range r.Differences {
+		fmt.Printf("\t%s\n", d.Name)
+		for i, sig := range d.Signatures {
+			if sig == "" {
+				sig = "(not declared)"
+			}
+			fmt.Printf("\t\t%s: %s\n", r.Configs[i].Name, sig)
+		}
+	}
+}
+
+func join(ranges []clangconfigs.Range) string {
+	if len(ranges) == 0 {
+		return "none"
+	}
+	var s []string
+	for _, r := range ranges {
+		s = append(s, r.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+// printLines prints every line of the source preceded by a column per
+// configuration, + when the configuration compiles the line and - when not.
+func printLines(r *clangconfigs.Report) error {
+	contents, err := ioutil.ReadFile(r.File)
+	if err != nil {
+		return err
+	}
+	for i, c := range r.Configs {
+		fmt.Printf("%s%s\n", strings.Repeat("|", i), c.Name)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(contents))
+	for line := 1; sc.Scan(); line++ {
+		var marks strings.Builder
+		for i := range r.Configs {
+			if r.Live(i, line) {
+				marks.WriteByte('+')
+			} else {
+				marks.WriteByte('-')
+			}
+		}
+		fmt.Printf("%s %4d  %s\n", marks.String(), line, sc.Text())
+	}
+	return sc.Err()
+}
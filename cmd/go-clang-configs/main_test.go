@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestGoClangConfigs(t *testing.T) {
+	additional := strings.Fields(*cflags)
+	source := "../../testdata/configs.c"
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-config", "base:", "-config", "debug:DEBUG", "-config", "WIDE", source}, 0},
+		{[]string{"-lines", "-config", "base:", "-config", "DEBUG", source}, 0},
+		{[]string{"-json", "-config", "DEBUG,WIDE", source}, 0},
+		{[]string{"-config", ":DEBUG", source}, 2},
+		{[]string{source}, 2},
+		{[]string{"-config", "DEBUG"}, 1},
+	} {
+		args := tc.args
+		if tc.want == 0 {
+			args = append(args, additional...)
+		}
+		if r := cmd(args); r != tc.want {
+			t.Errorf("cmd(%v) = %d, want %d", args, r, tc.want)
+		}
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/typekind"
+	"github.com/frankreh/go-clang/internal/clangheaders"
+)
+
+// cType is a parameter or result type of a libclang function.
+type cType struct {
+	Spelling string        // as written in the header, e.g. "const char *"
+	Kind     typekind.Kind // kind of the canonical type
+	Enum     string        // first enumerator, when the canonical type is an enum
+}
+
+type param struct {
+	Name string
+	Type cType
+}
+
+// function is an exported clang_* function of the libclang headers.
+type function struct {
+	Name    string
+	File    string // header base name, e.g. Index.h
+	Comment string // raw doc comment, as found in the header
+	Result  cType
+	Params  []param
+}
+
+// extractFunctions parses every header in dir/clang-c and returns the clang_*
+// functions declared there, in header order. The cflags are passed on to
+// clang.
+func extractFunctions(dir string, cflags []string) ([]function, error) {
+	var r []function
+	err := clangheaders.Parse(dir, "go-clang-coverage.c", cflags, func(tu clang.TranslationUnit) {
+		r = functionsOf(tu)
+	})
+	return r, err
+}
+
+// functionsOf returns the clang_* functions declared in the translation unit,
+// in order.
+func functionsOf(tu clang.TranslationUnit) []function {
+	var r []function
+	seen := make(map[string]bool)
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		if cursor.Kind() != cursorkind.FunctionDecl {
+			return clang.ChildVisit_Continue
+		}
+		name := cursor.Spelling()
+		if !strings.HasPrefix(name, "clang_") || seen[name] {
+			return clang.ChildVisit_Continue
+		}
+		seen[name] = true
+
+		file, _, _, _ := cursor.Location().FileLocation()
+		f := function{
+			Name:    name,
+			File:    filepath.Base(file.Name()),
+			Comment: cursor.RawCommentText(),
+			Result:  newCType(cursor.ResultType()),
+		}
+		for i := int32(0); i < cursor.NumArguments(); i++ {
+			arg := cursor.Argument(uint32(i))
+			f.Params = append(f.Params, param{
+				Name: arg.Spelling(),
+				Type: newCType(arg.Type()),
+			})
+		}
+		r = append(r, f)
+		return clang.ChildVisit_Continue
+	})
+	return r
+}
+
+func newCType(t clang.Type) cType {
+	canonical := t.CanonicalType()
+	ct := cType{
+		Spelling: t.Spelling(),
+		Kind:     canonical.Kind(),
+	}
+	if ct.Kind == typekind.Enum {
+		canonical.Declaration().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+			if cursor.Kind() == cursorkind.EnumConstantDecl {
+				ct.Enum = cursor.Spelling()
+				return clang.ChildVisit_Break
+			}
+			return clang.ChildVisit_Continue
+		})
+	}
+	return ct
+}
@@ -0,0 +1,157 @@
+// go-clang-coverage reports which libclang functions the clang package wraps
+// and writes first-draft wrappers for the ones it does not.
+//
+// The vendored clang/clang-c headers are parsed with the clang package itself
+// and every exported clang_* function is listed, marked wrapped when the Go or
+// C sources of the clang package call it. With -stubs, the missing functions
+// are written as receiver methods in the style of the clang package to the
+// given file, to be reviewed and moved into place by hand. Functions taking
+// callbacks, out parameters or other types that cannot be converted
+// mechanically are listed at the top of that file.
+//
+// $ go-clang-coverage -clang ../../clang
+// or, to list only the missing functions
+// $ go-clang-coverage -clang ../../clang -missing
+// or, to write the stubs
+// $ go-clang-coverage -clang ../../clang -stubs /tmp/stubs.go
+//
+// Arguments after the flags are passed on to clang, e.g. include paths needed
+// to find the system headers.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-coverage", flag.ContinueOnError)
+	clangDir := flags.String("clang", "clang", "directory of the clang package, holding clang-c")
+	missing := flags.Bool("missing", false, "list only the functions that are not wrapped")
+	stubs := flags.String("stubs", "", "write first-draft wrappers for the missing functions to this file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	functions, err := extractFunctions(*clangDir, flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	called, enumTypes, err := scanPackage(*clangDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var unwrapped []function
+	for _, f := range functions {
+		if !called[f.Name] {
+			unwrapped = append(unwrapped, f)
+		}
+		if *missing && called[f.Name] {
+			continue
+		}
+		status := "missing"
+		if called[f.Name] {
+			status = "wrapped"
+		}
+		fmt.Printf("%-8s %-25s %s\n", status, f.File, f.Name)
+	}
+	wrapped := len(functions) - len(unwrapped)
+	if len(functions) > 0 {
+		fmt.Printf("%d of %d functions wrapped (%.0f%%)\n", wrapped, len(functions), 100*float64(wrapped)/float64(len(functions)))
+	}
+
+	if *stubs != "" {
+		g := &stubGen{
+			enumTypes: enumTypes,
+			disposers: make(map[string]string),
+			headers:   make(map[string]bool),
+		}
+		for _, f := range functions {
+			if strings.Contains(strings.ToLower(f.Name), "dispose") && len(f.Params) == 1 {
+				g.disposers[stripConst(f.Params[0].Type.Spelling)] = f.Name
+			}
+		}
+		src, err := g.stubs(unwrapped)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := ioutil.WriteFile(*stubs, src, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+var cCall = regexp.MustCompile(`\b(clang_\w+)\s*\(`)
+
+// scanPackage returns the clang_* functions called by the clang package in
+// dir, and the Go type of every C enumerator the package defines a constant
+// for.
+func scanPackage(dir string) (map[string]bool, map[string]string, error) {
+	called := make(map[string]bool)
+	enumTypes := make(map[string]string)
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range pkgs {
+		ast.Inspect(pkg, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if x, ok := n.X.(*ast.Ident); ok && x.Name == "C" && strings.HasPrefix(n.Sel.Name, "clang_") {
+					called[n.Sel.Name] = true
+				}
+			case *ast.ValueSpec:
+				typ, ok := n.Type.(*ast.Ident)
+				if !ok {
+					break
+				}
+				for _, v := range n.Values {
+					if sel, ok := v.(*ast.SelectorExpr); ok {
+						if x, ok := sel.X.(*ast.Ident); ok && x.Name == "C" {
+							enumTypes[sel.Sel.Name] = typ.Name
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	// The C helpers of the package call some functions on its behalf.
+	cfiles, err := filepath.Glob(filepath.Join(dir, "*.c"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range cfiles {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range cCall.FindAllSubmatch(src, -1) {
+			called[string(m[1])] = true
+		}
+	}
+	return called, enumTypes, nil
+}
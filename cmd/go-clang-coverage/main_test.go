@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+func TestGoClangCoverage(t *testing.T) {
+	additional := dropEmpties(strings.Split(*cflags, " "))
+	stubs := filepath.Join(t.TempDir(), "stubs.go")
+	for _, args := range [][]string{
+		[]string{"-clang", "../../clang"},
+		[]string{"-clang", "../../clang", "-missing", "-stubs", stubs},
+	} {
+		args = append(args, additional...)
+		r := cmd(args)
+		if r != 0 {
+			t.Errorf("cmd(%v) = %d", args, r)
+		}
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), stubs, nil, 0); err != nil {
+		t.Errorf("stubs: %v", err)
+	}
+}
+
+func TestScanPackage(t *testing.T) {
+	called, enumTypes, err := scanPackage("../../clang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"clang_getCursorSpelling", "clang_disposeString", "clang_visitChildren"} {
+		if !called[name] {
+			t.Errorf("%s not found", name)
+		}
+	}
+	if got := enumTypes["CXLinkage_External"]; got != "LinkageKind" {
+		t.Errorf("enumTypes[CXLinkage_External] = %q", got)
+	}
+}
+
+func TestStub(t *testing.T) {
+	g := &stubGen{
+		enumTypes: map[string]string{"CXLinkage_Invalid": "LinkageKind"},
+		disposers: map[string]string{"CXEvalResult": "clang_EvalResult_dispose", "CXTargetInfo": "clang_TargetInfo_dispose"},
+		headers:   make(map[string]bool),
+	}
+	cursor := cType{Spelling: "CXCursor", Kind: typekind.Record}
+	location := cType{Spelling: "CXSourceLocation", Kind: typekind.Record}
+	for _, tc := range []struct {
+		f    function
+		want string
+	}{
+		{
+			function{Name: "clang_Cursor_getVarDeclInitializer", Result: cursor, Params: []param{{"cursor", cursor}}},
+			"func (c Cursor) VarDeclInitializer() Cursor {\n\treturn Cursor{C.clang_Cursor_getVarDeclInitializer(c.c)}\n}\n",
+		},
+		{
+			function{Name: "clang_Cursor_isExternalSymbol", Result: cType{Spelling: "unsigned int", Kind: typekind.UInt}, Params: []param{{"C", cursor}}},
+			"func (c Cursor) IsExternalSymbol() bool {\n\treturn C.clang_Cursor_isExternalSymbol(c.c) != 0\n}\n",
+		},
+		{
+			function{Name: "clang_getCursorLinkage", Result: cType{Spelling: "enum CXLinkageKind", Kind: typekind.Enum, Enum: "CXLinkage_Invalid"}, Params: []param{{"cursor", cursor}}},
+			"func (c Cursor) Linkage() LinkageKind {\n\treturn LinkageKind(C.clang_getCursorLinkage(c.c))\n}\n",
+		},
+		{
+			function{Name: "clang_getCursorPrettyPrinted", Result: cType{Spelling: "CXString", Kind: typekind.Record}, Params: []param{{"Cursor", cursor}, {"Policy", cType{Spelling: "CXPrintingPolicy", Kind: typekind.Pointer}}}},
+			"func (c Cursor) PrettyPrinted(policy PrintingPolicy) string {\n\treturn cx2GoString(C.clang_getCursorPrettyPrinted(c.c, policy.c))\n}\n",
+		},
+		{
+			// A parameter named C does not shadow cgo.
+			function{Name: "clang_equalLocations", Result: cType{Spelling: "unsigned int", Kind: typekind.UInt}, Params: []param{{"A", location}, {"C", location}}},
+			"func (sl SourceLocation) EqualLocations(c SourceLocation) uint32 {\n\treturn uint32(C.clang_equalLocations(sl.c, c.c))\n}\n",
+		},
+		{
+			function{Name: "clang_Cursor_Evaluate", Result: cType{Spelling: "CXEvalResult", Kind: typekind.Pointer}, Params: []param{{"C", cursor}}},
+			"// The result must be released with Dispose.\nfunc (c Cursor) Evaluate() EvalResult {\n\treturn EvalResult{C.clang_Cursor_Evaluate(c.c)}\n}\n",
+		},
+		{
+			function{Name: "clang_Cursor_getCXXManglings", Result: cType{Spelling: "CXStringSet *", Kind: typekind.Pointer}, Params: []param{{"C", cursor}}},
+			"func (c Cursor) CXXManglings() []string {\n\treturn copyAndDisposeStringSet(C.clang_Cursor_getCXXManglings(c.c))\n}\n",
+		},
+		{
+			function{Name: "clang_getBuildSessionTimestamp", Result: cType{Spelling: "unsigned long long", Kind: typekind.ULongLong}},
+			"func GetBuildSessionTimestamp() uint64 {\n\treturn uint64(C.clang_getBuildSessionTimestamp())\n}\n",
+		},
+	} {
+		got, reason := g.stub(tc.f)
+		if got != tc.want {
+			t.Errorf("stub(%s) = %q, %q; want %q", tc.f.Name, got, reason, tc.want)
+		}
+	}
+
+	visitor := function{Name: "clang_Type_visitFields", Result: cType{Spelling: "unsigned int", Kind: typekind.UInt}, Params: []param{
+		{"T", cType{Spelling: "CXType", Kind: typekind.Record}},
+		{"visitor", cType{Spelling: "CXFieldVisitor", Kind: typekind.Pointer}},
+		{"client_data", cType{Spelling: "CXClientData", Kind: typekind.Pointer}},
+	}}
+	if _, reason := g.stub(visitor); reason == "" {
+		t.Errorf("stub(%s) has no reason to be wrapped by hand", visitor.Name)
+	}
+	// What cannot be converted mechanically is left with its dispose to defer.
+	targetInfo := function{Name: "clang_getTranslationUnitTargetInfo", Result: cType{Spelling: "CXTargetInfo", Kind: typekind.Pointer}, Params: []param{
+		{"CTUnit", cType{Spelling: "CXTranslationUnit", Kind: typekind.Pointer}},
+	}}
+	if _, reason := g.stub(targetInfo); !strings.Contains(reason, "clang_TargetInfo_dispose") {
+		t.Errorf("stub(%s) reason %q does not tell the dispose", targetInfo.Name, reason)
+	}
+
+	for _, tc := range []struct{ name, want string }{
+		{"Policy", "policy"},
+		{"C", "c"},
+		{"TU", "tu"},
+		{"CIdx", "cIdx"},
+		{"client_data", "client_data"},
+		{"type", "p0"},
+		{"", "p0"},
+	} {
+		if got := paramName(tc.name, 0, nil); got != tc.want {
+			t.Errorf("paramName(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
+
+func dropEmpties(ss []string) (r []string) {
+	for _, s := range ss {
+		if s != "" {
+			r = append(r, s)
+		}
+	}
+	return
+}
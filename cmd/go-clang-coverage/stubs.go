@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/frankreh/go-clang/clang/typekind"
+	"github.com/frankreh/go-clang/internal/clangheaders"
+)
+
+// wrapper is a clang package type holding a single C value in its c field.
+type wrapper struct {
+	Type     string
+	Receiver string
+}
+
+// wrappers is keyed by the C type spelling.
+var wrappers = map[string]wrapper{
+	"CXCompletionString":    {"CompletionString", "cs"},
+	"CXComment":             {"Comment", "c"},
+	"CXCursor":              {"Cursor", "c"},
+	"CXCursorSet":           {"CursorSet", "cs"},
+	"CXDiagnostic":          {"Diagnostic", "d"},
+	"CXDiagnosticSet":       {"DiagnosticSet", "ds"},
+	"CXEvalResult":          {"EvalResult", "er"},
+	"CXFile":                {"File", "f"},
+	"CXIndex":               {"Index", "i"},
+	"CXIndexAction":         {"IndexAction", "ia"},
+	"CXModule":              {"Module", "m"},
+	"CXModuleMapDescriptor": {"ModuleMapDescriptor", "mmd"},
+	"CXPrintingPolicy":      {"PrintingPolicy", "p"},
+	"CXSourceLocation":      {"SourceLocation", "sl"},
+	"CXSourceRange":         {"SourceRange", "sr"},
+	"CXToken":               {"Token", "t"},
+	"CXTranslationUnit":     {"TranslationUnit", "tu"},
+	"CXType":                {"Type", "t"},
+	"CXVirtualFileOverlay":  {"VirtualFileOverlay", "vfo"},
+}
+
+// scalars maps the canonical kinds of C integer and floating types to the Go
+// type used by the clang package and the cgo type used to pass it.
+var scalars = map[typekind.Kind][2]string{
+	typekind.Int:       {"int32", "C.int"},
+	typekind.UInt:      {"uint32", "C.uint"},
+	typekind.Long:      {"int64", "C.long"},
+	typekind.ULong:     {"uint64", "C.ulong"},
+	typekind.LongLong:  {"int64", "C.longlong"},
+	typekind.ULongLong: {"uint64", "C.ulonglong"},
+	typekind.Double:    {"float64", "C.double"},
+}
+
+// stubGen writes first-draft wrappers in the style of the clang package.
+type stubGen struct {
+	enumTypes map[string]string // C enumerator to the Go type of its constant
+	disposers map[string]string // C type to the clang_* function disposing it
+	headers   map[string]bool   // headers the generated source needs
+	unsafe    bool              // the generated source uses unsafe
+}
+
+func stripConst(s string) string {
+	return strings.TrimSpace(strings.TrimPrefix(s, "const "))
+}
+
+// goType returns the Go type for a C type, or "" when it has to be wrapped by
+// hand.
+func (g *stubGen) goType(t cType) string {
+	s := stripConst(t.Spelling)
+	switch {
+	case s == "CXString":
+		return "string"
+	case s == "CXStringSet *":
+		return "[]string"
+	case s == "enum CXErrorCode":
+		return "error"
+	case s == "char *" && strings.HasPrefix(t.Spelling, "const "):
+		return "string"
+	case wrappers[s].Type != "":
+		return wrappers[s].Type
+	case t.Kind == typekind.Enum:
+		return g.enumTypes[t.Enum]
+	}
+	return scalars[t.Kind][0]
+}
+
+// toC returns the expression converting the Go value v of type t to C, adding
+// any setup statements to b.
+func (g *stubGen) toC(b *bytes.Buffer, v string, t cType) string {
+	s := stripConst(t.Spelling)
+	switch {
+	case s == "char *":
+		g.unsafe = true
+		fmt.Fprintf(b, "\tc_%s := C.CString(%s)\n", v, v)
+		fmt.Fprintf(b, "\tdefer C.free(unsafe.Pointer(c_%s))\n", v)
+		return "c_" + v
+	case wrappers[s].Type != "":
+		return v + ".c"
+	case t.Kind == typekind.Enum:
+		if strings.HasPrefix(s, "enum ") {
+			return fmt.Sprintf("C.enum_%s(%s)", strings.TrimPrefix(s, "enum "), v)
+		}
+		return fmt.Sprintf("C.%s(%s)", s, v)
+	}
+	return fmt.Sprintf("%s(%s)", scalars[t.Kind][1], v)
+}
+
+// fromC returns the expression converting the C result expr to goType.
+func (g *stubGen) fromC(expr string, t cType, goType string) string {
+	s := stripConst(t.Spelling)
+	switch {
+	case s == "CXString":
+		return fmt.Sprintf("cx2GoString(%s)", expr)
+	case s == "CXStringSet *":
+		return fmt.Sprintf("copyAndDisposeStringSet(%s)", expr)
+	case s == "enum CXErrorCode":
+		return fmt.Sprintf("convertErrorCode(%s)", expr)
+	case s == "char *":
+		return fmt.Sprintf("C.GoString(%s)", expr)
+	case goType == "bool":
+		return expr + " != 0"
+	case wrappers[s].Type != "":
+		return fmt.Sprintf("%s{%s}", goType, expr)
+	}
+	return fmt.Sprintf("%s(%s)", goType, expr)
+}
+
+// goName returns the method or function name for a clang_* function, given
+// the receiver type if it has one.
+func goName(cName string, recv *wrapper) string {
+	name := strings.TrimPrefix(cName, "clang_")
+	if recv != nil {
+		name = strings.TrimPrefix(name, recv.Type+"_")
+		if strings.HasPrefix(name, "dispose") || strings.HasSuffix(name, "_dispose") {
+			return "Dispose"
+		}
+		if strings.HasPrefix(name, "get") && len(name) > 3 {
+			name = name[3:]
+			if (recv.Type == "Cursor" || recv.Type == "Type") && strings.HasPrefix(name, recv.Type) && len(name) > len(recv.Type) {
+				name = name[len(recv.Type):]
+			}
+		}
+	}
+	// Keep a C++ style family prefix, as in CXXMethod_IsStatic.
+	var parts []string
+	for _, p := range strings.Split(name, "_") {
+		parts = append(parts, capitalize(p))
+	}
+	return strings.Join(parts, "_")
+}
+
+// paramName returns the Go name of the C parameter named name, the i-th after
+// any receiver: lower case, so C of cgo or the exported names of the package
+// are not shadowed, as in policy for Policy or tu for TU.
+func paramName(name string, i int, recv *wrapper) string {
+	r := []rune(name)
+	n := 0
+	for n < len(r) && unicode.IsUpper(r[n]) {
+		n++
+	}
+	if n > 1 && n < len(r) {
+		n-- // The capital starting the next word, as in CIdx.
+	}
+	for j := 0; j < n; j++ {
+		r[j] = unicode.ToLower(r[j])
+	}
+	v := string(r)
+	if v == "" || token.IsKeyword(v) || (recv != nil && v == recv.Receiver) {
+		v = fmt.Sprintf("p%d", i)
+	}
+	return v
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// stub returns the wrapper for f, or a reason why it has to be written by
+// hand.
+func (g *stubGen) stub(f function) (string, string) {
+	var b, setup bytes.Buffer
+	usesUnsafe := g.unsafe
+	defer func() { g.unsafe = usesUnsafe }()
+
+	params := f.Params
+	var recv *wrapper
+	if len(params) > 0 {
+		if w, ok := wrappers[stripConst(params[0].Type.Spelling)]; ok {
+			recv = &w
+			params = params[1:]
+		}
+	}
+	name := goName(f.Name, recv)
+
+	var args, cArgs []string
+	if recv != nil {
+		cArgs = append(cArgs, recv.Receiver+".c")
+	}
+	for i, p := range params {
+		v := paramName(p.Name, i, recv)
+		t := g.goType(p.Type)
+		if t == "" || t == "error" || stripConst(p.Type.Spelling) == "CXString" {
+			return "", fmt.Sprintf("parameter %s has type %s", p.Name, p.Type.Spelling)
+		}
+		args = append(args, v+" "+t)
+		cArgs = append(cArgs, g.toC(&setup, v, p.Type))
+	}
+
+	result := ""
+	if f.Result.Kind != typekind.Void {
+		result = g.goType(f.Result)
+		if result == "" {
+			if d, ok := g.disposers[stripConst(f.Result.Spelling)]; ok {
+				// To copy out of, deferring the dispose, as TargetInfo does.
+				return "", fmt.Sprintf("result has type %s, released with %s", f.Result.Spelling, d)
+			}
+			return "", fmt.Sprintf("result has type %s", f.Result.Spelling)
+		}
+		if (strings.HasPrefix(name, "Is") || strings.HasPrefix(name, "Has")) && scalars[f.Result.Kind][0] != "" {
+			result = "bool"
+		}
+	}
+
+	doc := clangheaders.DocText(f.Comment)
+	if _, ok := g.disposers[stripConst(f.Result.Spelling)]; ok && wrappers[stripConst(f.Result.Spelling)].Type != "" {
+		// The caller owns what is returned. Results converted to Go values,
+		// as strings are, are disposed of by the conversion.
+		if doc != "" {
+			doc += "\n\n"
+		}
+		doc += "The result must be released with Dispose."
+	}
+	clangheaders.WriteComment(&b, "", doc)
+	b.WriteString("func ")
+	if recv != nil {
+		fmt.Fprintf(&b, "(%s %s) ", recv.Receiver, recv.Type)
+	}
+	fmt.Fprintf(&b, "%s(%s) %s {\n", name, strings.Join(args, ", "), result)
+	b.Write(setup.Bytes())
+	call := fmt.Sprintf("C.%s(%s)", f.Name, strings.Join(cArgs, ", "))
+	if result == "" {
+		fmt.Fprintf(&b, "\t%s\n", call)
+	} else {
+		fmt.Fprintf(&b, "\treturn %s\n", g.fromC(call, f.Result, result))
+	}
+	b.WriteString("}\n")
+	usesUnsafe = g.unsafe
+	return b.String(), ""
+}
+
+// stubs returns the source of a clang package file wrapping the functions.
+// Functions that cannot be wrapped mechanically are listed in a comment.
+func (g *stubGen) stubs(functions []function) ([]byte, error) {
+	var body bytes.Buffer
+	var manual []string
+	for _, f := range functions {
+		src, reason := g.stub(f)
+		if reason != "" {
+			manual = append(manual, fmt.Sprintf("%s: %s", f.Name, reason))
+			continue
+		}
+		body.WriteString("\n")
+		body.WriteString(src)
+		if f.File != "Index.h" {
+			g.headers[f.File] = true
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("package clang\n\n")
+	var headers []string
+	for h := range g.headers {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	for _, h := range headers {
+		fmt.Fprintf(&b, "// #include \"./clang-c/%s\"\n", h)
+	}
+	b.WriteString("// #include \"go-clang.h\"\nimport \"C\"\n")
+	if g.unsafe {
+		b.WriteString("import \"unsafe\"\n")
+	}
+	if len(manual) > 0 {
+		b.WriteString("\n// The following functions need to be wrapped by hand:\n//\n")
+		for _, m := range manual {
+			fmt.Fprintf(&b, "//\t%s\n", m)
+		}
+	}
+	b.Write(body.Bytes())
+	return format.Source(b.Bytes())
+}
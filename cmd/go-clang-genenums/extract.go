@@ -0,0 +1,123 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/internal/clangheaders"
+)
+
+// enumConst is one enumerator of a C enum.
+type enumConst struct {
+	Name    string // C spelling, e.g. CXCursor_UnexposedDecl
+	Value   int64
+	Alias   string // C spelling of the enumerator this one is defined as, if any
+	Comment string // raw doc comment, as found in the header
+}
+
+// enumDecl is a C enum whose name starts with CX.
+type enumDecl struct {
+	Name    string // enum tag, or typedef name for anonymous enums
+	File    string // header base name, e.g. Index.h
+	Comment string
+	Consts  []enumConst
+}
+
+// lookup returns the enumerator with the given C name.
+func (e *enumDecl) lookup(name string) (enumConst, bool) {
+	for _, c := range e.Consts {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return enumConst{}, false
+}
+
+// extractEnums parses every header in dir/clang-c and returns the CX enums
+// defined there, keyed by name. The cflags are passed on to clang.
+func extractEnums(dir string, cflags []string) (map[string]*enumDecl, error) {
+	var r map[string]*enumDecl
+	err := clangheaders.Parse(dir, "go-clang-genenums.c", cflags, func(tu clang.TranslationUnit) {
+		r = enumsOf(tu)
+	})
+	return r, err
+}
+
+// enumsOf returns the CX enums defined in the translation unit, keyed by
+// name.
+func enumsOf(tu clang.TranslationUnit) map[string]*enumDecl {
+	// Anonymous enums are only known by the typedef naming them.
+	typedefs := make(map[clang.Cursor]string)
+	var enums []clang.Cursor
+
+	tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		switch cursor.Kind() {
+		case cursorkind.TypedefDecl:
+			decl := cursor.TypedefDeclUnderlyingType().Declaration()
+			if decl.Kind() == cursorkind.EnumDecl {
+				if _, ok := typedefs[decl]; !ok {
+					typedefs[decl] = cursor.Spelling()
+				}
+			}
+		case cursorkind.EnumDecl:
+			if cursor.IsCursorDefinition() {
+				enums = append(enums, cursor)
+			}
+		}
+		return clang.ChildVisit_Continue
+	})
+
+	r := make(map[string]*enumDecl)
+	for _, cursor := range enums {
+		name := cursor.Spelling()
+		if cursor.IsAnonymous() {
+			name = typedefs[cursor]
+		}
+		if !strings.HasPrefix(name, "CX") {
+			continue
+		}
+		if _, ok := r[name]; ok {
+			continue
+		}
+		file, _, _, _ := cursor.Location().FileLocation()
+		e := &enumDecl{
+			Name:    name,
+			File:    filepath.Base(file.Name()),
+			Comment: cursor.RawCommentText(),
+		}
+		cursor.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+			if cursor.Kind() == cursorkind.EnumConstantDecl {
+				e.Consts = append(e.Consts, enumConst{
+					Name:    cursor.Spelling(),
+					Value:   cursor.EnumConstantDeclValue(),
+					Alias:   aliasOf(cursor),
+					Comment: cursor.RawCommentText(),
+				})
+			}
+			return clang.ChildVisit_Continue
+		})
+		r[name] = e
+	}
+	return r
+}
+
+// aliasOf returns the name of the enumerator an EnumConstantDecl is
+// initialized with, as in CXCursor_FirstDecl = CXCursor_UnexposedDecl, or ""
+// when the initializer is anything else.
+func aliasOf(cursor clang.Cursor) string {
+	alias := ""
+	cursor.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+		switch cursor.Kind() {
+		case cursorkind.UnexposedExpr, cursorkind.ParenExpr:
+			return clang.ChildVisit_Recurse
+		case cursorkind.DeclRefExpr:
+			if cursor.Referenced().Kind() == cursorkind.EnumConstantDecl {
+				alias = cursor.Spelling()
+			}
+		}
+		return clang.ChildVisit_Break
+	})
+	return alias
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/internal/clangheaders"
+)
+
+// genKindPackage returns the kind.go source of one of the cgo-free kind
+// packages.
+func genKindPackage(p kindPackage, e *enumDecl) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by go-clang-genenums from clang-c/%s; DO NOT EDIT.\n\n", e.File)
+	fmt.Fprintf(&b, "package %s\n\n", p.Dir)
+	doc := clangheaders.DocText(e.Comment)
+	if doc == "" {
+		doc = p.Doc
+	}
+	clangheaders.WriteComment(&b, "", doc)
+	b.WriteString("type Kind int\n\n")
+	b.WriteString("//go:generate stringer -type=Kind\n\n")
+
+	name := func(c string) string { return strings.TrimPrefix(c, p.Prefix) }
+
+	b.WriteString("const (\n")
+	var aliases []enumConst
+	for _, c := range e.Consts {
+		if c.Alias != "" {
+			aliases = append(aliases, c)
+			continue
+		}
+		clangheaders.WriteComment(&b, "\t", clangheaders.DocText(c.Comment))
+		fmt.Fprintf(&b, "\t%s Kind = %d\n", name(c.Name), c.Value)
+	}
+	for _, x := range p.Extras {
+		b.WriteString("\n")
+		clangheaders.WriteComment(&b, "\t", x.Comment)
+		fmt.Fprintf(&b, "\t%s Kind = %d\n", x.Name, x.Value)
+	}
+	if len(aliases) > 0 {
+		b.WriteString("\n")
+	}
+	for _, c := range aliases {
+		clangheaders.WriteComment(&b, "\t", clangheaders.DocText(c.Comment))
+		fmt.Fprintf(&b, "\t%s Kind = %s\n", name(c.Name), name(c.Alias))
+	}
+	b.WriteString(")\n\n")
+
+	// Validate accepts every value of the enum, one case per run of
+	// consecutive values.
+	first := make(map[int64]string)
+	var values []int64
+	for _, c := range e.Consts {
+		if _, ok := first[c.Value]; !ok {
+			first[c.Value] = name(c.Name)
+			values = append(values, c.Value)
+		}
+	}
+	for _, x := range p.Extras {
+		if _, ok := first[x.Value]; !ok {
+			first[x.Value] = x.Name
+			values = append(values, x.Value)
+		}
+	}
+	b.WriteString("func Validate(i int) (Kind, error) {\n\tswitch {\n")
+	for _, r := range runs(values) {
+		if r[0] == r[1] {
+			fmt.Fprintf(&b, "\tcase i == int(%s):\n", first[r[0]])
+		} else {
+			fmt.Fprintf(&b, "\tcase int(%s) <= i && i <= int(%s):\n", first[r[0]], first[r[1]])
+		}
+		b.WriteString("\t\treturn Kind(i), nil\n")
+	}
+	b.WriteString("\tdefault:\n\t\treturn 0, InvalidErr\n\t}\n}\n\n")
+
+	b.WriteString(`func MustValidate(i int) Kind {
+	kind, err := Validate(i)
+	if err != nil {
+		panic(err.Error() + " " + Kind(i).String())
+	}
+	return kind
+}
+
+type Error string
+
+const InvalidErr Error = "Invalid value"
+
+func (e Error) Error() string {
+	return string(e)
+}
+`)
+
+	// Each First<Category>/Last<Category> pair in the header gives a
+	// predicate.
+	for _, c := range e.Consts {
+		category := strings.TrimPrefix(name(c.Name), "First")
+		if category == name(c.Name) {
+			continue
+		}
+		if _, ok := e.lookup(p.Prefix + "Last" + category); !ok {
+			continue
+		}
+		pred, ok := p.Predicates[category]
+		if !ok {
+			pred = predicate{
+				Name: "Is" + category,
+				Doc:  fmt.Sprintf("Determine whether the given kind is in the range First%s to Last%s.", category, category),
+			}
+		}
+		b.WriteString("\n")
+		clangheaders.WriteComment(&b, "", pred.Doc)
+		fmt.Fprintf(&b, "func (%s Kind) %s() bool {\n", p.Receiver, pred.Name)
+		fmt.Fprintf(&b, "\treturn First%s <= %s && %s <= Last%s\n}\n", category, p.Receiver, p.Receiver, category)
+	}
+
+	if p.Unexposed {
+		var unexposed []string
+		for _, c := range e.Consts {
+			if c.Alias == "" && strings.HasPrefix(name(c.Name), "Unexposed") {
+				unexposed = append(unexposed, name(c.Name))
+			}
+		}
+		b.WriteString("\n// Determine whether the given cursor represents a currently unexposed piece of the AST (e.g., CXUnexposedStmt).\n")
+		fmt.Fprintf(&b, "func (%s Kind) IsUnexposed() bool {\n", p.Receiver)
+		fmt.Fprintf(&b, "\tswitch %s {\n\tcase %s:\n\t\treturn true\n\t}\n\treturn false\n}\n", p.Receiver, strings.Join(unexposed, ", "))
+	}
+
+	if p.Source != "" {
+		b.WriteString("\n")
+		b.WriteString(p.Source)
+	}
+
+	return format.Source(b.Bytes())
+}
+
+// genClangEnum returns the source of a clang package file holding a single
+// enum type.
+func genClangEnum(s clangEnum, e *enumDecl) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by go-clang-genenums from clang-c/%s; DO NOT EDIT.\n\n", e.File)
+	b.WriteString("package clang\n\n")
+	for _, inc := range s.Includes {
+		fmt.Fprintf(&b, "// #include %q\n", inc)
+	}
+	b.WriteString("// #include \"go-clang.h\"\nimport \"C\"\n\n")
+	clangheaders.WriteComment(&b, "", clangheaders.DocText(e.Comment))
+	fmt.Fprintf(&b, "type %s %s\n\n", s.Type, s.Base)
+
+	b.WriteString("const (\n")
+	for _, x := range s.Extras {
+		clangheaders.WriteComment(&b, "\t", x.Comment)
+		fmt.Fprintf(&b, "\t%s%s %s = %d\n", s.GoPrefix, x.Name, s.Type, x.Value)
+	}
+	for _, c := range e.Consts {
+		clangheaders.WriteComment(&b, "\t", clangheaders.DocText(c.Comment))
+		fmt.Fprintf(&b, "\t%s%s %s = C.%s\n", s.GoPrefix, strings.TrimPrefix(c.Name, s.CPrefix), s.Type, c.Name)
+	}
+	b.WriteString(")\n")
+
+	return format.Source(b.Bytes())
+}
+
+// runs sorts the values and returns them as inclusive ranges of consecutive
+// values.
+func runs(values []int64) [][2]int64 {
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var r [][2]int64
+	for _, v := range sorted {
+		if n := len(r); n > 0 && r[n-1][1]+1 == v {
+			r[n-1][1] = v
+			continue
+		}
+		r = append(r, [2]int64{v, v})
+	}
+	return r
+}
@@ -0,0 +1,122 @@
+// go-clang-genenums regenerates the Go enum files of the clang package from
+// the libclang headers vendored in clang/clang-c.
+//
+// The headers are parsed with the clang package itself and every enum whose
+// name starts with CX is extracted. From these it writes the kind.go files of
+// the cursorkind, typekind and tokenkind packages, including their Validate
+// tables and category predicates, and the clang package files holding a single
+// enum type. Running stringer afterwards (go generate) refreshes the String
+// methods.
+//
+// $ go-clang-genenums -clang ../../clang
+// or, to only report the files that are out of date
+// $ go-clang-genenums -clang ../../clang -check
+// or, to list the enums found in the headers
+// $ go-clang-genenums -clang ../../clang -list
+//
+// Arguments after the flags are passed on to clang, e.g. include paths needed
+// to find the system headers.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-genenums", flag.ContinueOnError)
+	clangDir := flags.String("clang", "clang", "directory of the clang package, holding clang-c")
+	outDir := flags.String("o", "", "output directory, defaults to the clang package directory")
+	check := flags.Bool("check", false, "report files that differ from the generated ones instead of writing them")
+	list := flags.Bool("list", false, "list the enums found in the headers")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *outDir == "" {
+		*outDir = *clangDir
+	}
+
+	enums, err := extractEnums(*clangDir, flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *list {
+		var names []string
+		for name := range enums {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			e := enums[name]
+			fmt.Printf("%s: %s (%d constants)\n", e.File, e.Name, len(e.Consts))
+		}
+		return 0
+	}
+
+	files := make(map[string][]byte)
+	for _, p := range kindPackages {
+		e, ok := enums[p.Enum]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "enum %s not found\n", p.Enum)
+			return 1
+		}
+		src, err := genKindPackage(p, e)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p.Dir, err)
+			return 1
+		}
+		files[filepath.Join(p.Dir, "kind.go")] = src
+	}
+	for _, s := range clangEnums {
+		e, ok := enums[s.Enum]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "enum %s not found\n", s.Enum)
+			return 1
+		}
+		src, err := genClangEnum(s, e)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", s.File, err)
+			return 1
+		}
+		files[s.File] = src
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := 0
+	for _, name := range names {
+		path := filepath.Join(*outDir, name)
+		if *check {
+			old, err := ioutil.ReadFile(path)
+			if err != nil || !bytes.Equal(old, files[name]) {
+				fmt.Printf("%s is out of date\n", path)
+				r = 1
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := ioutil.WriteFile(path, files[name], 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return r
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoClangGenEnums(t *testing.T) {
+	additional := dropEmpties(strings.Split(*cflags, " "))
+	if r := cmd(append([]string{"-clang", "../../clang", "-list"}, additional...)); r != 0 {
+		t.Fatalf("cmd(-list) = %d", r)
+	}
+
+	dir := t.TempDir()
+	if r := cmd(append([]string{"-clang", "../../clang", "-o", dir}, additional...)); r != 0 {
+		t.Fatalf("cmd = %d", r)
+	}
+
+	for _, tc := range []struct {
+		file  string
+		names []string
+	}{
+		{"cursorkind/kind.go", []string{"UnexposedDecl", "TranslationUnit", "Back", "FirstDecl", "LastExtraDecl", "Validate", "IsDeclaration", "IsExpression", "IsUnexposed"}},
+		{"typekind/kind.go", []string{"Invalid", "Atomic", "FirstBuiltin", "LastBuiltin", "Validate", "IsBuiltin"}},
+		{"tokenkind/kind.go", []string{"Punctuation", "Comment", "Validate"}},
+		{"linkagekind.go", []string{"LinkageKind", "Linkage_External"}},
+		{"reparse.go", []string{"Reparse_Flags", "Reparse_None"}},
+		{"cursor_exceptionspecificationkind.go", []string{"ExceptionSpecification_NonFunction", "ExceptionSpecification_None"}},
+	} {
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, tc.file), nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", tc.file, err)
+			continue
+		}
+		declared := make(map[string]bool)
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				if n.Obj != nil && n.Obj.Decl != nil {
+					declared[n.Name] = true
+				}
+			case *ast.FuncDecl:
+				declared[n.Name.Name] = true
+			}
+			return true
+		})
+		for _, name := range tc.names {
+			if !declared[name] {
+				t.Errorf("%s: %s not declared", tc.file, name)
+			}
+		}
+	}
+
+	// The files just written are up to date.
+	if r := cmd(append([]string{"-clang", "../../clang", "-o", dir, "-check"}, additional...)); r != 0 {
+		t.Errorf("cmd(-check) = %d, the generated files differ from those just written", r)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "linkagekind.go"), []byte("package clang\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if r := cmd(append([]string{"-clang", "../../clang", "-o", dir, "-check"}, additional...)); r != 1 {
+		t.Errorf("cmd(-check) = %d after linkagekind.go changed, want 1", r)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	got := runs([]int64{3, 1, 2, 7, -1, 5, 6})
+	want := [][2]int64{{-1, -1}, {1, 3}, {5, 7}}
+	if len(got) != len(want) {
+		t.Fatalf("runs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("runs = %v, want %v", got, want)
+		}
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
+
+func dropEmpties(ss []string) (r []string) {
+	for _, s := range ss {
+		if s != "" {
+			r = append(r, s)
+		}
+	}
+	return
+}
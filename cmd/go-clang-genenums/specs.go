@@ -0,0 +1,143 @@
+package main
+
+// extraConst is a go-clang specific value that does not come from a header.
+type extraConst struct {
+	Name    string
+	Value   int64
+	Comment string
+}
+
+// predicate names the Is method generated for a First<Category>/Last<Category>
+// pair of enumerators.
+type predicate struct {
+	Name string
+	Doc  string
+}
+
+// kindPackage describes one of the clang/<name>kind packages. These hold a
+// Kind type with plain integer constants, so they can be used without cgo.
+type kindPackage struct {
+	Dir        string // relative to the clang directory
+	Enum       string // C enum name
+	Prefix     string // stripped from the C enumerator names
+	Doc        string // doc comment used when the header has none
+	Receiver   string
+	Extras     []extraConst
+	Predicates map[string]predicate // keyed by category
+	Unexposed  bool                 // generate IsUnexposed from the Unexposed* enumerators
+	Source     string               // hand written methods, appended verbatim
+}
+
+// clangEnum describes an enum type of the clang package itself. These are
+// defined in terms of the C constants.
+type clangEnum struct {
+	Enum     string // C enum name
+	File     string // relative to the clang directory
+	Type     string
+	Base     string // underlying Go type
+	CPrefix  string // stripped from the C enumerator names
+	GoPrefix string // prepended to the Go constant names
+	Includes []string
+	Extras   []extraConst
+}
+
+var kindPackages = []kindPackage{
+	{
+		Dir:      "cursorkind",
+		Enum:     "CXCursorKind",
+		Prefix:   "CXCursor_",
+		Receiver: "ck",
+		Extras: []extraConst{
+			{
+				Name:  "Back",
+				Value: -1,
+				Comment: "A go-clang value, created to represent a cursor that points back to a\n" +
+					"previous cursor that has already been seen in the recursive walk.\n" +
+					"This expressly does not come from the libclang header file.",
+			},
+		},
+		Predicates: map[string]predicate{
+			"Decl":          {"IsNormDeclaration", "Determine whether the given cursor kind represents a normal declaration,\nnot an extra declaration."},
+			"ExtraDecl":     {"IsExtraDeclaration", "Determine whether the given cursor kind represents an extra declaration."},
+			"Ref":           {"IsReference", "Determine whether the given cursor kind represents a simple\nreference.\n\nNote that other kinds of cursors (such as expressions) can also refer to\nother cursors. Use clang_getCursorReferenced() to determine whether a\nparticular cursor refers to another entity."},
+			"Expr":          {"IsExpression", "Determine whether the given cursor kind represents an expression."},
+			"Stmt":          {"IsStatement", "Determine whether the given cursor kind represents a statement."},
+			"Attr":          {"IsAttribute", "Determine whether the given cursor kind represents an attribute."},
+			"Invalid":       {"IsInvalid", "Determine whether the given cursor kind represents an invalid cursor."},
+			"Preprocessing": {"IsPreprocessing", "Determine whether the given cursor represents a preprocessing element,\nsuch as a preprocessor directive or macro instantiation."},
+		},
+		Unexposed: true,
+		Source: `// Determine whether the given cursor kind represents a declaration.
+func (ck Kind) IsDeclaration() bool {
+	return ck.IsNormDeclaration() || ck.IsExtraDeclaration()
+}
+
+// Determine whether the given cursor kind represents a translation unit.
+func (ck Kind) IsTranslationUnit() bool {
+	return ck == TranslationUnit
+}
+
+// IsLiteral returns true for Literal kinds; these return no Spelling string, but getting the token is an option.
+// There are some CursorKinds with the name Literal in them that sound more like expressions so there weren't
+// included here.
+func (ck Kind) IsLiteral() bool {
+	switch ck {
+	case IntegerLiteral,
+		FloatingLiteral,
+		ImaginaryLiteral,
+		StringLiteral,
+		CharacterLiteral,
+		ObjCStringLiteral:
+		return true
+	}
+	return false
+}
+`,
+	},
+	{
+		Dir:      "typekind",
+		Enum:     "CXTypeKind",
+		Prefix:   "CXType_",
+		Doc:      "Describes the kind of type",
+		Receiver: "k",
+		Predicates: map[string]predicate{
+			"Builtin": {"IsBuiltin", "Determine whether the given type kind represents a builtin type."},
+		},
+	},
+	{
+		Dir:      "tokenkind",
+		Enum:     "CXTokenKind",
+		Prefix:   "CXToken_",
+		Doc:      "Describes a kind of token.",
+		Receiver: "k",
+	},
+}
+
+// clangEnums lists the enum types of the clang package that have a file to
+// themselves. The other types named in the stringer list of the clang package
+// share their file with hand written declarations and are kept by hand:
+// AccessSpecifier, ChildVisitResult, CommentInlineCommandRenderKind,
+// CommentParamPassDirection, EvalResultKind, IdxAttrKind,
+// IdxEntityCXXTemplateKind, IdxEntityKind, IdxEntityLanguage,
+// IdxEntityRefKind, IdxObjCContainerKind and TUResourceUsageKind.
+var clangEnums = []clangEnum{
+	{Enum: "CXAvailabilityKind", File: "availabilitykind.go", Type: "AvailabilityKind", Base: "uint32", CPrefix: "CXAvailability_", GoPrefix: "Availability_"},
+	{Enum: "CXCallingConv", File: "callingconv.go", Type: "CallingConv", Base: "uint32", CPrefix: "CXCallingConv_", GoPrefix: "CallingConv_"},
+	{Enum: "CXCommentKind", File: "commentkind.go", Type: "CommentKind", Base: "uint32", CPrefix: "CXComment_", GoPrefix: "Comment_", Includes: []string{"./clang-c/Documentation.h"}},
+	{Enum: "CXCompletionChunkKind", File: "completionchunkkind.go", Type: "CompletionChunkKind", Base: "uint32", CPrefix: "CXCompletionChunk_", GoPrefix: "CompletionChunk_"},
+	{Enum: "CXCursor_ExceptionSpecificationKind", File: "cursor_exceptionspecificationkind.go", Type: "ExceptionSpecification", Base: "int32", CPrefix: "CXCursor_ExceptionSpecificationKind_", GoPrefix: "ExceptionSpecification_",
+		Extras: []extraConst{{Name: "NonFunction", Value: -1, Comment: "A non-function type (manually added)."}}},
+	{Enum: "CXDiagnosticSeverity", File: "diagnosticseverity.go", Type: "DiagnosticSeverity", Base: "uint32", CPrefix: "CXDiagnostic_", GoPrefix: "Diagnostic_"},
+	{Enum: "CXIndexOptFlags", File: "indexoptflags.go", Type: "IndexOptFlags", Base: "uint32", CPrefix: "CXIndexOpt_", GoPrefix: "IndexOpt_"},
+	{Enum: "CXLanguageKind", File: "languagekind.go", Type: "LanguageKind", Base: "uint32", CPrefix: "CXLanguage_", GoPrefix: "Language_"},
+	{Enum: "CXLinkageKind", File: "linkagekind.go", Type: "LinkageKind", Base: "uint32", CPrefix: "CXLinkage_", GoPrefix: "Linkage_"},
+	{Enum: "CXNameRefFlags", File: "namerefflags.go", Type: "NameRefFlags", Base: "uint32", CPrefix: "CXNameRange_", GoPrefix: "NameRange_"},
+	{Enum: "CXRefQualifierKind", File: "refqualifierkind.go", Type: "RefQualifierKind", Base: "uint32", CPrefix: "CXRefQualifier_", GoPrefix: "RefQualifier_"},
+	{Enum: "CXReparse_Flags", File: "reparse.go", Type: "Reparse_Flags", Base: "uint32", CPrefix: "CXReparse_", GoPrefix: "Reparse_"},
+	{Enum: "CXResult", File: "result.go", Type: "Result", Base: "uint32", CPrefix: "CXResult_", GoPrefix: "Result_"},
+	{Enum: "CXSaveTranslationUnit_Flags", File: "savetranslationunit.go", Type: "SaveTranslationUnit_Flags", Base: "uint32", CPrefix: "CXSaveTranslationUnit_", GoPrefix: "SaveTranslationUnit_"},
+	{Enum: "CX_StorageClass", File: "storageclass.go", Type: "StorageClass", Base: "uint32", CPrefix: "CX_SC_", GoPrefix: "SC_"},
+	{Enum: "CXTemplateArgumentKind", File: "templateargumentkind.go", Type: "TemplateArgumentKind", Base: "uint32", CPrefix: "CXTemplateArgumentKind_", GoPrefix: "TemplateArgumentKind_"},
+	{Enum: "CXVisibilityKind", File: "visibilitykind.go", Type: "VisibilityKind", Base: "uint32", CPrefix: "CXVisibility_", GoPrefix: "Visibility_"},
+	{Enum: "CXVisitorResult", File: "visitorresult.go", Type: "VisitorResult", Base: "uint32", CPrefix: "CXVisit_", GoPrefix: "Visit_"},
+}
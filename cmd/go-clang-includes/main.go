@@ -1,22 +1,30 @@
-// go-clang-includes lists the include files.
+// go-clang-includes shows the include graph of a source file: who includes
+// whom, at which line and through which search path, with the depth and
+// fan-in of every header, which ones are system headers and the include
+// cycles.
 //
 // This shows two things: how to find the included files and perhaps more
 // importantly, how the source filename does not have to be explicitly passed
 // to the ParseTranslationUnit method. Libclang finds the source filename from
 // the arguments, just as clang would.
 //
-// So this command takes no flags. All the arguments are passed to
-// libclang.
+// So only a leading -format flag is for this command, optionally followed by
+// --. All the other arguments are passed to libclang. Problems are written to
+// the standard error, to keep the graph apart.
 //
 // $ go-clang-includes -c -I/include/dir -DDEBUG ../../testdata/hello.c
+// or, for Graphviz
+// $ go-clang-includes -format dot -- -c ../../testdata/hello.c > hello.dot
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/frankreh/go-clang/clang"
-	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clangincludes"
 )
 
 func main() {
@@ -24,6 +32,17 @@ func main() {
 }
 
 func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-includes", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format, text, dot or json")
+	own, args := split(flags, args)
+	if err := flags.Parse(own); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "dot" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "**error: unknown format %q\n", *format)
+		return 2
+	}
+
 	idx := clang.NewIndex(0, 1)
 	defer idx.Dispose()
 
@@ -31,33 +50,61 @@ func cmd(args []string) int {
 	tu := idx.ParseTranslationUnit("", args, nil, clang.TranslationUnit_DetailedPreprocessingRecord)
 	defer tu.Dispose()
 
-	// The tu.Spelling() shows the source filename that was extracted from the args.
-	//fmt.Printf("tu: %s\n", tu.Spelling())
-
 	diagnostics := tu.Diagnostics()
 	for _, d := range diagnostics {
-		fmt.Println("PROBLEM:", d.Spelling())
+		fmt.Fprintln(os.Stderr, "PROBLEM:", d.Spelling())
 	}
 
-	cursor := tu.TranslationUnitCursor()
-	if cursor.IsNull() {
-		fmt.Println("PROBLEM: TranslationUnitCursor creation failed")
+	if tu.TranslationUnitCursor().IsNull() {
+		fmt.Fprintln(os.Stderr, "PROBLEM: TranslationUnitCursor creation failed")
 		return 1
 	}
+	g := clangincludes.Explore(tu)
 
-	cursor.Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
-		switch cursor.Kind() {
-		case cursorkind.InclusionDirective:
-			file := cursor.IncludedFile()
-			//uniqueID, _ := file.UniqueID()
-			//fmt.Printf("%s: %s\n", cursor.Kind().Spelling(), cursor.Spelling())
-			//fmt.Printf("  name: %s, time: %v, uniqueID: %d\n", file.Name(), file.Time(), uniqueID)
-			fmt.Printf("%s\n", file.Name())
-		}
-
-		// No need to recurse. The inclusion directives will be found at the top level.
-		return clang.ChildVisit_Continue
-	})
-
+	var err error
+	switch *format {
+	case "dot":
+		err = g.WriteDOT(os.Stdout)
+	case "json":
+		err = g.WriteJSON(os.Stdout)
+	default:
+		err = g.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "**error: %v\n", err)
+		return 1
+	}
 	return 0
 }
+
+// split returns the leading arguments that are flags of the set, with their
+// values, and the arguments left for libclang. A -- ends the flags of the set
+// and is dropped.
+func split(flags *flag.FlagSet, args []string) ([]string, []string) {
+	i := 0
+	for i < len(args) {
+		if args[i] == "--" {
+			return args[:i], args[i+1:]
+		}
+		name := strings.TrimLeft(args[i], "-")
+		if name == args[i] {
+			break
+		}
+		hasValue := strings.Contains(name, "=")
+		if hasValue {
+			name = name[:strings.Index(name, "=")]
+		}
+		if flags.Lookup(name) == nil {
+			break
+		}
+		i++
+		if !hasValue {
+			// Every flag of the set takes a value.
+			i++
+		}
+	}
+	if i > len(args) {
+		i = len(args)
+	}
+	return args[:i], args[i:]
+}
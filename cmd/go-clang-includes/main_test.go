@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -17,15 +18,42 @@ func dropEmpties(ss []string) (r []string) {
 	return
 }
 
-func TestGoClangDump(t *testing.T) {
+func TestGoClangIncludes(t *testing.T) {
 	additional := dropEmpties(strings.Split(*cflags, " "))
-	for _, args := range [][]string{
-		[]string{"-c", "../../testdata/hello.c"},
+	for _, tc := range []struct {
+		flags []string
+		args  []string
+		want  int
+	}{
+		{nil, []string{"-c", "../../testdata/hello.c"}, 0},
+		{[]string{"-format", "dot"}, []string{"-c", "../../testdata/hello.c"}, 0},
+		{[]string{"-format=json", "--"}, []string{"-I../../testdata/includes/inc", "../../testdata/includes/main.c"}, 0},
+		{[]string{"-format", "svg"}, []string{"-c", "../../testdata/hello.c"}, 2},
 	} {
-		args = append(additional, args...)
+		args := append(append(tc.flags, additional...), tc.args...)
 		r := cmd(args)
-		if r != 0 {
-			t.Errorf("cmd(%v) = %d", args, r)
+		if r != tc.want {
+			t.Errorf("cmd(%v) = %d, want %d", args, r, tc.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for _, tc := range []struct {
+		args      []string
+		own, rest []string
+	}{
+		{[]string{"-c", "a.c"}, []string{}, []string{"-c", "a.c"}},
+		{[]string{"-format", "dot", "-c", "a.c"}, []string{"-format", "dot"}, []string{"-c", "a.c"}},
+		{[]string{"--format=dot", "a.c"}, []string{"--format=dot"}, []string{"a.c"}},
+		{[]string{"-format", "dot", "--", "-format", "a.c"}, []string{"-format", "dot"}, []string{"-format", "a.c"}},
+		{[]string{"-format"}, []string{"-format"}, []string{}},
+	} {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.String("format", "", "")
+		own, rest := split(flags, tc.args)
+		if !reflect.DeepEqual(own, tc.own) || !reflect.DeepEqual(rest, tc.rest) {
+			t.Errorf("split(%q) = %q, %q, want %q, %q", tc.args, own, rest, tc.own, tc.rest)
 		}
 	}
 }
@@ -0,0 +1,129 @@
+// go-clang-index builds a symbol index of every entry of a compilation
+// database, keyed by USR, and answers queries on it without parsing again.
+//
+// The index records every declaration, definition and reference, with its
+// location and the function containing it. It is written to the file given
+// with -o and read back with -i.
+//
+// $ go-clang-index -o project.index /path/to/build
+// then, to find where main is defined
+// $ go-clang-index -i project.index -def main
+// or who references foo
+// $ go-clang-index -i project.index -refs foo
+// or all the symbols whose name starts with str
+// $ go-clang-index -i project.index -match '^str'
+//
+// Symbols are given by name or by USR.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangindex"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-index", flag.ContinueOnError)
+	output := flags.String("o", "", "write the index built to this file")
+	input := flags.String("i", "", "read the index from this file instead of building it")
+	def := flags.String("def", "", "print where the symbol with this name or USR is defined")
+	refs := flags.String("refs", "", "print where the symbol with this name or USR is referenced")
+	match := flags.String("match", "", "print the symbols whose name matches this regular expression")
+	system := flags.Bool("system", false, "also index the occurrences in system headers")
+	cflags := flags.String("cflags", "", "space separated flags to pass to clang")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var x *clangindex.Index
+	switch {
+	case *input != "" && flags.NArg() == 0:
+		var err error
+		x, err = clangindex.ReadFile(*input)
+		if err != nil {
+			fmt.Printf("**error: %v\n", err)
+			return 1
+		}
+	case *input == "" && flags.NArg() == 1:
+		db, err := clang.FromDirectory(flags.Arg(0))
+		if err != nil {
+			fmt.Printf("**error: could not open compilation database at [%s]: %v\n", flags.Arg(0), err)
+			return 1
+		}
+		var problems []string
+		x, problems = clangindex.Build(db.AllCompileCommands(), clangindex.Options{
+			Args:   strings.Fields(*cflags),
+			System: *system,
+		})
+		db.Dispose()
+		for _, p := range problems {
+			fmt.Println("PROBLEM:", p)
+		}
+	default:
+		fmt.Printf("**error: you need to give either an index with -i or a directory containing a 'compile_commands.json' file\n")
+		return 1
+	}
+
+	if *output != "" {
+		if err := x.WriteFile(*output); err != nil {
+			fmt.Printf("**error: %v\n", err)
+			return 1
+		}
+	}
+
+	status := 0
+	if *def != "" {
+		status |= printOccurrences(x, *def, (*clangindex.Symbol).Definitions)
+	}
+	if *refs != "" {
+		status |= printOccurrences(x, *refs, (*clangindex.Symbol).References)
+	}
+	if *match != "" {
+		re, err := regexp.Compile(*match)
+		if err != nil {
+			fmt.Printf("**error: %v\n", err)
+			return 1
+		}
+		for _, s := range x.Match(re) {
+			fmt.Printf("%s %s %s %s (%d occurrences)\n", s.Name, s.Kind, s.Linkage, s.USR, len(s.Occurrences))
+		}
+	}
+	if *def == "" && *refs == "" && *match == "" {
+		occurrences := 0
+		for _, s := range x.Symbols {
+			occurrences += len(s.Occurrences)
+		}
+		fmt.Printf("%d files, %d symbols, %d occurrences\n", len(x.Files), len(x.Symbols), occurrences)
+	}
+	return status
+}
+
+// printOccurrences prints the occurrences of the symbols named by
+// usrOrName, as selected by occurrences, and returns 1 when there is none.
+func printOccurrences(x *clangindex.Index, usrOrName string, occurrences func(*clangindex.Symbol) []clangindex.Occurrence) int {
+	found := false
+	for _, s := range x.Lookup(usrOrName) {
+		for _, o := range occurrences(s) {
+			found = true
+			fmt.Printf("%s:%d:%d: %s of %s %s", o.File, o.Line, o.Column, o.Role, s.Kind, s.Name)
+			if c, ok := x.Symbols[o.Container]; ok {
+				fmt.Printf(" in %s", c.Name)
+			}
+			fmt.Println()
+		}
+	}
+	if !found {
+		fmt.Printf("no occurrence of %s\n", usrOrName)
+		return 1
+	}
+	return 0
+}
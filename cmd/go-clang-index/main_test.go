@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoClangIndex(t *testing.T) {
+	testdata, err := filepath.Abs("../../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]string
+	for _, file := range []string{"hello.c", "globals.c", "struct.c"} {
+		entries = append(entries, map[string]string{
+			"directory": testdata,
+			"command":   "cc -c -o " + file + ".o " + file,
+			"file":      file,
+		})
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(dir, "project.index")
+
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-cflags", *cflags, "-o", index, dir}, 0},
+		{[]string{"-i", index, "-def", "main"}, 0},
+		{[]string{"-i", index, "-refs", "foo", "-def", "Foo"}, 0},
+		{[]string{"-i", index, "-match", "^ba"}, 0},
+		{[]string{"-i", index, "-refs", "nothing"}, 1},
+		{[]string{"-i", index, dir}, 1},
+	} {
+		if r := cmd(tc.args); r != tc.want {
+			t.Errorf("cmd(%v) = %d, want %d", tc.args, r, tc.want)
+		}
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
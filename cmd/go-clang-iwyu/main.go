@@ -0,0 +1,165 @@
+// go-clang-iwyu finds the includes of the sources of a compilation database
+// that contribute nothing, and the declarations and macros the sources use
+// from headers they only include through other headers. It is a light
+// version of include-what-you-use.
+//
+// Fix-its removing the unused includes and adding the missing ones are
+// printed, or applied to the sources with -w. Uses of system headers reached
+// through other headers are not reported, as such headers are often internal
+// to the one meant to be included.
+//
+// $ go-clang-iwyu /path/to/build
+// or, for some of the sources only, fixing them
+// $ go-clang-iwyu -w /path/to/build main.c util.c
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangincludes"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-iwyu", flag.ContinueOnError)
+	write := flags.Bool("w", false, "apply the fix-its to the sources")
+	asJSON := flags.Bool("json", false, "write the reports as JSON")
+	cflags := flags.String("cflags", "", "space separated flags to pass to clang")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 {
+		fmt.Printf("**error: you need to give a directory containing a 'compile_commands.json' file\n")
+		return 1
+	}
+
+	db, err := clang.FromDirectory(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("**error: could not open compilation database at [%s]: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	defer db.Dispose()
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	var reports []*clangincludes.Report
+	seen := make(map[string]bool)
+	for _, c := range db.AllCompileCommands() {
+		source, cmdArgs := c.ParseArgs()
+		if !selected(source, flags.Args()[1:]) {
+			continue
+		}
+		tu := idx.ParseTranslationUnit(source, append(cmdArgs, strings.Fields(*cflags)...), nil,
+			clang.TranslationUnit_DetailedPreprocessingRecord)
+		if !tu.IsValid() {
+			fmt.Println("PROBLEM: parsing", source, "failed")
+			continue
+		}
+		for _, d := range tu.Diagnostics() {
+			if d.Severity() >= clang.Diagnostic_Error {
+				fmt.Println("PROBLEM:", d.Spelling())
+			}
+		}
+		r := clangincludes.Analyze(tu)
+		tu.Dispose()
+		// A source the database lists more than once is reported once, so
+		// its fix-its are not applied again to lines they have moved.
+		if seen[r.Main] {
+			continue
+		}
+		seen[r.Main] = true
+		reports = append(reports, r)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(reports); err != nil {
+			fmt.Printf("**error: %v\n", err)
+			return 1
+		}
+	} else {
+		for _, r := range reports {
+			printReport(r, *write)
+		}
+	}
+
+	if *write {
+		for _, r := range reports {
+			if len(r.FixIts) == 0 {
+				continue
+			}
+			if err := fix(r); err != nil {
+				fmt.Printf("**error: %v\n", err)
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+// selected reports whether source is one of the files, or any source when
+// there are none. A file matches the end of the source name.
+func selected(source string, files []string) bool {
+	if len(files) == 0 {
+		return true
+	}
+	for _, f := range files {
+		f = filepath.Clean(f)
+		if source == f || strings.HasSuffix(source, string(filepath.Separator)+f) {
+			return true
+		}
+	}
+	return false
+}
+
+func printReport(r *clangincludes.Report, write bool) {
+	for _, u := range r.Includes {
+		if u.Unused() {
+			fmt.Printf("%s:%d: unused #include %s\n", r.Main, u.Include.Line, written(u.Include))
+		}
+	}
+	for _, u := range r.Indirect {
+		if u.System {
+			continue
+		}
+		fmt.Printf("%s:%d: %s is declared in %s, only included through other headers\n",
+			r.Main, u.Line, u.Name, u.Header)
+	}
+	if write {
+		return
+	}
+	for _, f := range r.FixIts {
+		fmt.Printf("fix: %v\n", f)
+	}
+}
+
+func written(in clangincludes.Include) string {
+	if in.Angled {
+		return "<" + in.Written + ">"
+	}
+	return `"` + in.Written + `"`
+}
+
+// fix applies the fix-its of the report to its main file.
+func fix(r *clangincludes.Report) error {
+	contents, err := ioutil.ReadFile(r.Main)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(r.Main)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.Main, clangincludes.Apply(contents, r.FixIts), fi.Mode())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoClangIwyu(t *testing.T) {
+	// Work on a copy of the sources, as -w rewrites them.
+	dir := t.TempDir()
+	for _, file := range []string{"unused.c", "local.h", "inc/a.h", "inc/b.h", "inc/c.h"} {
+		b, err := ioutil.ReadFile(filepath.Join("../../testdata/includes", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The source is listed twice, as a database may, yet fixed once.
+	command := map[string]string{
+		"directory": dir,
+		"command":   "cc -Iinc -c -o unused.o unused.c",
+		"file":      "unused.c",
+	}
+	b, err := json.Marshal([]map[string]string{command, command})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-cflags", *cflags, dir}, 0},
+		{[]string{"-cflags", *cflags, "-json", dir, "unused.c"}, 0},
+		{[]string{"-cflags", *cflags, "-w", dir, "unused.c"}, 0},
+		{[]string{}, 1},
+	} {
+		if r := cmd(tc.args); r != tc.want {
+			t.Errorf("cmd(%v) = %d, want %d", tc.args, r, tc.want)
+		}
+	}
+
+	fixed, err := ioutil.ReadFile(filepath.Join(dir, "unused.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(fixed), "<c.h>") || !strings.Contains(string(fixed), `#include "b.h"`) ||
+		!strings.Contains(string(fixed), `#include "local.h"`) {
+		t.Errorf("unused.c not fixed:\n%s", fixed)
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
@@ -0,0 +1,47 @@
+// go-clang-lsp is a Language Server Protocol server for C and C++, speaking
+// JSON-RPC over stdin and stdout.
+//
+// Open documents are parsed with the arguments of their entry in the
+// compilation database and kept up to date as they are edited. The server
+// publishes diagnostics and answers hover, definition, references, completion
+// and document symbol requests. References are searched for in the open
+// documents.
+//
+// The compilation database is read from the directory given with -compdb, or
+// else from the root of the workspace when the client opens one.
+//
+// $ go-clang-lsp
+// or
+// $ go-clang-lsp -compdb /path/to/build -cflags "-I/usr/lib/clang/include"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-lsp", flag.ContinueOnError)
+	compdb := flags.String("compdb", "", "directory containing a 'compile_commands.json' file")
+	cflags := flags.String("cflags", "", "space separated flags to pass to clang")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var commands map[string][]string
+	if *compdb != "" {
+		var err error
+		commands, err = loadCommands(*compdb)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "**error: could not open compilation database at [%s]: %v\n", *compdb, err)
+			return 1
+		}
+	}
+	return serve(os.Stdin, os.Stdout, commands, strings.Fields(*cflags))
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frankreh/go-clang/clangsession"
+)
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
+
+const source = `struct point {
+	int x;
+	int y;
+};
+
+#define ZERO 0
+
+/// Adds the coordinates of p.
+int sum(struct point p)
+{
+	return p.x + p.y;
+}
+
+int main(void)
+{
+	struct point p = { ZERO, VALUE };
+	return sum(p);
+}
+`
+
+// client drives the server the way an editor would.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	id     int
+	notes  chan *message
+	result chan *message
+}
+
+func newClient(t *testing.T, r io.Reader, w io.Writer) *client {
+	c := &client{
+		t:      t,
+		conn:   newConn(r, w),
+		notes:  make(chan *message, 100),
+		result: make(chan *message),
+	}
+	go func() {
+		for {
+			m, err := c.conn.read()
+			if err != nil {
+				close(c.notes)
+				return
+			}
+			if m.ID != nil {
+				c.result <- m
+			} else {
+				c.notes <- m
+			}
+		}
+	}()
+	return c
+}
+
+// call sends a request and decodes its result into result.
+func (c *client) call(method string, params, result interface{}) {
+	c.t.Helper()
+	c.id++
+	b, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	id, _ := json.Marshal(c.id)
+	if err := c.conn.write(&message{ID: id, Method: method, Params: b}); err != nil {
+		c.t.Fatal(err)
+	}
+	m := <-c.result
+	if string(m.ID) != string(id) {
+		c.t.Fatalf("%s: reply to %s, want %s", method, m.ID, id)
+	}
+	if m.Error != nil {
+		c.t.Fatalf("%s: %v", method, m.Error)
+	}
+	if result != nil {
+		if err := json.Unmarshal(m.Result, result); err != nil {
+			c.t.Fatalf("%s: %v", method, err)
+		}
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics waits for the next diagnostics published.
+func (c *client) diagnostics() []diagnostic {
+	c.t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case m, ok := <-c.notes:
+			if !ok {
+				c.t.Fatal("connection closed")
+			}
+			if m.Method != "textDocument/publishDiagnostics" {
+				continue
+			}
+			var p publishDiagnosticsParams
+			if err := json.Unmarshal(m.Params, &p); err != nil {
+				c.t.Fatal(err)
+			}
+			return p.Diagnostics
+		case <-timeout:
+			c.t.Fatal("no diagnostics published")
+		}
+	}
+}
+
+// at returns the position of the first occurrence of s in text, plus delta
+// characters.
+func at(text, s string, delta int) clangsession.Position {
+	i := strings.Index(text, s)
+	line := strings.Count(text[:i], "\n")
+	return clangsession.Position{Line: line, Character: i - strings.LastIndex(text[:i], "\n") - 1 + delta}
+}
+
+func TestGoClangLsp(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "a.c")
+	if err := ioutil.WriteFile(filename, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal([]map[string]string{{
+		"directory": dir,
+		"command":   "cc -c -DVALUE=2 -o a.o a.c",
+		"file":      "a.c",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	exit := make(chan int)
+	go func() {
+		exit <- serve(inR, outW, nil, strings.Fields(*cflags))
+		outW.Close()
+	}()
+	c := newClient(t, outR, inW)
+
+	var init initializeResult
+	c.call("initialize", initializeParams{RootURI: pathURI(dir)}, &init)
+	if !init.Capabilities.HoverProvider || init.Capabilities.TextDocumentSync != syncIncremental {
+		t.Errorf("capabilities %+v", init.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	uri := pathURI(filename)
+	doc := textDocumentIdentifier{URI: uri}
+	c.notify("textDocument/didOpen", didOpenTextDocumentParams{
+		TextDocument: textDocumentItem{URI: uri, LanguageID: "c", Version: 1, Text: source},
+	})
+	// VALUE is defined by the compilation database.
+	for _, d := range c.diagnostics() {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+
+	var h hover
+	c.call("textDocument/hover", textDocumentPositionParams{doc, at(source, "sum(p)", 0)}, &h)
+	if !strings.Contains(h.Contents.Value, "int sum(struct point)") || !strings.Contains(h.Contents.Value, "Adds the coordinates") {
+		t.Errorf("hover %q", h.Contents.Value)
+	}
+
+	c.call("textDocument/hover", textDocumentPositionParams{doc, at(source, "ZERO,", 0)}, &h)
+	if h.Contents.Value != "#define ZERO" {
+		t.Errorf("hover %q, want #define ZERO", h.Contents.Value)
+	}
+
+	var locations []location
+	c.call("textDocument/definition", textDocumentPositionParams{doc, at(source, "sum(p)", 1)}, &locations)
+	want := clangsession.Range{Start: at(source, "sum(struct", 0), End: at(source, "sum(struct", 3)}
+	if len(locations) != 1 || locations[0].URI != uri || locations[0].Range != want {
+		t.Errorf("definition %+v, want %v", locations, want)
+	}
+
+	var p referenceParams
+	p.TextDocument = doc
+	p.Position = at(source, "point p)", 2)
+	p.Context.IncludeDeclaration = true
+	c.call("textDocument/references", p, &locations)
+	if len(locations) != 3 {
+		t.Errorf("%d references to struct point, want 3: %+v", len(locations), locations)
+	}
+
+	var symbols []documentSymbol
+	c.call("textDocument/documentSymbol", documentSymbolParams{doc}, &symbols)
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+		for _, child := range s.Children {
+			names = append(names, s.Name+"."+child.Name)
+		}
+	}
+	if got := strings.Join(names, " "); got != "point point.x point.y ZERO sum main" {
+		t.Errorf("symbols %s", got)
+	}
+
+	// Start a member access, which leaves the code incomplete.
+	edit := "\tp.\n"
+	pos := at(source, "\treturn sum", 0)
+	c.notify("textDocument/didChange", didChangeTextDocumentParams{
+		TextDocument: doc,
+		ContentChanges: []textDocumentContentChangeEvent{
+			{Range: &clangsession.Range{Start: pos, End: pos}, Text: edit},
+		},
+	})
+	var list completionList
+	c.call("textDocument/completion", textDocumentPositionParams{doc, clangsession.Position{Line: pos.Line, Character: 3}}, &list)
+	var labels []string
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	if got := strings.Join(labels, " "); got != "x y" {
+		t.Errorf("completions %q, want x y", got)
+	}
+	if diagnostics := c.diagnostics(); len(diagnostics) == 0 {
+		t.Error("no diagnostic for the incomplete member access")
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if code := <-exit; code != 0 {
+		t.Errorf("exit code %d", code)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import "github.com/frankreh/go-clang/clangsession"
+
+// The subset of the Language Server Protocol the server implements. Positions
+// and ranges are those of the clangsession package, which follow the
+// protocol.
+
+type initializeParams struct {
+	RootURI  string `json:"rootUri"`
+	RootPath string `json:"rootPath"`
+}
+
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities"`
+	ServerInfo   serverInfo         `json:"serverInfo"`
+}
+
+type serverInfo struct {
+	Name string `json:"name"`
+}
+
+type serverCapabilities struct {
+	TextDocumentSync       int               `json:"textDocumentSync"`
+	HoverProvider          bool              `json:"hoverProvider"`
+	DefinitionProvider     bool              `json:"definitionProvider"`
+	ReferencesProvider     bool              `json:"referencesProvider"`
+	CompletionProvider     completionOptions `json:"completionProvider"`
+	DocumentSymbolProvider bool              `json:"documentSymbolProvider"`
+}
+
+type completionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters"`
+}
+
+// Text document synchronization kinds.
+const (
+	syncIncremental = 2
+)
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type textDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type didOpenTextDocumentParams struct {
+	TextDocument textDocumentItem `json:"textDocument"`
+}
+
+type didChangeTextDocumentParams struct {
+	TextDocument   textDocumentIdentifier           `json:"textDocument"`
+	ContentChanges []textDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+type textDocumentContentChangeEvent struct {
+	Range *clangsession.Range `json:"range"`
+	Text  string              `json:"text"`
+}
+
+type didCloseTextDocumentParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     clangsession.Position  `json:"position"`
+}
+
+type referenceParams struct {
+	textDocumentPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type documentSymbolParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type location struct {
+	URI   string             `json:"uri"`
+	Range clangsession.Range `json:"range"`
+}
+
+// Diagnostic severities.
+const (
+	severityError       = 1
+	severityWarning     = 2
+	severityInformation = 3
+)
+
+type diagnostic struct {
+	Range    clangsession.Range `json:"range"`
+	Severity int                `json:"severity"`
+	Code     string             `json:"code,omitempty"`
+	Source   string             `json:"source"`
+	Message  string             `json:"message"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []diagnostic `json:"diagnostics"`
+}
+
+type markupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type hover struct {
+	Contents markupContent       `json:"contents"`
+	Range    *clangsession.Range `json:"range,omitempty"`
+}
+
+type completionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []completionItem `json:"items"`
+}
+
+type completionItem struct {
+	Label         string         `json:"label"`
+	Kind          int            `json:"kind,omitempty"`
+	Detail        string         `json:"detail,omitempty"`
+	Documentation *markupContent `json:"documentation,omitempty"`
+	InsertText    string         `json:"insertText,omitempty"`
+	SortText      string         `json:"sortText,omitempty"`
+	Deprecated    bool           `json:"deprecated,omitempty"`
+}
+
+// Completion item kinds.
+const (
+	completionText        = 1
+	completionMethod      = 2
+	completionFunction    = 3
+	completionConstructor = 4
+	completionField       = 5
+	completionVariable    = 6
+	completionClass       = 7
+	completionModule      = 9
+	completionEnum        = 13
+	completionKeyword     = 14
+	completionEnumMember  = 20
+	completionStruct      = 22
+	completionTypeParam   = 25
+)
+
+type documentSymbol struct {
+	Name           string             `json:"name"`
+	Detail         string             `json:"detail,omitempty"`
+	Kind           int                `json:"kind"`
+	Range          clangsession.Range `json:"range"`
+	SelectionRange clangsession.Range `json:"selectionRange"`
+	Children       []documentSymbol   `json:"children,omitempty"`
+}
+
+// Symbol kinds.
+const (
+	symbolNamespace   = 3
+	symbolClass       = 5
+	symbolMethod      = 6
+	symbolField       = 8
+	symbolConstructor = 9
+	symbolEnum        = 10
+	symbolFunction    = 12
+	symbolVariable    = 13
+	symbolConstant    = 14
+	symbolEnumMember  = 22
+	symbolStruct      = 23
+)
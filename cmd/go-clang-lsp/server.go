@@ -0,0 +1,625 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clangsession"
+)
+
+// maxCompletions is the number of completion items returned at most.
+const maxCompletions = 200
+
+type server struct {
+	conn     *conn
+	args     []string            // passed to clang for every file
+	commands map[string][]string // clang arguments from the compilation database, keyed by source file
+	sessions map[string]*clangsession.Session
+	shutdown bool
+}
+
+// serve answers the requests read from in until the exit notification or the
+// end of in, and returns the exit code.
+func serve(in io.Reader, out io.Writer, commands map[string][]string, args []string) int {
+	s := &server{
+		conn:     newConn(in, out),
+		args:     args,
+		commands: commands,
+		sessions: make(map[string]*clangsession.Session),
+	}
+	defer func() {
+		for _, ss := range s.sessions {
+			ss.Close()
+		}
+	}()
+
+	for {
+		m, err := s.conn.read()
+		if err != nil {
+			if re, ok := err.(*responseError); ok {
+				s.conn.reply(json.RawMessage("null"), nil, re)
+				continue
+			}
+			return 1
+		}
+		if m.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, err := s.handle(m.Method, m.Params, m.ID != nil)
+		if m.ID != nil {
+			s.conn.reply(m.ID, result, err)
+		} else if err != nil {
+			s.logMessage(err)
+		}
+	}
+}
+
+// handle dispatches a request or, when isRequest is false, a notification.
+func (s *server) handle(method string, params json.RawMessage, isRequest bool) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var p initializeParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.initialize(&p), nil
+	case "initialized", "textDocument/didSave", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenTextDocumentParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.didOpen(&p)
+	case "textDocument/didChange":
+		var p didChangeTextDocumentParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.didChange(&p)
+	case "textDocument/didClose":
+		var p didCloseTextDocumentParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.didClose(&p)
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(&p)
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(&p)
+	case "textDocument/references":
+		var p referenceParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.references(&p)
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.completion(&p)
+	case "textDocument/documentSymbol":
+		var p documentSymbolParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.documentSymbol(&p)
+	}
+	if isRequest {
+		return nil, &responseError{codeMethodNotFound, "method not supported: " + method}
+	}
+	return nil, nil
+}
+
+func unmarshal(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+// logMessage reports an error the client cannot be replied to with.
+func (s *server) logMessage(err error) {
+	s.conn.notify("window/logMessage", map[string]interface{}{
+		"type":    1,
+		"message": err.Error(),
+	})
+}
+
+func (s *server) initialize(p *initializeParams) *initializeResult {
+	// Without a compilation database given on the command line, look for one
+	// at the root of the workspace.
+	if s.commands == nil {
+		root := p.RootPath
+		if p.RootURI != "" {
+			root = uriPath(p.RootURI)
+		}
+		if root != "" {
+			s.commands, _ = loadCommands(root)
+		}
+	}
+
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:       syncIncremental,
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			CompletionProvider:     completionOptions{TriggerCharacters: []string{".", ">", ":"}},
+			DocumentSymbolProvider: true,
+		},
+		ServerInfo: serverInfo{Name: "go-clang-lsp"},
+	}
+}
+
+// loadCommands returns the clang arguments of every source file of the
+// compilation database in dir.
+func loadCommands(dir string) (map[string][]string, error) {
+	db, err := clang.FromDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Dispose()
+
+	commands := make(map[string][]string)
+	for _, cmd := range db.AllCompileCommands() {
+		source, args := cmd.ParseArgs()
+		commands[filepath.Clean(source)] = args
+	}
+	return commands, nil
+}
+
+func (s *server) didOpen(p *didOpenTextDocumentParams) error {
+	uri := p.TextDocument.URI
+	path := uriPath(uri)
+	args, ok := s.commands[path]
+	if !ok && (p.TextDocument.LanguageID == "cpp" || p.TextDocument.LanguageID == "objective-cpp") {
+		args = []string{"-x", "c++"}
+	}
+	args = append(args[:len(args):len(args)], s.args...)
+
+	if old, ok := s.sessions[uri]; ok {
+		old.Close()
+		delete(s.sessions, uri)
+	}
+	// The detailed preprocessing record gives the macro definitions.
+	options := clang.DefaultEditingTranslationUnitOptions() | clang.TranslationUnit_DetailedPreprocessingRecord
+	ss, err := clangsession.New(path, args, p.TextDocument.Text, options)
+	if err != nil {
+		return err
+	}
+	s.sessions[uri] = ss
+	ss.OnParse = func(ss *clangsession.Session) { s.publishDiagnostics(uri, ss) }
+	s.publishDiagnostics(uri, ss)
+	return nil
+}
+
+func (s *server) didChange(p *didChangeTextDocumentParams) error {
+	ss, err := s.session(p.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	var edits []clangsession.Edit
+	for _, c := range p.ContentChanges {
+		edits = append(edits, clangsession.Edit{Range: c.Range, Text: c.Text})
+	}
+	return ss.Apply(edits...)
+}
+
+func (s *server) didClose(p *didCloseTextDocumentParams) error {
+	uri := p.TextDocument.URI
+	ss, err := s.session(uri)
+	if err != nil {
+		return err
+	}
+	ss.Close()
+	delete(s.sessions, uri)
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: []diagnostic{}})
+}
+
+// publishDiagnostics sends the diagnostics of the last parse of the session
+// located in its file.
+func (s *server) publishDiagnostics(uri string, ss *clangsession.Session) {
+	diagnostics := []diagnostic{}
+	if err := ss.Err(); err != nil {
+		diagnostics = append(diagnostics, diagnostic{
+			Severity: severityError,
+			Source:   "clang",
+			Message:  err.Error(),
+		})
+	}
+	for _, d := range ss.Diagnostics() {
+		if d.File != "" && filepath.Clean(d.File) != filepath.Clean(ss.Filename) {
+			continue
+		}
+		r := diagnostic{
+			Range:   d.Range,
+			Source:  "clang",
+			Code:    d.Option,
+			Message: d.Message,
+		}
+		switch {
+		case d.Severity >= clang.Diagnostic_Error:
+			r.Severity = severityError
+		case d.Severity == clang.Diagnostic_Warning:
+			r.Severity = severityWarning
+		case d.Severity == clang.Diagnostic_Note:
+			r.Severity = severityInformation
+		default:
+			continue
+		}
+		diagnostics = append(diagnostics, r)
+	}
+	s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+// session returns the up to date session of an open document.
+func (s *server) session(uri string) (*clangsession.Session, error) {
+	ss, ok := s.sessions[uri]
+	if !ok {
+		return nil, &responseError{codeInvalidParams, "document not open: " + uri}
+	}
+	return ss, nil
+}
+
+// parsed returns the session of an open document, reparsed if it was edited
+// since its last parse.
+func (s *server) parsed(uri string) (*clangsession.Session, error) {
+	ss, err := s.session(uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := ss.Flush(); err != nil {
+		return nil, &responseError{codeRequestFailed, err.Error()}
+	}
+	return ss, nil
+}
+
+// cursorAt returns the cursor at p in the translation unit of the session
+// and the declaration it refers to, or false when there is none.
+func cursorAt(tu clang.TranslationUnit, ss *clangsession.Session, p clangsession.Position) (clang.Cursor, clang.Cursor, bool) {
+	c := tu.Cursor(ss.Location(p))
+	if c.IsNull() || c.Kind().IsInvalid() || c.Kind().IsTranslationUnit() {
+		return c, c, false
+	}
+	ref := c.Referenced()
+	if ref.IsNull() {
+		return c, c, false
+	}
+	return c, ref, true
+}
+
+func (s *server) hover(p *textDocumentPositionParams) (interface{}, error) {
+	ss, err := s.parsed(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	var h *hover
+	ss.Do(func(tu clang.TranslationUnit) {
+		c, ref, ok := cursorAt(tu, ss, p.Position)
+		if !ok {
+			return
+		}
+		text := declaration(ref)
+		if comment := ref.BriefCommentText(); comment != "" {
+			text += "\n\n" + comment
+		}
+		_, r := ss.Range(c.SpellingNameRange(0, 0))
+		h = &hover{
+			Contents: markupContent{Kind: "plaintext", Value: text},
+			Range:    &r,
+		}
+	})
+	if h == nil {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// declaration returns a one line description of the declaration c.
+func declaration(c clang.Cursor) string {
+	switch c.Kind() {
+	case cursorkind.FunctionDecl, cursorkind.CXXMethod, cursorkind.Constructor, cursorkind.Destructor:
+		return strings.TrimSpace(c.ResultType().Spelling() + " " + c.DisplayName())
+	case cursorkind.TypedefDecl:
+		return "typedef " + c.TypedefDeclUnderlyingType().Spelling() + " " + c.Spelling()
+	case cursorkind.StructDecl, cursorkind.UnionDecl, cursorkind.EnumDecl, cursorkind.ClassDecl:
+		return c.Type().Spelling()
+	case cursorkind.MacroDefinition:
+		return "#define " + c.Spelling()
+	case cursorkind.EnumConstantDecl:
+		return fmt.Sprintf("%s = %d", c.Spelling(), c.EnumConstantDeclValue())
+	}
+	if t := c.Type().Spelling(); t != "" {
+		return t + " " + c.Spelling()
+	}
+	return c.Spelling()
+}
+
+func (s *server) definition(p *textDocumentPositionParams) (interface{}, error) {
+	ss, err := s.parsed(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	var locations []location
+	ss.Do(func(tu clang.TranslationUnit) {
+		_, ref, ok := cursorAt(tu, ss, p.Position)
+		if !ok {
+			return
+		}
+		def := ref.Definition()
+		if def.IsNull() {
+			def = ref
+		}
+		name, r := ss.Range(def.SpellingNameRange(0, 0))
+		if name != "" {
+			locations = append(locations, location{URI: pathURI(name), Range: r})
+		}
+	})
+	if locations == nil {
+		return nil, nil
+	}
+	return locations, nil
+}
+
+// references finds the cursors referring to the declaration at the position
+// in every open document, matching declarations by USR.
+func (s *server) references(p *referenceParams) (interface{}, error) {
+	ss, err := s.parsed(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	var usr string
+	ss.Do(func(tu clang.TranslationUnit) {
+		if _, ref, ok := cursorAt(tu, ss, p.Position); ok {
+			usr = ref.USR()
+		}
+	})
+	locations := []location{}
+	if usr == "" {
+		return locations, nil
+	}
+
+	var uris []string
+	for uri := range s.sessions {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		other, err := s.parsed(uri)
+		if err != nil {
+			continue
+		}
+		seen := make(map[clangsession.Range]bool)
+		other.Do(func(tu clang.TranslationUnit) {
+			if !tu.IsValid() {
+				return
+			}
+			tu.TranslationUnitCursor().Visit(func(cursor, parent clang.Cursor) clang.ChildVisitResult {
+				if !cursor.Location().IsFromMainFile() {
+					return clang.ChildVisit_Continue
+				}
+				if cursor.Kind().IsDeclaration() && !p.Context.IncludeDeclaration {
+					return clang.ChildVisit_Recurse
+				}
+				ref := cursor.Referenced()
+				if ref.IsNull() || ref.USR() != usr {
+					return clang.ChildVisit_Recurse
+				}
+				_, r := other.Range(cursor.SpellingNameRange(0, 0))
+				if !seen[r] {
+					seen[r] = true
+					locations = append(locations, location{URI: uri, Range: r})
+				}
+				return clang.ChildVisit_Recurse
+			})
+		})
+	}
+	return locations, nil
+}
+
+func (s *server) completion(p *textDocumentPositionParams) (interface{}, error) {
+	ss, err := s.parsed(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	list := &completionList{Items: []completionItem{}}
+	contents, _ := ss.Contents()
+	ss.Do(func(tu clang.TranslationUnit) {
+		_, line, column, _ := ss.Location(p.Position).FileLocation()
+		uf := clang.NewUnsavedFile(ss.Filename, contents)
+		defer uf.Dispose()
+		options := clang.CodeComplete_Flags(clang.DefaultCodeCompleteOptions()) | clang.CodeComplete_IncludeBriefComments
+		res := tu.CodeCompleteAt(ss.Filename, line, column, []clang.UnsavedFile{uf}, options)
+		if res == nil {
+			return
+		}
+		defer res.Dispose()
+
+		results := res.Results()
+		clang.SortCodeCompletionResults(results)
+		for i, r := range results {
+			cs := r.CompletionString()
+			if cs.Availability() == clang.Availability_NotAvailable || cs.Availability() == clang.Availability_NotAccessible {
+				continue
+			}
+			if len(list.Items) == maxCompletions {
+				list.IsIncomplete = true
+				break
+			}
+			item := completionItem{
+				Kind:       completionKind(r.CursorKind()),
+				SortText:   fmt.Sprintf("%05d", i),
+				Deprecated: cs.Availability() == clang.Availability_Deprecated,
+			}
+			var signature strings.Builder
+			for j := uint32(0); j < cs.NumChunks(); j++ {
+				text := cs.ChunkText(j)
+				switch cs.ChunkKind(j) {
+				case clang.CompletionChunk_TypedText:
+					item.Label = text
+					item.InsertText = text
+					signature.WriteString(text)
+				case clang.CompletionChunk_ResultType:
+					item.Detail = text
+				case clang.CompletionChunk_Optional, clang.CompletionChunk_Informative, clang.CompletionChunk_VerticalSpace:
+				default:
+					signature.WriteString(text)
+				}
+			}
+			if item.Label == "" {
+				continue
+			}
+			if sig := signature.String(); sig != item.Label {
+				item.Detail = strings.TrimSpace(item.Detail + " " + sig)
+			}
+			if comment := cs.BriefComment(); comment != "" {
+				item.Documentation = &markupContent{Kind: "plaintext", Value: comment}
+			}
+			list.Items = append(list.Items, item)
+		}
+	})
+	return list, nil
+}
+
+func completionKind(k cursorkind.Kind) int {
+	switch k {
+	case cursorkind.FunctionDecl, cursorkind.FunctionTemplate:
+		return completionFunction
+	case cursorkind.CXXMethod:
+		return completionMethod
+	case cursorkind.Constructor:
+		return completionConstructor
+	case cursorkind.FieldDecl:
+		return completionField
+	case cursorkind.VarDecl, cursorkind.ParmDecl:
+		return completionVariable
+	case cursorkind.ClassDecl, cursorkind.ClassTemplate, cursorkind.TypedefDecl, cursorkind.TypeAliasDecl:
+		return completionClass
+	case cursorkind.StructDecl, cursorkind.UnionDecl:
+		return completionStruct
+	case cursorkind.EnumDecl:
+		return completionEnum
+	case cursorkind.EnumConstantDecl:
+		return completionEnumMember
+	case cursorkind.Namespace:
+		return completionModule
+	case cursorkind.TemplateTypeParameter:
+		return completionTypeParam
+	case cursorkind.NotImplemented:
+		// Keywords and code patterns.
+		return completionKeyword
+	}
+	return completionText
+}
+
+func (s *server) documentSymbol(p *documentSymbolParams) (interface{}, error) {
+	ss, err := s.parsed(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []documentSymbol{}
+	ss.Do(func(tu clang.TranslationUnit) {
+		if !tu.IsValid() {
+			return
+		}
+		var visit func(cursor clang.Cursor) []documentSymbol
+		visit = func(cursor clang.Cursor) []documentSymbol {
+			var r []documentSymbol
+			cursor.Visit(func(c, parent clang.Cursor) clang.ChildVisitResult {
+				kind := symbolKind(c.Kind())
+				if kind == 0 || c.Spelling() == "" || !c.Location().IsFromMainFile() {
+					return clang.ChildVisit_Continue
+				}
+				_, extent := ss.Range(c.Extent())
+				_, name := ss.Range(c.SpellingNameRange(0, 0))
+				sym := documentSymbol{
+					Name:           c.Spelling(),
+					Kind:           kind,
+					Range:          extent,
+					SelectionRange: name,
+				}
+				if t := c.Type().Spelling(); kind == symbolFunction || kind == symbolVariable || kind == symbolField {
+					sym.Detail = t
+				}
+				switch kind {
+				case symbolStruct, symbolClass, symbolEnum, symbolNamespace:
+					sym.Children = visit(c)
+				}
+				r = append(r, sym)
+				return clang.ChildVisit_Continue
+			})
+			return r
+		}
+		symbols = append(symbols, visit(tu.TranslationUnitCursor())...)
+	})
+	return symbols, nil
+}
+
+func symbolKind(k cursorkind.Kind) int {
+	switch k {
+	case cursorkind.FunctionDecl, cursorkind.FunctionTemplate:
+		return symbolFunction
+	case cursorkind.CXXMethod:
+		return symbolMethod
+	case cursorkind.Constructor:
+		return symbolConstructor
+	case cursorkind.VarDecl:
+		return symbolVariable
+	case cursorkind.FieldDecl:
+		return symbolField
+	case cursorkind.StructDecl, cursorkind.UnionDecl:
+		return symbolStruct
+	case cursorkind.ClassDecl, cursorkind.ClassTemplate, cursorkind.TypedefDecl, cursorkind.TypeAliasDecl:
+		return symbolClass
+	case cursorkind.EnumDecl:
+		return symbolEnum
+	case cursorkind.EnumConstantDecl:
+		return symbolEnumMember
+	case cursorkind.Namespace:
+		return symbolNamespace
+	case cursorkind.MacroDefinition:
+		return symbolConstant
+	}
+	return 0
+}
+
+// uriPath returns the file name of a file URI.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path))
+}
+
+// pathURI returns the file URI of a file name.
+func pathURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
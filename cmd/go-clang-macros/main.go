@@ -0,0 +1,127 @@
+// go-clang-macros reports on the macros of every entry of a compilation
+// database: where each macro is defined and expanded, which macros are never
+// used, which macros other macros use, and which function-like macros could
+// be inline functions.
+//
+// $ go-clang-macros /path/to/build
+// or, to list where every macro is expanded
+// $ go-clang-macros -sites /path/to/build
+// or the unused macros only
+// $ go-clang-macros -unused /path/to/build
+// or the macros that could be inline functions
+// $ go-clang-macros -inline /path/to/build
+// or the dependency graph of the macros, for Graphviz
+// $ go-clang-macros -format dot /path/to/build > macros.dot
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangmacros"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-macros", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format, text, dot or json")
+	sites := flags.Bool("sites", false, "list where every macro is expanded")
+	unused := flags.Bool("unused", false, "only report the unused macros")
+	inline := flags.Bool("inline", false, "only report the function-like macros that could be inline functions")
+	system := flags.Bool("system", false, "also report the macros of system headers")
+	cflags := flags.String("cflags", "", "space separated flags to pass to clang")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "dot" && *format != "json" {
+		fmt.Printf("**error: unknown format %q\n", *format)
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Printf("**error: you need to give a directory containing a 'compile_commands.json' file\n")
+		return 1
+	}
+
+	db, err := clang.FromDirectory(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("**error: could not open compilation database at [%s]: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	s, problems := clangmacros.Build(db.AllCompileCommands(), clangmacros.Options{
+		Args:   strings.Fields(*cflags),
+		System: *system,
+	})
+	db.Dispose()
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, "PROBLEM:", p)
+	}
+
+	var macros []*clangmacros.Macro
+	for _, m := range s.Sorted() {
+		if *unused && !m.Unused() || *inline && !m.Inline {
+			continue
+		}
+		macros = append(macros, m)
+	}
+
+	switch *format {
+	case "dot":
+		err = s.WriteDOT(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(macros)
+	default:
+		for _, m := range macros {
+			printMacro(m, *sites)
+		}
+	}
+	if err != nil {
+		fmt.Printf("**error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printMacro(m *clangmacros.Macro, sites bool) {
+	name := m.Name
+	if m.FunctionLike {
+		name += "(" + strings.Join(m.Params, ", ") + ")"
+	}
+	var notes []string
+	switch {
+	case m.Guard:
+		notes = append(notes, "include guard")
+	case m.Unused():
+		notes = append(notes, "unused")
+	default:
+		notes = append(notes, fmt.Sprintf("%d expansions", len(m.Expansions)))
+	}
+	if len(m.Uses) > 0 {
+		notes = append(notes, "uses "+strings.Join(m.Uses, " "))
+	}
+	if len(m.UsedBy) > 0 {
+		notes = append(notes, "used by "+strings.Join(m.UsedBy, " "))
+	}
+	switch {
+	case m.Inline && m.Reason != "":
+		notes = append(notes, "could be an inline function, it "+m.Reason)
+	case m.Inline:
+		notes = append(notes, "could be an inline function")
+	case m.Reason != "":
+		notes = append(notes, "not an inline function, it "+m.Reason)
+	}
+	fmt.Printf("%v: %s: %s\n", m.Site, name, strings.Join(notes, "; "))
+	if sites {
+		for _, e := range m.Expansions {
+			fmt.Printf("\t%v\n", e)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoClangMacros(t *testing.T) {
+	testdata, err := filepath.Abs("../../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]string
+	for _, file := range []string{"hello.c", "macros.c"} {
+		entries = append(entries, map[string]string{
+			"directory": testdata,
+			"command":   "cc -c -o " + file + ".o " + file,
+			"file":      file,
+		})
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-cflags", *cflags, "-sites", dir}, 0},
+		{[]string{"-cflags", *cflags, "-unused", dir}, 0},
+		{[]string{"-cflags", *cflags, "-inline", "-format", "json", dir}, 0},
+		{[]string{"-cflags", *cflags, "-format", "dot", dir}, 0},
+		{[]string{"-format", "svg", dir}, 2},
+		{[]string{}, 1},
+	} {
+		if r := cmd(tc.args); r != tc.want {
+			t.Errorf("cmd(%v) = %d, want %d", tc.args, r, tc.want)
+		}
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
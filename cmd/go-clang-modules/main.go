@@ -0,0 +1,113 @@
+// go-clang-modules lists the clang modules used by a source file, or writes a
+// module map for a directory of headers.
+//
+// The arguments after -- are passed to libclang, which should be given
+// -fmodules. Each module is shown with its submodules, its top-level headers
+// and whether the translation unit imports it, followed by the included files
+// and the module owning each of them.
+//
+// $ go-clang-modules -- -fmodules -fmodules-cache-path=/tmp/mc -I../../testdata/modules/shapes -c ../../testdata/modules/main.c
+// or, to write the module.modulemap of a directory of headers, geometry.h
+// being the umbrella header
+// $ go-clang-modules -modulemap /path/to/include/geometry -name geometry
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangmodules"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	return run(args, os.Stdout)
+}
+
+// run is cmd, writing what it lists to w.
+func run(args []string, w io.Writer) int {
+	flags := flag.NewFlagSet("go-clang-modules", flag.ContinueOnError)
+	modulemap := flags.String("modulemap", "", "write a module.modulemap for the headers of this directory")
+	name := flags.String("name", "", "module name for -modulemap, defaults to the directory name")
+	umbrella := flags.String("umbrella", "", "umbrella header for -modulemap, relative to the directory")
+	force := flags.Bool("force", false, "overwrite an existing module.modulemap")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *modulemap != "" {
+		if *name == "" {
+			*name = filepath.Base(filepath.Clean(*modulemap))
+		}
+		path, err := clangmodules.WriteModuleMap(*modulemap, *name, *umbrella, *force)
+		if err != nil {
+			fmt.Fprintln(w, "PROBLEM:", err)
+			return 1
+		}
+		fmt.Fprintln(w, path)
+		return 0
+	}
+
+	idx := clang.NewIndex(0, 1)
+	defer idx.Dispose()
+
+	// Pass the DetailedPreprocessingRecord option so libclang keeps the detailed information about inclusions.
+	tu := idx.ParseTranslationUnit("", flags.Args(), nil, clang.TranslationUnit_DetailedPreprocessingRecord)
+	defer tu.Dispose()
+
+	diagnostics := tu.Diagnostics()
+	for _, d := range diagnostics {
+		fmt.Fprintln(w, "PROBLEM:", d.Spelling())
+	}
+
+	if tu.TranslationUnitCursor().IsNull() {
+		fmt.Fprintln(w, "PROBLEM: TranslationUnitCursor creation failed")
+		return 1
+	}
+
+	ms := clangmodules.Explore(tu)
+	ms.Walk(func(m *clangmodules.Module, depth int) {
+		indent := strings.Repeat("  ", depth)
+		var notes []string
+		if m.IsSystem {
+			notes = append(notes, "system")
+		}
+		if m.Imported {
+			notes = append(notes, "imported")
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(w, "%s%s (%s)\n", indent, m.FullName, strings.Join(notes, ", "))
+		} else {
+			fmt.Fprintf(w, "%s%s\n", indent, m.FullName)
+		}
+		for _, h := range m.Headers {
+			fmt.Fprintf(w, "%s  header: %s\n", indent, h)
+		}
+	})
+
+	var files []string
+	for f := range ms.Files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	if len(files) > 0 {
+		fmt.Fprintln(w, "files:")
+	}
+	for _, f := range files {
+		owner := "(no module)"
+		if m := ms.Files[f]; m != nil {
+			owner = m.FullName
+		}
+		fmt.Fprintf(w, "  %s: %s\n", f, owner)
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGoClangModules(t *testing.T) {
+	additional := dropEmpties(strings.Split(*cflags, " "))
+	cache := t.TempDir()
+	args := append([]string{"--", "-fmodules", "-fmodules-cache-path=" + cache, "-I../../testdata/modules/shapes"}, additional...)
+	args = append(args, "-c", "../../testdata/modules/main.c")
+	var out bytes.Buffer
+	if r := run(args, &out); r != 0 {
+		t.Errorf("run(%v) = %d", args, r)
+	}
+
+	// The module with its submodule, and the files each owns.
+	for _, want := range []string{
+		`(?m)^shapes( \(.*\))?$`,
+		`(?m)^  shapes\.circle( \(.*\))?$`,
+		`(?m)^files:$`,
+		`(?m)^  .*circle\.h: shapes\.circle$`,
+		`(?m)^  .*shapes\.h: shapes$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(out.String()) {
+			t.Errorf("output does not match %s:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestGoClangModulesModuleMap(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"geometry.h", "other.h"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("int f(void);\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := cmd([]string{"-modulemap", dir, "-name", "geometry"}); r != 0 {
+		t.Fatalf("cmd = %d", r)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "module.modulemap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A plain module, as clang only reads framework modules from frameworks.
+	if !strings.HasPrefix(string(b), "module geometry {") {
+		t.Errorf("module.modulemap is not of the plain module geometry:\n%s", b)
+	}
+	if !strings.Contains(string(b), `umbrella header "geometry.h"`) {
+		t.Errorf("module.modulemap does not contain the umbrella header:\n%s", b)
+	}
+
+	// Which clang reads from a directory searched with -I.
+	source := filepath.Join(dir, "main.c")
+	if err := ioutil.WriteFile(source, []byte("#include \"geometry.h\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := append([]string{"--", "-fmodules", "-fmodules-cache-path=" + t.TempDir(), "-I" + dir}, dropEmpties(strings.Split(*cflags, " "))...)
+	args = append(args, "-c", source)
+	var out bytes.Buffer
+	if r := run(args, &out); r != 0 {
+		t.Fatalf("run(%v) = %d", args, r)
+	}
+	if !regexp.MustCompile(`(?m)^  .*geometry\.h: geometry`).MatchString(out.String()) {
+		t.Errorf("geometry.h not owned by the module geometry:\n%s", out.String())
+	}
+
+	// The module map is not overwritten, unless forced.
+	if r := cmd([]string{"-modulemap", dir, "-name", "geometry", "-umbrella", "other.h"}); r == 0 {
+		t.Errorf("cmd overwriting module.modulemap = %d", r)
+	}
+	if c, err := ioutil.ReadFile(filepath.Join(dir, "module.modulemap")); err != nil || !bytes.Equal(c, b) {
+		t.Errorf("module.modulemap changed:\n%s", c)
+	}
+	if r := cmd([]string{"-modulemap", dir, "-name", "geometry", "-umbrella", "other.h", "-force"}); r != 0 {
+		t.Errorf("cmd -force = %d", r)
+	}
+	if c, err := ioutil.ReadFile(filepath.Join(dir, "module.modulemap")); err != nil || !strings.Contains(string(c), `umbrella header "other.h"`) {
+		t.Errorf("module.modulemap not overwritten:\n%s", c)
+	}
+
+	// Without a header named after the module there is no umbrella header.
+	if r := cmd([]string{"-modulemap", dir, "-name", "shapes", "-force"}); r == 0 {
+		t.Errorf("cmd without an umbrella header = %d", r)
+	}
+	// Nor is the one of the shapes, with its submodule.
+	if r := cmd([]string{"-modulemap", "../../testdata/modules/shapes", "-name", "shapes"}); r == 0 {
+		t.Errorf("cmd overwriting the shapes module.modulemap = %d", r)
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
+
+func dropEmpties(ss []string) (r []string) {
+	for _, s := range ss {
+		if s != "" {
+			r = append(r, s)
+		}
+	}
+	return
+}
@@ -0,0 +1,98 @@
+// go-clang-pch proposes a precompiled header for the entries of a compilation
+// database and can verify how much it speeds up parsing.
+//
+// The headers included by a large enough share of the translation units are
+// ranked by their estimated parse cost, see go-clang-profile, and the most
+// expensive are proposed. With -verify, a header including them is written
+// and precompiled, and every translation unit is parsed without it, with it
+// and reparsed with a precompiled preamble.
+//
+// $ go-clang-pch /path/to/build
+// or
+// $ go-clang-pch -share 0.8 -max 5 -verify -dir /tmp/pch /path/to/build
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangprofile"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-pch", flag.ContinueOnError)
+	share := flags.Float64("share", 0.5, "propose headers included by at least this share of the translation units")
+	max := flags.Int("max", 10, "propose at most this many headers, 0 for no limit")
+	system := flags.Bool("system", true, "also consider system headers")
+	verify := flags.Bool("verify", false, "build the precompiled header and measure the speedup")
+	dir := flags.String("dir", "", "directory to write the precompiled header to, defaults to a temporary one")
+	cflags := flags.String("cflags", "", "space separated flags to pass to clang")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Printf("**error: you need to give a directory containing a 'compile_commands.json' file\n")
+		return 1
+	}
+
+	db, err := clang.FromDirectory(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("**error: could not open compilation database at [%s]: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	defer db.Dispose()
+	cmds := db.AllCompileCommands()
+
+	p := clangprofile.Profiler{
+		Args:   strings.Fields(*cflags),
+		System: *system,
+	}
+	report := p.Profile(cmds)
+	for _, tu := range report.TUs {
+		for _, problem := range tu.Problems {
+			fmt.Printf("PROBLEM: %s: %s\n", tu.Filename, problem)
+		}
+	}
+
+	headers := report.ProposePCH(*share, *max)
+	fmt.Printf("%d headers proposed for %d translation units:\n", len(headers), len(report.TUs))
+	fmt.Printf("%12s %12s %4s  %s\n", "cost", "parse", "TUs", "header")
+	for _, h := range headers {
+		fmt.Printf("%12v %12v %4d  %s\n", h.Cost(), h.Duration, h.Includers, h.Name)
+	}
+	if len(headers) == 0 || !*verify {
+		return 0
+	}
+
+	if *dir == "" {
+		*dir, err = ioutil.TempDir("", "go-clang-pch")
+		if err != nil {
+			fmt.Printf("**error: %v\n", err)
+			return 1
+		}
+	}
+	v, err := p.VerifyPCH(cmds, report, headers, *dir)
+	if err != nil {
+		fmt.Printf("**error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("\n%s built in %v from %s\n", v.PCH, v.Build, v.Header)
+	fmt.Printf("%12s %12s %12s  %s\n", "parse", "with pch", "reparse", "file")
+	for _, tu := range v.TUs {
+		fmt.Printf("%12v %12v %12v  %s\n", tu.Parse, tu.PCHParse, tu.Reparse, tu.Filename)
+		for _, problem := range tu.Problems {
+			fmt.Println("PROBLEM:", problem)
+		}
+	}
+	pch, preamble := v.Speedup()
+	fmt.Printf("speedup: %.2fx with the precompiled header, %.2fx reparsing with a preamble\n", pch, preamble)
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoClangPCH(t *testing.T) {
+	testdata, err := filepath.Abs("../../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]string
+	for _, file := range []string{"hello.c", "globals.c", "struct.c"} {
+		entries = append(entries, map[string]string{
+			"directory": testdata,
+			"command":   "cc -c " + file,
+			"file":      file,
+		})
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pch := t.TempDir()
+	for _, args := range [][]string{
+		[]string{"-cflags", *cflags, dir},
+		[]string{"-cflags", *cflags, "-share", "0.3", "-verify", "-dir", pch, dir},
+	} {
+		if r := cmd(args); r != 0 {
+			t.Errorf("cmd(%v) = %d", args, r)
+		}
+	}
+	// hello.c includes stdio.h, so there is something to precompile.
+	if _, err := os.Stat(filepath.Join(pch, "pch.h.pch")); err != nil {
+		t.Error(err)
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
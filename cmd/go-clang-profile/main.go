@@ -0,0 +1,91 @@
+// go-clang-profile measures the parse time and memory of every entry of a
+// compilation database and ranks the headers they include by estimated cost.
+//
+// Each header is parsed on its own to measure it. Its estimated cost is that
+// parse time multiplied by the number of translation units including it.
+//
+// $ go-clang-profile /path/to/build
+// or, to also profile system headers and limit the headers parsed
+// $ go-clang-profile -system -headers 50 /path/to/build
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clangprofile"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	return run(args, os.Stdout)
+}
+
+// run is cmd, writing the report to w.
+func run(args []string, w io.Writer) int {
+	flags := flag.NewFlagSet("go-clang-profile", flag.ContinueOnError)
+	system := flags.Bool("system", false, "also parse system headers standalone")
+	headers := flags.Int("headers", 0, "parse at most this many headers standalone, those included most often")
+	top := flags.Int("top", 20, "number of headers to report, 0 for all")
+	cflags := flags.String("cflags", "", "space separated flags to pass to clang")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(w, "**error: you need to give a directory containing a 'compile_commands.json' file\n")
+		return 1
+	}
+
+	db, err := clang.FromDirectory(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(w, "**error: could not open compilation database at [%s]: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	defer db.Dispose()
+
+	p := clangprofile.Profiler{
+		Args:       strings.Fields(*cflags),
+		System:     *system,
+		MaxHeaders: *headers,
+	}
+	report := p.Profile(db.AllCompileCommands())
+
+	fmt.Fprintf(w, "%d translation units:\n", len(report.TUs))
+	fmt.Fprintf(w, "%12s %10s %6s  %s\n", "parse", "memory", "files", "file")
+	for _, tu := range report.TUs {
+		fmt.Fprintf(w, "%12v %10s %6d  %s\n", tu.Duration, size(tu.Memory), len(tu.Closure), tu.Filename)
+		for _, p := range tu.Problems {
+			fmt.Fprintln(w, "PROBLEM:", p)
+		}
+	}
+
+	n := len(report.Headers)
+	if *top > 0 && n > *top {
+		n = *top
+	}
+	fmt.Fprintf(w, "\n%d most expensive of %d headers:\n", n, len(report.Headers))
+	fmt.Fprintf(w, "%12s %12s %10s %6s %4s  %s\n", "cost", "parse", "memory", "files", "TUs", "header")
+	for _, h := range report.Headers[:n] {
+		fmt.Fprintf(w, "%12v %12v %10s %6d %4d  %s\n", h.Cost(), h.Duration, size(h.Memory), len(h.Closure), h.Includers, h.Name)
+	}
+	return 0
+}
+
+func size(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestGoClangProfile(t *testing.T) {
+	includes, err := filepath.Abs("../../testdata/includes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]string
+	for _, file := range []string{"main.c", "unused.c"} {
+		entries = append(entries, map[string]string{
+			"directory": includes,
+			"command":   "cc -Iinc -c -o " + file + ".o " + file,
+			"file":      file,
+		})
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "compile_commands.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-cflags", *cflags, dir}, []string{
+			`^2 translation units:$`,
+			` 4  .*/includes/main\.c$`,
+			` 4  .*/includes/unused\.c$`,
+			`^4 most expensive of 4 headers:$`,
+			` 2  .*/includes/local\.h$`,
+			` 2  .*/includes/inc/a\.h$`,
+		}},
+		{[]string{"-cflags", *cflags, "-system", "-headers", "2", "-top", "1", dir}, []string{
+			`^1 most expensive of 2 headers:$`,
+			` 2  .*/includes/inc/[ab]\.h$`,
+		}},
+	} {
+		var out bytes.Buffer
+		if r := run(tc.args, &out); r != 0 {
+			t.Errorf("cmd(%v) = %d", tc.args, r)
+		}
+		if regexp.MustCompile(`(?m)^PROBLEM:`).Match(out.Bytes()) {
+			t.Errorf("cmd(%v) reports problems:\n%s", tc.args, out.String())
+		}
+		for _, want := range tc.want {
+			if !regexp.MustCompile("(?m)" + want).Match(out.Bytes()) {
+				t.Errorf("cmd(%v) output has no line matching %s:\n%s", tc.args, want, out.String())
+			}
+		}
+	}
+	if r := run([]string{dir + "-not-there"}, ioutil.Discard); r != 1 {
+		t.Errorf("cmd of a missing directory = %d, want 1", r)
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
@@ -0,0 +1,124 @@
+// go-clang-query prints the cursors of a translation unit a selector selects,
+// see package astquery for the selectors, with their location, kind and name.
+//
+// The selector is followed by the source file, and the arguments after it
+// are passed to libclang. With -load, the translation unit is read from a
+// file written by -save instead, and no source file is given. Files written
+// by -save before it used the binary format of package ast still load.
+//
+// $ go-clang-query 'FunctionDecl[name=main] > CompoundStmt CallExpr[name^=str]' file.c -Iinclude
+// or, to query a file again later without parsing it again
+// $ go-clang-query -save file.ast 'FunctionDecl' file.c
+// $ go-clang-query -load file.ast 'CallExpr[name=malloc]'
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/astbridge"
+	"github.com/frankreh/go-clang/astquery"
+	"github.com/frankreh/go-clang/clang"
+)
+
+func main() {
+	os.Exit(cmd(os.Args[1:]))
+}
+
+func cmd(args []string) int {
+	flags := flag.NewFlagSet("go-clang-query", flag.ContinueOnError)
+	load := flags.String("load", "", "read the translation unit from this file rather than parse a source file")
+	save := flags.String("save", "", "write the translation unit to this file")
+	count := flags.Bool("count", false, "print the number of cursors selected only")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 {
+		fmt.Printf("**error: you need to give a selector\n")
+		return 2
+	}
+	q, err := astquery.Compile(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("**error: %v\n", err)
+		return 2
+	}
+
+	var tu *ast.TranslationUnit
+	if *load != "" {
+		if flags.NArg() > 1 {
+			fmt.Printf("**error: no source file is parsed with -load\n")
+			return 2
+		}
+		tu, err = loadTu(*load)
+	} else {
+		if flags.NArg() < 2 {
+			fmt.Printf("**error: you need to give a source file\n")
+			return 1
+		}
+		tu, err = parseTu(flags.Arg(1), flags.Args()[2:])
+	}
+	if err != nil {
+		fmt.Printf("**error: %v\n", err)
+		return 1
+	}
+	if *save != "" {
+		if err := saveTu(*save, tu); err != nil {
+			fmt.Printf("**error: %v\n", err)
+			return 1
+		}
+	}
+
+	ids := q.Select(tu)
+	if *count {
+		fmt.Println(len(ids))
+		return 0
+	}
+	for _, id := range ids {
+		c := tu.Cursors[id]
+		fmt.Printf("%s: %s %s\n", tu.Position(tu.CursorLocation(id)), c.CursorKindId, tu.CursorNameMap.ToString(c.CursorNameId))
+	}
+	return 0
+}
+
+func parseTu(source string, args []string) (*ast.TranslationUnit, error) {
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+	tu := idx.ParseTranslationUnit(source, args, nil, 0)
+	if !tu.IsValid() {
+		return nil, fmt.Errorf("parsing %s failed", source)
+	}
+	defer tu.Dispose()
+
+	ctu := astbridge.ClangTranslationUnit{}
+	if err := ctu.Populate(&tu, nil); err != nil {
+		return nil, err
+	}
+	return &ctu.GoTu, nil
+}
+
+func loadTu(name string) (*ast.TranslationUnit, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tu := &ast.TranslationUnit{}
+	if err := tu.Decode(f); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", name, err)
+	}
+	return tu, nil
+}
+
+func saveTu(name string, tu *ast.TranslationUnit) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := tu.EncodeBinary(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
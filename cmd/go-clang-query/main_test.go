@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoClangQuery(t *testing.T) {
+	additional := strings.Fields(*cflags)
+	dir, err := ioutil.TempDir("", "go-clang-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	saved := filepath.Join(dir, "callgraph.ast")
+
+	source := "../../testdata/callgraph.c"
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"FunctionDecl[name=main] CallExpr", source}, 0},
+		{[]string{"-count", "-save", saved, "FunctionDecl:has(CallExpr[name=fact])", source}, 0},
+		{[]string{"-load", saved, "ParmDecl[type^=int]"}, 0},
+		{[]string{"-load", saved, "CallExpr", source}, 2},
+		{[]string{"FunctionDecl[size=1]", source}, 2},
+		{[]string{}, 2},
+		{[]string{"FunctionDecl"}, 1},
+		{[]string{"-load", filepath.Join(dir, "missing.ast"), "FunctionDecl"}, 1},
+	} {
+		args := tc.args
+		if tc.want == 0 && !strings.HasPrefix(args[0], "-load") {
+			args = append(args, additional...)
+		}
+		if r := cmd(args); r != tc.want {
+			t.Errorf("cmd(%v) = %d, want %d", args, r, tc.want)
+		}
+	}
+}
+
+var cflags = flag.String("cflags", "", "space separated flags to pass to clang")
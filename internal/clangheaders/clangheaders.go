@@ -0,0 +1,105 @@
+// Package clangheaders parses the libclang headers vendored in clang/clang-c,
+// for the commands generating or checking the clang package from them, and
+// turns their doc comments into Go comments.
+package clangheaders
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/frankreh/go-clang/clang"
+)
+
+// Parse parses every header in dir/clang-c and calls fn with the translation
+// unit. The cflags are passed on to clang. Function bodies are skipped, and
+// a diagnostic of an error fails the parse.
+//
+// A single unsaved source, named source, including all the headers gives one
+// translation unit covering every declaration, with each header parsed once.
+func Parse(dir, source string, cflags []string, fn func(tu clang.TranslationUnit)) error {
+	headers, err := filepath.Glob(filepath.Join(dir, "clang-c", "*.h"))
+	if err != nil {
+		return err
+	}
+	if len(headers) == 0 {
+		return fmt.Errorf("no headers found in %s", filepath.Join(dir, "clang-c"))
+	}
+	sort.Strings(headers)
+
+	var b strings.Builder
+	for _, h := range headers {
+		fmt.Fprintf(&b, "#include \"clang-c/%s\"\n", filepath.Base(h))
+	}
+
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	unsaved := []clang.UnsavedFile{clang.NewUnsavedFile(source, b.String())}
+	args := append([]string{"-x", "c", "-I" + dir}, cflags...)
+	tu := idx.ParseTranslationUnit(source, args, unsaved, clang.TranslationUnit_SkipFunctionBodies)
+	if !tu.IsValid() {
+		return fmt.Errorf("parsing %s failed", filepath.Join(dir, "clang-c"))
+	}
+	defer tu.Dispose()
+
+	for _, d := range tu.Diagnostics() {
+		if d.Severity() >= clang.Diagnostic_Error {
+			return fmt.Errorf("parsing %s: %s", filepath.Join(dir, "clang-c"), d.Spelling())
+		}
+	}
+
+	fn(tu)
+	return nil
+}
+
+// DocText turns a raw doxygen comment from the headers into plain text lines.
+func DocText(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if strings.HasPrefix(raw, "/*") {
+		raw = strings.TrimPrefix(raw, "/*")
+		raw = strings.TrimLeft(raw, "*!")
+		raw = strings.TrimPrefix(raw, "<")
+		raw = strings.TrimSuffix(raw, "*/")
+	}
+
+	var lines []string
+	for _, l := range strings.Split(raw, "\n") {
+		l = strings.TrimLeft(l, " \t")
+		switch {
+		case strings.HasPrefix(l, "///"), strings.HasPrefix(l, "//!"):
+			l = strings.TrimPrefix(l[3:], "<")
+		case strings.HasPrefix(l, "*"):
+			l = l[1:]
+		}
+		l = strings.TrimPrefix(l, " ")
+		l = strings.TrimPrefix(l, "\\brief ")
+		lines = append(lines, strings.TrimRight(l, " \t"))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteComment writes text as // comment lines with the given indent.
+func WriteComment(b *bytes.Buffer, indent, text string) {
+	if text == "" {
+		return
+	}
+	for _, l := range strings.Split(text, "\n") {
+		if l == "" {
+			fmt.Fprintf(b, "%s//\n", indent)
+		} else {
+			fmt.Fprintf(b, "%s// %s\n", indent, l)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package clangheaders
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDocText(t *testing.T) {
+	for _, tc := range []struct{ raw, want string }{
+		{"/**\n * A C or C++ struct.\n */", "A C or C++ struct."},
+		{"/**< The entity is available. */", "The entity is available."},
+		{"/// One.\n/// Two.", "One.\nTwo."},
+		{"/**\n * \\brief First.\n *\n * Second.\n */", "First.\n\nSecond."},
+		{"", ""},
+	} {
+		if got := DocText(tc.raw); got != tc.want {
+			t.Errorf("DocText(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestWriteComment(t *testing.T) {
+	var b bytes.Buffer
+	WriteComment(&b, "\t", "First.\n\nSecond.")
+	WriteComment(&b, "", "")
+	if got, want := b.String(), "\t// First.\n\t//\n\t// Second.\n"; got != want {
+		t.Errorf("WriteComment wrote %q, want %q", got, want)
+	}
+}
@@ -574,8 +574,10 @@ func TestAst(t *testing.T) {
 				}
 			}
 
+			gobSize1 := 0
 			if test.ExpectedGobSize1 > 0 {
 				got := second_gobtest(t, test.Name+" second_gobtest", &tr.tuParser.TranslationUnit)
+				gobSize1 = got
 				if createGot {
 					test.ExpectedGobSize1 = got
 				} else {
@@ -587,6 +589,16 @@ func TestAst(t *testing.T) {
 					}
 				}
 			}
+
+			if gobSize1 > 0 {
+				// The binary encoding is to be smaller than the gob one of
+				// the same translation unit.
+				got := third_binarytest(t, test.Name+" third_binarytest", &tr.tuParser.TranslationUnit)
+				if got >= gobSize1 {
+					t.Errorf("%s Binary encoding: got %d, not less than gob %d\n",
+						test.Name, got, gobSize1)
+				}
+			}
 		})
 	}
 
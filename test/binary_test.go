@@ -0,0 +1,254 @@
+package clang_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/tokenkind"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+// binaryTu returns a small translation unit with something in each of the
+// parts the binary format writes. The children of each cursor follow each
+// other, as decoding requires.
+func binaryTu(t testing.TB) *ast.TranslationUnit {
+	t.Helper()
+	tu := &ast.TranslationUnit{
+		Cursors: []ast.Cursor{
+			{CursorKindId: cursorkind.TranslationUnit, ParentIndex: -1},
+			{CursorKindId: cursorkind.StructDecl, ParentIndex: 0, Tokens: ast.IndexPair{Head: 0, Len: 5}},
+			{CursorKindId: cursorkind.VarDecl, ParentIndex: 0, Tokens: ast.IndexPair{Head: 6, Len: 3}},
+			{CursorKindId: cursorkind.FieldDecl, ParentIndex: 1, Tokens: ast.IndexPair{Head: 3, Len: 2}},
+			{CursorKindId: cursorkind.TypeRef, ParentIndex: 2, Tokens: ast.IndexPair{Head: 6, Len: 2}},
+		},
+		Back:       map[int]int{},
+		Referenced: map[int]int{4: 1},
+		Definition: map[int]int{1: 1, 2: 2},
+	}
+	for i, name := range []string{"a.c", "s", "v", "x", "struct s"} {
+		tu.Cursors[i].CursorNameId = tu.CursorNameMap.Id(name)
+	}
+	for _, s := range []struct {
+		kind tokenkind.Kind
+		name string
+	}{
+		{tokenkind.Keyword, "struct"},
+		{tokenkind.Identifier, "s"},
+		{tokenkind.Punctuation, "{"},
+		{tokenkind.Keyword, "int"},
+		{tokenkind.Identifier, "x"},
+		{tokenkind.Punctuation, "}"},
+		{tokenkind.Keyword, "struct"},
+		{tokenkind.Identifier, "s"},
+		{tokenkind.Identifier, "v"},
+	} {
+		id := tu.TokenMap.Id(ast.Token{TokenKindId: s.kind, TokenNameId: tu.TokenNameMap.Id(s.name)})
+		tu.TokenIds = append(tu.TokenIds, id)
+	}
+
+	tm := &tu.TypeMap
+	tm.Init()
+	must := func(i int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	intType := must(tm.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Int}, Align: 4, Size: 4, TypeSpelling: "int"}))
+	record := must(tm.AddRecord(ast.TypeRecord{Align: 4, Size: 4, TypeSpelling: "struct s"}))
+	if err := tm.SetFields(record, []ast.TypeField{{Name: "x", TypeId: intType, Offset: 0, BitWidth: -1}}); err != nil {
+		t.Fatal(err)
+	}
+	pointer := must(tm.AddPointer(ast.TypePointer{UnderlyingTypeIndex: record}))
+	if err := tm.Qualify(pointer, ast.QualConst); err != nil {
+		t.Fatal(err)
+	}
+	must(tm.AddFunction(ast.TypeFunction{
+		TypeKindKind: ast.TypeKindKind{TypeKind: typekind.FunctionProto},
+		ResultTypeId: intType,
+		ArgIds:       []int{pointer},
+		TypeSpelling: "int (struct s *const, ...)",
+		Variadic:     true,
+	}))
+	must(tm.AddConstantArray(ast.TypeConstantArray{ElemCount: 2, TypeVariableArray: ast.TypeVariableArray{ElemTypeId: intType, Align: 4, Size: 8, TypeSpelling: "int [2]"}}))
+	must(tm.AddObjC(ast.TypeObjC{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.ObjCObject}, BaseTypeId: record, Protocols: []string{"P", "Q"}, TypeSpelling: "s<P, Q>"}))
+	tu.Cursors[1].TypeIndex = record
+	tu.Cursors[2].TypeIndex = record
+	tu.Cursors[3].TypeIndex = intType
+
+	tu.CursorLocs = []ast.Location{
+		{},
+		tu.AddLocation("a.c", 0, 1, 1),
+		tu.AddLocation("a.c", 20, 2, 1),
+		tu.AddLocation("a.c", 11, 1, 12),
+		tu.AddLocation("a.c", 20, 2, 1),
+	}
+	tu.CursorSpellings = map[int]ast.Location{
+		3: tu.AddLocation("a.h", 15, 1, 14),
+	}
+	for i := range tu.TokenIds {
+		tu.TokenLocs = append(tu.TokenLocs, tu.AddLocation("a.c", 2*i, 1, 2*i+1))
+	}
+	tu.TokenSpellings = map[int]ast.Location{}
+	return tu
+}
+
+// backTu returns binaryTu with a Back cursor, under the FieldDecl, leading
+// back to the StructDecl.
+func backTu(t testing.TB) *ast.TranslationUnit {
+	t.Helper()
+	tu := binaryTu(t)
+	tu.Cursors = append(tu.Cursors, ast.Cursor{CursorKindId: cursorkind.Back, ParentIndex: 3})
+	tu.CursorLocs = append(tu.CursorLocs, ast.Location{})
+	tu.Back[5] = 1
+	return tu
+}
+
+// assertEqualTu fails unless tu2 is what tu was encoded to, the cursors
+// included, which AssertEqual leaves out.
+func assertEqualTu(t *testing.T, tu, tu2 *ast.TranslationUnit) {
+	t.Helper()
+	if err := tu.AssertEqual(tu2); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualString(t, fmt.Sprintf("%#v", tu2.Cursors), fmt.Sprintf("%#v", tu.Cursors))
+	assertEqualString(t, fmt.Sprintf("%#v", tu2.TokenIds), fmt.Sprintf("%#v", tu.TokenIds))
+}
+
+func TestBinary(t *testing.T) {
+	tu := binaryTu(t)
+
+	// Decoding the gob version rebuilds the children of the cursors.
+	var gobBuf bytes.Buffer
+	if err := tu.EncodeGobV1(&gobBuf); err != nil {
+		t.Fatal(err)
+	}
+	gobSize := gobBuf.Len()
+	var want ast.TranslationUnit
+	if err := want.DecodeGobV1(bytes.NewReader(gobBuf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := want.EncodeBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assertTrue(t, bytes.HasPrefix(buf.Bytes(), []byte(ast.BinaryMagic)))
+	assertTrue(t, buf.Len() < gobSize)
+
+	var got ast.TranslationUnit
+	if err := got.DecodeBinary(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualTu(t, &want, &got)
+	assertEqualString(t, got.Position(got.CursorSpellingLocation(3)), "a.h:1:14")
+	assertEqualString(t, got.TypeMap.Spelling(got.Cursors[1].TypeIndex), "struct s")
+	assertEqualInt(t, len(got.Children(0, ast.IgnoreBack)), 2)
+
+	// Decode takes either format.
+	var fromGob, fromBinary ast.TranslationUnit
+	if err := fromGob.Decode(bytes.NewReader(gobBuf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualTu(t, &want, &fromGob)
+	if err := fromBinary.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualTu(t, &want, &fromBinary)
+
+	// A gob file migrates to the same bytes.
+	var migrated bytes.Buffer
+	if err := ast.MigrateGobV1(&migrated, bytes.NewReader(gobBuf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assertTrue(t, bytes.Equal(migrated.Bytes(), buf.Bytes()))
+
+	// An empty translation unit round trips too.
+	var empty, empty2 ast.TranslationUnit
+	buf.Reset()
+	if err := empty.EncodeBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := empty2.DecodeBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualTu(t, &empty, &empty2)
+}
+
+func TestBinaryReader(t *testing.T) {
+	tu := binaryTu(t)
+	var buf bytes.Buffer
+	if err := tu.EncodeBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	br, err := ast.NewBinaryReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualInt(t, br.Version(), ast.BinaryVersion)
+
+	// The types alone.
+	var types ast.TranslationUnit
+	if err := br.LoadTypes(&types); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.TypeMap.AssertEqual(&types.TypeMap); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualInt(t, len(types.Cursors), 0)
+	assertEqualInt(t, len(types.TokenIds), 0)
+	assertEqualInt(t, len(types.CursorLocs), 0)
+
+	// Then the tokens and the cursors, with the strings read already.
+	if err := br.LoadTokens(&types); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualString(t, fmt.Sprintf("%#v", types.TokenIds), fmt.Sprintf("%#v", tu.TokenIds))
+	if err := br.LoadCursors(&types); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualInt(t, len(types.Cursors), len(tu.Cursors))
+	assertEqualString(t, types.CursorNameMap.Strings[types.Cursors[4].CursorNameId], "struct s")
+
+	var all ast.TranslationUnit
+	if err := br.Load(&all); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.AssertEqual(&all); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	tu := binaryTu(t)
+	var buf bytes.Buffer
+	if err := tu.EncodeBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	var got ast.TranslationUnit
+	if err := got.DecodeBinary(bytes.NewReader([]byte("not a translation unit"))); err != ast.ErrNotBinary {
+		t.Errorf("bad magic: got %v, expected %v", err, ast.ErrNotBinary)
+	}
+
+	newer := append([]byte(nil), data...)
+	newer[len(ast.BinaryMagic)] = ast.BinaryVersion + 1
+	if err := got.DecodeBinary(bytes.NewReader(newer)); err == nil {
+		t.Errorf("newer version: no error")
+	}
+
+	// Every truncation fails, without panicking.
+	for n := len(ast.BinaryMagic); n < len(data); n++ {
+		var got ast.TranslationUnit
+		if err := got.DecodeBinary(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("truncated to %d of %d bytes: no error", n, len(data))
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package clang_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+// fuzzSeeds returns the encodings of a few translation units, by encode.
+func fuzzSeeds(f *testing.F, encode func(tu *ast.TranslationUnit, w io.Writer) error) [][]byte {
+	var seeds [][]byte
+	for _, tu := range []*ast.TranslationUnit{{}, binaryTu(f), backTu(f)} {
+		var buf bytes.Buffer
+		if err := encode(tu, &buf); err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, buf.Bytes())
+	}
+	return seeds
+}
+
+// useDecoded uses what was decoded without error the ways a translation unit
+// is used, none of which is to panic, and checks it encodes and decodes
+// again.
+func useDecoded(t *testing.T, tu *ast.TranslationUnit) {
+	_ = tu.SetBackChildren()
+	tu.WalkWith(0, ast.FollowBack, nil, nil)
+	for i := range tu.Cursors {
+		tu.CursorNameMap.ToString(tu.Cursors[i].CursorNameId)
+		tu.Ancestors(i)
+		tu.Position(tu.CursorLocation(i))
+		tu.Position(tu.CursorSpellingLocation(i))
+	}
+	for _, id := range tu.TokenIds {
+		tu.TokenNameMap.ToString(tu.TokenMap.ToToken(id).TokenNameId)
+	}
+	for i := 0; i < tu.TypeMap.Len(); i++ {
+		tu.TypeMap.Spelling(i)
+		tu.TypeMap.Declarator(i, "x")
+		tu.TypeMap.Canonical(i)
+		tu.TypeMap.PointerDepth(i)
+	}
+
+	var buf bytes.Buffer
+	if err := tu.EncodeBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tu2 ast.TranslationUnit
+	if err := tu2.DecodeBinary(&buf); err != nil {
+		t.Fatalf("decoding again: %v", err)
+	}
+	if err := tu.AssertEqual(&tu2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzDecodeGobV1(f *testing.F) {
+	for _, seed := range fuzzSeeds(f, (*ast.TranslationUnit).EncodeGobV1) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tu ast.TranslationUnit
+		if err := tu.DecodeGobV1(bytes.NewReader(data)); err != nil {
+			return
+		}
+		useDecoded(t, &tu)
+	})
+}
+
+func FuzzDecodeBinary(f *testing.F) {
+	for _, seed := range fuzzSeeds(f, (*ast.TranslationUnit).EncodeBinary) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tu ast.TranslationUnit
+		if err := tu.DecodeBinary(bytes.NewReader(data)); err != nil {
+			return
+		}
+		useDecoded(t, &tu)
+	})
+}
+
+func TestDecodeChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(tu *ast.TranslationUnit)
+		want    string
+	}{
+		{"parent", func(tu *ast.TranslationUnit) { tu.Cursors[2].ParentIndex = 7 }, "cursor 2: parent 7 not before it"},
+		{"root", func(tu *ast.TranslationUnit) { tu.Cursors[0].ParentIndex = 0 }, "root with parent 0"},
+		{"back", func(tu *ast.TranslationUnit) { tu.Back[9] = 1 }, "Back 9:1: cursors out of range"},
+		{"referenced", func(tu *ast.TranslationUnit) { tu.Referenced[2] = -2 }, "Referenced 2:-2: cursors out of range"},
+		{"name", func(tu *ast.TranslationUnit) { tu.Cursors[1].CursorNameId = 50 }, "cursor 1: CursorNameMap"},
+		{"token id", func(tu *ast.TranslationUnit) { tu.TokenIds[0] = 99 }, "TokenIds 0: TokenMap"},
+		{"no names", func(tu *ast.TranslationUnit) { tu.CursorNameMap = ast.StringMap{} }, "cursor 0: CursorNameMap"},
+		{"no tokens", func(tu *ast.TranslationUnit) {
+			tu.TokenMap = ast.TokenMap{}
+			tu.TokenNameMap = ast.StringMap{}
+		}, "TokenIds 0: TokenMap"},
+		{"tokens", func(tu *ast.TranslationUnit) { tu.Cursors[2].Tokens.Len = 100 }, "cursor 2: tokens 6:100"},
+		{"type index", func(tu *ast.TranslationUnit) { tu.Cursors[3].TypeIndex = 1000 }, "cursor 3: TypeMap"},
+		{"typedef", func(tu *ast.TranslationUnit) {
+			tu.TypeMap.AddTypedef(ast.TypeTypedef{UnderlyingTypeIndex: 1000, TypeSpelling: "t"})
+		}, "Typedefs 0: Keys"},
+		{"pointer loop", func(tu *ast.TranslationUnit) {
+			n := tu.TypeMap.Len()
+			tu.TypeMap.Keys = append(tu.TypeMap.Keys,
+				ast.TypeKey{TypeKind: typekind.Pointer, TypeId: n + 1},
+				ast.TypeKey{TypeKind: typekind.Pointer, TypeId: n})
+		}, "leads back to itself"},
+		{"file", func(tu *ast.TranslationUnit) { tu.CursorLocs[1].FileId = 30 }, "CursorLocs 1: Files"},
+		{"not contiguous", func(tu *ast.TranslationUnit) {
+			// The children of the StructDecl on both sides of a child of
+			// the root, parents [-1 0 1 0 1].
+			tu.Cursors[2].ParentIndex = 1
+			tu.Cursors[3].ParentIndex = 0
+			tu.Cursors[4].ParentIndex = 1
+		}, "cursor 4: outside the children 2:2 of its parent 1"},
+		{"locations", func(tu *ast.TranslationUnit) { tu.TokenLocs = tu.TokenLocs[1:] }, "TokenLocs of length 8 for 9"},
+	}
+
+	// A cursor not seen when Referenced and Definition were populated is
+	// left as -1.
+	unseen := binaryTu(t)
+	unseen.Referenced[2] = -1
+	unseen.Definition[4] = -1
+	var buf bytes.Buffer
+	if err := unseen.EncodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got ast.TranslationUnit
+	if err := got.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		tu := binaryTu(t)
+		test.corrupt(tu)
+		for _, coder := range []struct {
+			name   string
+			encode func(*ast.TranslationUnit, io.Writer) error
+			decode func(*ast.TranslationUnit, io.Reader) error
+		}{
+			{"gob", (*ast.TranslationUnit).EncodeGobV1, (*ast.TranslationUnit).DecodeGobV1},
+			{"binary", (*ast.TranslationUnit).EncodeBinary, (*ast.TranslationUnit).DecodeBinary},
+		} {
+			var buf bytes.Buffer
+			if err := coder.encode(tu, &buf); err != nil {
+				t.Fatal(err)
+			}
+			var got ast.TranslationUnit
+			err := coder.decode(&got, &buf)
+			if _, ok := err.(*ast.TuErr); !ok {
+				t.Errorf("%s %s: got %v, expected a *TuErr", test.name, coder.name, err)
+				continue
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%s %s: got %v, expected %q in it", test.name, coder.name, err, test.want)
+			}
+		}
+	}
+}
+
+func TestSetBackChildren(t *testing.T) {
+	tu := binaryTu(t)
+	tu.Cursors = append(tu.Cursors, ast.Cursor{CursorKindId: cursorkind.Back, ParentIndex: 3})
+	tu.Back[5] = 1
+	tu.Back[4] = 1 // A TypeRef, not a Back cursor.
+	tu.Back[6] = 2
+
+	err := tu.SetBackChildren()
+	if _, ok := err.(*ast.TuErr); !ok {
+		t.Fatalf("got %v, expected a *TuErr", err)
+	}
+	msg := err.Error()
+	assertTrue(t, strings.Contains(msg, "Back 4:1: backId not leading to Back cursor"))
+	assertTrue(t, strings.Contains(msg, "Back 6:2: back out of range"))
+	// The entry in place is set all the same.
+	assertEqualInt(t, tu.Cursors[5].Children.Head, 1)
+	assertEqualInt(t, tu.Cursors[5].Children.Len, 1)
+
+	delete(tu.Back, 4)
+	delete(tu.Back, 6)
+	tu.Cursors[5].Children = ast.IndexPair{}
+	if err := tu.SetBackChildren(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -101,3 +101,33 @@ func second_gobtest(t *testing.T, testname string, tu *ast.TranslationUnit) int
 	}
 	return encodeLen
 }
+
+// third_binarytest uses the binary coder and decoder on entire TranslationUnit.
+func third_binarytest(t *testing.T, testname string, tu *ast.TranslationUnit) int {
+
+	var buf bytes.Buffer
+
+	err := tu.EncodeBinary(&buf)
+	if err != nil {
+		t.Fatalf("encode error: %s %s", testname, err)
+	}
+	encodeLen := buf.Len()
+
+	var tu2 ast.TranslationUnit
+	err = tu2.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode error: %s %s", testname, err)
+	}
+
+	if eq_err := tu.AssertEqual(&tu2); eq_err != nil {
+		t.Errorf("%s: not equal: %s\n", testname, eq_err)
+	}
+	// AssertEqual leaves the cursors and the token ids out.
+	before := fmt.Sprintf("%#v\n%#v\n", tu.Cursors, tu.TokenIds)
+	after := fmt.Sprintf("%#v\n%#v\n", tu2.Cursors, tu2.TokenIds)
+	if before != after {
+		t.Errorf("%s:\n=== before ===\n%s\n=== after ===\n%s\n",
+			testname, before, after)
+	}
+	return encodeLen
+}
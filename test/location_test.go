@@ -0,0 +1,141 @@
+package clang_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/astbridge"
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+func TestPopulateLocations(t *testing.T) {
+	tmpfilename := "sample.c"
+	buffers := []clang.UnsavedFile{
+		clang.NewUnsavedFile(tmpfilename, "#define ZERO 0\nint world() {\n  return ZERO;\n}\n"),
+	}
+
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	tu := idx.ParseTranslationUnit(tmpfilename, nil, buffers, clang.TranslationUnit_DetailedPreprocessingRecord)
+	assertTrue(t, tu.IsValid())
+	defer tu.Dispose()
+
+	ctu := astbridge.ClangTranslationUnit{}
+	if err := ctu.Populate(&tu, nil); err != nil {
+		t.Fatal(err)
+	}
+	gotu := &ctu.GoTu
+	assertEqualInt(t, len(gotu.CursorLocs), len(gotu.Cursors))
+	assertEqualInt(t, len(gotu.TokenLocs), len(gotu.TokenIds))
+	assertTrue(t, !gotu.CursorLocation(0).IsValid())
+
+	found := false
+	for id, c := range gotu.Cursors {
+		if c.CursorKindId != cursorkind.FunctionDecl {
+			continue
+		}
+		found = true
+		l := gotu.CursorLocation(id)
+		assertEqualString(t, gotu.Position(l), "sample.c:2:5")
+		assertEqualInt(t, l.Offset, len("#define ZERO 0\nint "))
+
+		// The first token of the function is its int.
+		tl := gotu.TokenLocation(c.Tokens.Head)
+		assertEqualString(t, gotu.Position(tl), "sample.c:2:1")
+	}
+	assertTrue(t, found)
+
+	// The 0 out of ZERO is located where ZERO is expanded, for its spelling
+	// too: libclang gives the file location for it, not the definition of
+	// the macro. The tokens are not expanded at all.
+	found = false
+	for id, c := range gotu.Cursors {
+		if c.CursorKindId != cursorkind.IntegerLiteral {
+			continue
+		}
+		found = true
+		assertEqualString(t, gotu.Position(gotu.CursorLocation(id)), "sample.c:3:10")
+		assertEqualString(t, gotu.Position(gotu.CursorSpellingLocation(id)), "sample.c:3:10")
+	}
+	assertTrue(t, found)
+	assertEqualInt(t, len(gotu.CursorSpellings), 0)
+	assertEqualInt(t, len(gotu.TokenSpellings), 0)
+
+	var buf bytes.Buffer
+	if err := gotu.EncodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tu2 ast.TranslationUnit
+	if err := tu2.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotu.AssertEqual(&tu2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocationsGobV1(t *testing.T) {
+	tu := &ast.TranslationUnit{
+		Cursors: []ast.Cursor{
+			{CursorKindId: cursorkind.TranslationUnit, ParentIndex: -1},
+			{CursorKindId: cursorkind.VarDecl, ParentIndex: 0},
+			{CursorKindId: cursorkind.IntegerLiteral, ParentIndex: 1},
+		},
+		TokenIds: []ast.TokenId{0, 1},
+	}
+	tu.CursorLocs = []ast.Location{
+		{},
+		tu.AddLocation("a.c", 24, 2, 5),
+		tu.AddLocation("a.c", 30, 2, 11),
+	}
+	tu.CursorSpellings = map[int]ast.Location{
+		2: tu.AddLocation("a.h", 15, 1, 14),
+	}
+	tu.TokenLocs = []ast.Location{
+		tu.AddLocation("a.c", 30, 2, 11),
+		tu.AddLocation("a.c", 8, 1, 9),
+	}
+
+	assertEqualString(t, tu.Position(tu.CursorLocation(2)), "a.c:2:11")
+	assertEqualString(t, tu.Position(tu.CursorSpellingLocation(2)), "a.h:1:14")
+	assertEqualString(t, tu.Position(tu.CursorSpellingLocation(1)), "a.c:2:5")
+	assertEqualString(t, tu.Position(tu.CursorLocation(3)), "-")
+	assertEqualString(t, tu.FileName(tu.TokenSpellingLocation(1)), "a.c")
+
+	var buf bytes.Buffer
+	if err := tu.EncodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tu2 ast.TranslationUnit
+	if err := tu2.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.AssertEqual(&tu2); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualString(t, tu2.Position(tu2.CursorSpellingLocation(2)), "a.h:1:14")
+
+	// A stream encoded before locations were recorded decodes without them.
+	buf.Reset()
+	enc := gob.NewEncoder(&buf)
+	for _, v := range []interface{}{
+		[]int{int(cursorkind.TranslationUnit)}, []int{0}, []int{-1}, []int{0}, []int{0}, []int{0},
+		tu.TokenIds, tu.CursorNameMap, tu.TokenMap, tu.TokenNameMap, tu.TypeMap,
+		map[int]int{}, map[int]int{}, map[int]int{},
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var old ast.TranslationUnit
+	if err := old.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualInt(t, len(old.Cursors), 1)
+	assertEqualInt(t, len(old.CursorLocs), 0)
+	assertTrue(t, !old.CursorLocation(0).IsValid())
+}
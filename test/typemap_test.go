@@ -0,0 +1,356 @@
+package clang_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/astbridge"
+	"github.com/frankreh/go-clang/clang"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+	"github.com/frankreh/go-clang/clang/nullability"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+func TestTypeMapKinds(t *testing.T) {
+	tu := &ast.TranslationUnit{
+		Cursors: []ast.Cursor{
+			{CursorKindId: cursorkind.TranslationUnit, ParentIndex: -1},
+		},
+	}
+	tm := &tu.TypeMap
+	tm.Init()
+
+	must := func(i int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	intType := must(tm.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Int}, Align: 4, Size: 4, TypeSpelling: "int"}))
+	doubleType := must(tm.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Double}, Align: 8, Size: 8, TypeSpelling: "double"}))
+	recordType := must(tm.AddRecord(ast.TypeRecord{Align: 4, Size: 4, TypeSpelling: "S"}))
+	intPointer := must(tm.AddPointer(ast.TypePointer{UnderlyingTypeIndex: intType}))
+
+	tests := []struct {
+		index    int
+		kind     typekind.Kind
+		spelling string
+	}{
+		{must(tm.AddIncompleteArray(ast.TypeIncompleteArray{TypeVariableArray: ast.TypeVariableArray{ElemTypeId: intType, Align: 4, TypeSpelling: "int []"}})), typekind.IncompleteArray, "int []"},
+		{must(tm.AddDependentSizedArray(ast.TypeDependentSizedArray{TypeVariableArray: ast.TypeVariableArray{ElemTypeId: intType, TypeSpelling: "int [N]"}})), typekind.DependentSizedArray, "int [N]"},
+		{must(tm.AddReference(ast.TypeReference{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.LValueReference}, UnderlyingTypeIndex: intType})), typekind.LValueReference, "int &"},
+		{must(tm.AddReference(ast.TypeReference{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.RValueReference}, UnderlyingTypeIndex: recordType})), typekind.RValueReference, "S &&"},
+		{must(tm.AddMemberPointer(ast.TypeMemberPointer{PointeeTypeId: intType, ClassTypeId: recordType, Align: 8, Size: 8, TypeSpelling: "int S::*"})), typekind.MemberPointer, "int S::*"},
+		{must(tm.AddBlockPointer(ast.TypeBlockPointer{UnderlyingTypeIndex: intType})), typekind.BlockPointer, "int ^"},
+		{must(tm.AddVector(ast.TypeVector{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Vector}, ElemTypeId: intType, ElemCount: 4, Align: 16, Size: 16, TypeSpelling: "int __attribute__((ext_vector_type(4)))"})), typekind.Vector, "int __attribute__((ext_vector_type(4)))"},
+		{must(tm.AddVector(ast.TypeVector{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Complex}, ElemTypeId: doubleType, ElemCount: 2, Align: 8, Size: 16, TypeSpelling: "_Complex double"})), typekind.Complex, "_Complex double"},
+		{must(tm.AddAuto(ast.TypeAuto{DeducedTypeId: intType, Align: 4, Size: 4, TypeSpelling: "auto"})), typekind.Auto, "auto"},
+		{must(tm.AddAttributed(ast.TypeAttributed{ModifiedTypeId: intPointer, Nullability: nullability.NonNull, Align: 8, Size: 8, TypeSpelling: "int * _Nonnull"})), typekind.Attributed, "int * _Nonnull"},
+		{must(tm.AddTemplateSpecialization(ast.TypeTemplateSpecialization{CanonicalTypeId: recordType, ArgTypeIds: []int{intType, -1}, Align: 4, Size: 4, TypeSpelling: "A<int, 3>"})), typekind.Unexposed, "A<int, 3>"},
+		{must(tm.AddAtomic(ast.TypeAtomic{UnderlyingTypeIndex: intType})), typekind.Atomic, "_Atomic(int)"},
+		{must(tm.AddObjC(ast.TypeObjC{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.ObjCInterface}, BaseTypeId: -1, TypeSpelling: "NSArray"})), typekind.ObjCInterface, "NSArray"},
+		{must(tm.AddObjC(ast.TypeObjC{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.ObjCObject}, BaseTypeId: tm.Len() - 1, TypeArgIds: []int{intPointer}, Protocols: []string{"NSCopying"}, TypeSpelling: "NSArray<int *><NSCopying>"})), typekind.ObjCObject, "NSArray<int *><NSCopying>"},
+		{must(tm.AddObjCObjectPointer(ast.TypeObjCObjectPointer{UnderlyingTypeIndex: tm.Len() - 1})), typekind.ObjCObjectPointer, "NSArray<int *><NSCopying> *"},
+	}
+	for _, test := range tests {
+		typ, err := tm.Type(test.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ == nil || typ.Kind() != test.kind {
+			t.Errorf("%s: got type %#v", test.spelling, typ)
+		}
+		assertEqualString(t, tm.Spelling(test.index), test.spelling)
+	}
+
+	// The Unexposed key without an index still has no type.
+	typ, err := tm.Type(tm.MustAutoKeyIndex(typekind.Unexposed))
+	assertTrue(t, typ == nil && err == nil)
+
+	if _, err := tm.AddVector(ast.TypeVector{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Int}}); err == nil {
+		t.Errorf("AddVector of an Int")
+	}
+	if _, err := tm.AddReference(ast.TypeReference{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.LValueReference}, UnderlyingTypeIndex: tm.Len()}); err == nil {
+		t.Errorf("AddReference out of range")
+	}
+
+	var buf bytes.Buffer
+	if err := tu.EncodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tu2 ast.TranslationUnit
+	if err := tu2.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.AssertEqual(&tu2); err != nil {
+		t.Fatal(err)
+	}
+
+	tu2.TypeMap.ObjCs[1].Protocols[0] = "NSCoding"
+	if err := tu.TypeMap.AssertEqual(&tu2.TypeMap); err == nil {
+		t.Errorf("AssertEqual missed a changed protocol")
+	}
+}
+
+func TestPopulateCppTypes(t *testing.T) {
+	tmpfilename := "sample.cpp"
+	buffers := []clang.UnsavedFile{
+		clang.NewUnsavedFile(tmpfilename, `
+struct S { int m; };
+template <typename T, int N> struct A { T t[N]; };
+extern int incomplete[];
+int &lref = incomplete[0];
+S &&rref = S();
+int S::*member = &S::m;
+A<int, 3> spec;
+auto deduced = 1.0;
+_Complex double z;
+typedef int v4 __attribute__((ext_vector_type(4)));
+v4 vec;
+`),
+	}
+
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	tu := idx.ParseTranslationUnit(tmpfilename, nil, buffers, 0)
+	assertTrue(t, tu.IsValid())
+	defer tu.Dispose()
+
+	ctu := astbridge.ClangTranslationUnit{}
+	if err := ctu.Populate(&tu, nil); err != nil {
+		t.Fatal(err)
+	}
+	gotu := &ctu.GoTu
+
+	kinds := make(map[string]typekind.Kind)
+	spellings := make(map[string]string)
+	for _, c := range gotu.Cursors {
+		if c.CursorKindId != cursorkind.VarDecl {
+			continue
+		}
+		typ, err := gotu.TypeMap.Type(c.TypeIndex)
+		if err != nil || typ == nil {
+			t.Fatalf("%s: type %d %v", gotu.CursorNameMap.ToString(c.CursorNameId), c.TypeIndex, err)
+		}
+		kinds[gotu.CursorNameMap.ToString(c.CursorNameId)] = typ.Kind()
+		spellings[gotu.CursorNameMap.ToString(c.CursorNameId)] = gotu.TypeMap.Spelling(c.TypeIndex)
+	}
+	for name, kind := range map[string]typekind.Kind{
+		"incomplete": typekind.IncompleteArray,
+		"lref":       typekind.LValueReference,
+		"rref":       typekind.RValueReference,
+		"member":     typekind.MemberPointer,
+		"deduced":    typekind.Auto,
+		"z":          typekind.Complex,
+		"vec":        typekind.Typedef,
+	} {
+		if kinds[name] != kind {
+			t.Errorf("%s: got %s, expected %s", name, kinds[name], kind)
+		}
+	}
+
+	// Depending on the version of clang, the specialization may be elaborated.
+	assertEqualString(t, spellings["spec"], "A<int, 3>")
+
+	var buf bytes.Buffer
+	if err := gotu.EncodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tu2 ast.TranslationUnit
+	if err := tu2.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotu.AssertEqual(&tu2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypeMapQualifiersAndFields(t *testing.T) {
+	tu := &ast.TranslationUnit{
+		Cursors: []ast.Cursor{
+			{CursorKindId: cursorkind.TranslationUnit, ParentIndex: -1},
+		},
+	}
+	tm := &tu.TypeMap
+	tm.Init()
+
+	must := func(i int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	intType := must(tm.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Int}, Align: 4, Size: 4, TypeSpelling: "int"}))
+	charType := must(tm.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Char_S}, Align: 1, Size: 1, TypeSpelling: "char"}))
+	constChar := must(tm.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: typekind.Char_S}, Align: 1, Size: 1, TypeSpelling: "const char"}))
+	if err := tm.Qualify(constChar, ast.QualConst); err != nil {
+		t.Fatal(err)
+	}
+	record := must(tm.AddRecord(ast.TypeRecord{Align: 8, Size: 16, TypeSpelling: "struct node"}))
+	elaborated := must(tm.AddElaborated(ast.TypeElaborated{UnderlyingTypeIndex: record}))
+	constElaborated := must(tm.AddElaborated(ast.TypeElaborated{UnderlyingTypeIndex: record}))
+	if err := tm.Qualify(constElaborated, ast.QualConst|ast.QualVolatile); err != nil {
+		t.Fatal(err)
+	}
+	next := must(tm.AddPointer(ast.TypePointer{UnderlyingTypeIndex: elaborated}))
+	restrictPointer := must(tm.AddPointer(ast.TypePointer{UnderlyingTypeIndex: constChar}))
+	if err := tm.Qualify(restrictPointer, ast.QualRestrict); err != nil {
+		t.Fatal(err)
+	}
+	function := must(tm.AddFunction(ast.TypeFunction{
+		TypeKindKind: ast.TypeKindKind{TypeKind: typekind.FunctionProto},
+		ResultTypeId: intType,
+		ArgIds:       []int{restrictPointer},
+		TypeSpelling: "int (const char *restrict, ...)",
+		Variadic:     true,
+	}))
+	constFunctionPointer := must(tm.AddPointer(ast.TypePointer{UnderlyingTypeIndex: function}))
+	if err := tm.Qualify(constFunctionPointer, ast.QualConst); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := []ast.TypeField{
+		{Name: "next", TypeId: next, Offset: 0, BitWidth: -1},
+		{Name: "flag", TypeId: charType, Offset: 64, BitWidth: 1},
+	}
+	if err := tm.SetFields(record, fields); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.SetFields(intType, fields); err == nil {
+		t.Errorf("SetFields of an int")
+	}
+	if err := tm.SetFields(record, []ast.TypeField{{Name: "bad", TypeId: tm.Len()}}); err == nil {
+		t.Errorf("SetFields with a field type out of range")
+	}
+	if err := tm.Qualify(1, ast.QualConst); err == nil {
+		t.Errorf("Qualify of the Unexposed key")
+	}
+
+	tests := []struct {
+		index    int
+		spelling string
+	}{
+		{constChar, "const char"},
+		{elaborated, "struct node"},
+		{constElaborated, "const volatile struct node"},
+		{next, "struct node *"},
+		{restrictPointer, "const char *restrict"},
+		{constFunctionPointer, "int (*const)(const char *restrict, ...)"},
+	}
+	for _, test := range tests {
+		assertEqualString(t, tm.Spelling(test.index), test.spelling)
+	}
+	assertEqualString(t, tm.Qualifiers(constElaborated).String(), "const volatile")
+	assertEqualInt(t, int(tm.Qualifiers(elaborated)), 0)
+
+	// The keys print as they did before they had qualifiers, but for those
+	// that have some.
+	assertEqualString(t, fmt.Sprintf("%v", tm.Keys[:2]), "[{Invalid -1} {Unexposed -1}]")
+	assertEqualString(t, fmt.Sprintf("%v", tm.Keys[constElaborated]), fmt.Sprintf("{Elaborated %d const volatile}", tm.Keys[constElaborated].TypeId))
+
+	var buf bytes.Buffer
+	if err := tu.EncodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tu2 ast.TranslationUnit
+	if err := tu2.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.AssertEqual(&tu2); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualInt(t, len(tu2.TypeMap.Records[0].Fields), 2)
+	assertEqualInt(t, int(tu2.TypeMap.Qualifiers(restrictPointer)), int(ast.QualRestrict))
+
+	tu2.TypeMap.Records[0].Fields[1].BitWidth = 2
+	if err := tu.TypeMap.AssertEqual(&tu2.TypeMap); err == nil {
+		t.Errorf("AssertEqual missed a changed field")
+	}
+	tu2.TypeMap.Records[0].Fields[1].BitWidth = 1
+	tu2.TypeMap.Functions[0].Variadic = false
+	if err := tu.TypeMap.AssertEqual(&tu2.TypeMap); err == nil {
+		t.Errorf("AssertEqual missed a changed function")
+	}
+}
+
+func TestPopulateRecordFields(t *testing.T) {
+	tmpfilename := "sample.c"
+	buffers := []clang.UnsavedFile{
+		clang.NewUnsavedFile(tmpfilename, `
+struct node {
+	struct node *next;
+	unsigned flag : 1;
+	const volatile int count;
+};
+int print(const char *restrict format, ...);
+`),
+	}
+
+	idx := clang.NewIndex(0, 0)
+	defer idx.Dispose()
+
+	tu := idx.ParseTranslationUnit(tmpfilename, nil, buffers, 0)
+	assertTrue(t, tu.IsValid())
+	defer tu.Dispose()
+
+	ctu := astbridge.ClangTranslationUnit{}
+	if err := ctu.Populate(&tu, nil); err != nil {
+		t.Fatal(err)
+	}
+	gotu := &ctu.GoTu
+	tm := &gotu.TypeMap
+
+	var record *ast.TypeRecord
+	for i := range tm.Records {
+		if tm.Records[i].TypeSpelling == "struct node" {
+			record = &tm.Records[i]
+		}
+	}
+	if record == nil {
+		t.Fatalf("no struct node in %#v", *tm)
+	}
+	assertEqualInt(t, len(record.Fields), 3)
+	assertEqualString(t, record.Fields[0].Name, "next")
+	assertEqualString(t, tm.Spelling(record.Fields[0].TypeId), "struct node *")
+	assertEqualInt(t, record.Fields[0].BitWidth, -1)
+	assertEqualString(t, record.Fields[1].Name, "flag")
+	assertEqualInt(t, record.Fields[1].Offset, 64)
+	assertEqualInt(t, record.Fields[1].BitWidth, 1)
+	assertEqualInt(t, int(tm.Qualifiers(record.Fields[2].TypeId)), int(ast.QualConst|ast.QualVolatile))
+
+	for id, c := range gotu.Cursors {
+		if c.CursorKindId != cursorkind.FunctionDecl {
+			continue
+		}
+		typ, err := tm.Type(c.TypeIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, ok := typ.(*ast.TypeFunction)
+		if !ok {
+			t.Fatalf("print has type %#v", typ)
+		}
+		assertTrue(t, f.Variadic)
+		assertEqualInt(t, f.CallingConv, int(clang.CallingConv_C))
+		assertEqualInt(t, int(tm.Qualifiers(f.ArgIds[0])), int(ast.QualRestrict))
+		assertEqualString(t, tm.Spelling(f.ArgIds[0]), "const char *restrict")
+		assertEqualString(t, fmt.Sprint(gotu.ParamNames(id)), "[format]")
+	}
+
+	var buf bytes.Buffer
+	if err := gotu.EncodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tu2 ast.TranslationUnit
+	if err := tu2.DecodeGobV1(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotu.AssertEqual(&tu2); err != nil {
+		t.Fatal(err)
+	}
+}
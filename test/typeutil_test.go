@@ -0,0 +1,152 @@
+package clang_test
+
+import (
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/clang/typekind"
+)
+
+func TestTypeResolution(t *testing.T) {
+	var tm ast.TypeMap
+	tm.Init()
+
+	must := func(i int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	qualify := func(i int, q ast.Qualifiers) int {
+		t.Helper()
+		if err := tm.Qualify(i, q); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	intrinsic := func(k typekind.Kind, size int, spelling string) int {
+		return must(tm.AddIntrinsic(ast.TypeIntrinsic{TypeKindKind: ast.TypeKindKind{TypeKind: k}, Align: size, Size: size, TypeSpelling: spelling}))
+	}
+	pointer := func(i int) int {
+		return must(tm.AddPointer(ast.TypePointer{UnderlyingTypeIndex: i}))
+	}
+
+	intType := intrinsic(typekind.Int, 4, "int")
+	charType := intrinsic(typekind.Char_S, 1, "char")
+	ulong := intrinsic(typekind.ULong, 8, "unsigned long")
+	double := intrinsic(typekind.Double, 8, "double")
+	sizeT := must(tm.AddTypedef(ast.TypeTypedef{UnderlyingTypeIndex: ulong, TypeSpelling: "size_t"}))
+	constSizeT := qualify(must(tm.AddTypedef(ast.TypeTypedef{UnderlyingTypeIndex: ulong, TypeSpelling: "const size_t"})), ast.QualConst)
+	myInt := must(tm.AddTypedef(ast.TypeTypedef{UnderlyingTypeIndex: sizeT, TypeSpelling: "my_size"}))
+	enum := must(tm.AddEnum(ast.TypeEnum{Align: 4, Size: 4, TypeSpelling: "enum color"}))
+	record := must(tm.AddRecord(ast.TypeRecord{Align: 8, Size: 8, TypeSpelling: "struct s"}))
+	elaborated := must(tm.AddElaborated(ast.TypeElaborated{UnderlyingTypeIndex: record}))
+	volatileElaborated := qualify(must(tm.AddElaborated(ast.TypeElaborated{UnderlyingTypeIndex: record})), ast.QualVolatile)
+
+	charPointer := pointer(charType)
+	charPointerPointer := pointer(charPointer)
+	constCharPointer := qualify(pointer(charType), ast.QualConst)
+	pointerToConstPointer := pointer(constCharPointer)
+	stringType := must(tm.AddTypedef(ast.TypeTypedef{UnderlyingTypeIndex: charPointer, TypeSpelling: "string"}))
+	stringPointer := pointer(stringType)
+
+	function := must(tm.AddFunction(ast.TypeFunction{
+		TypeKindKind: ast.TypeKindKind{TypeKind: typekind.FunctionProto},
+		ResultTypeId: intType,
+		ArgIds:       []int{charPointer},
+		TypeSpelling: "int (char *)",
+	}))
+	functionPointer := pointer(function)
+	voidFunction := must(tm.AddFunction(ast.TypeFunction{
+		TypeKindKind: ast.TypeKindKind{TypeKind: typekind.FunctionProto},
+		ResultTypeId: functionPointer,
+		TypeSpelling: "int (*(void))(char *)",
+	}))
+	variadic := must(tm.AddFunction(ast.TypeFunction{
+		TypeKindKind: ast.TypeKindKind{TypeKind: typekind.FunctionProto},
+		ResultTypeId: intType,
+		ArgIds:       []int{constCharPointer},
+		TypeSpelling: "int (char *const, ...)",
+		Variadic:     true,
+	}))
+	noProto := must(tm.AddFunction(ast.TypeFunction{
+		TypeKindKind: ast.TypeKindKind{TypeKind: typekind.FunctionNoProto},
+		ResultTypeId: double,
+		TypeSpelling: "double ()",
+	}))
+
+	array := must(tm.AddConstantArray(ast.TypeConstantArray{ElemCount: 4, TypeVariableArray: ast.TypeVariableArray{ElemTypeId: charPointer, Align: 8, Size: 32, TypeSpelling: "char *[4]"}}))
+	arrayPointer := pointer(must(tm.AddConstantArray(ast.TypeConstantArray{ElemCount: 3, TypeVariableArray: ast.TypeVariableArray{ElemTypeId: intType, Align: 4, Size: 12, TypeSpelling: "int [3]"}})))
+	variableArray := must(tm.AddVariableArray(ast.TypeVariableArray{ElemTypeId: array, Align: 8, TypeSpelling: "char *[n][4]"}))
+	incomplete := must(tm.AddIncompleteArray(ast.TypeIncompleteArray{TypeVariableArray: ast.TypeVariableArray{ElemTypeId: intType, Align: 4, TypeSpelling: "int []"}}))
+
+	tests := []struct {
+		name      string
+		index     int
+		canonical int
+		quals     ast.Qualifiers
+		depth     int
+		integer   bool
+		float     bool
+		pointer   bool
+		record    bool
+		decl      string
+	}{
+		{"int", intType, intType, 0, 0, true, false, false, false, "int x"},
+		{"size_t", sizeT, ulong, 0, 0, true, false, false, false, "size_t x"},
+		{"const size_t", constSizeT, ulong, ast.QualConst, 0, true, false, false, false, "const size_t x"},
+		{"chain", myInt, ulong, 0, 0, true, false, false, false, "my_size x"},
+		{"enum", enum, enum, 0, 0, true, false, false, false, "enum color x"},
+		{"double", double, double, 0, 0, false, true, false, false, "double x"},
+		{"elaborated", elaborated, record, 0, 0, false, false, false, true, "struct s x"},
+		{"volatile elaborated", volatileElaborated, record, ast.QualVolatile, 0, false, false, false, true, "volatile struct s x"},
+		{"char **", charPointerPointer, charPointerPointer, 0, 2, false, false, true, false, "char **x"},
+		{"char *const", constCharPointer, constCharPointer, ast.QualConst, 1, false, false, true, false, "char *const x"},
+		{"char *const *", pointerToConstPointer, pointerToConstPointer, 0, 2, false, false, true, false, "char *const *x"},
+		{"string *", stringPointer, stringPointer, 0, 2, false, false, true, false, "string *x"},
+		{"function pointer", functionPointer, functionPointer, 0, 1, false, false, true, false, "int (*x)(char *)"},
+		{"function", voidFunction, voidFunction, 0, 0, false, false, false, false, "int (*x(void))(char *)"},
+		{"variadic", variadic, variadic, 0, 0, false, false, false, false, "int x(char *const, ...)"},
+		{"no proto", noProto, noProto, 0, 0, false, false, false, false, "double x()"},
+		{"array", array, array, 0, 0, false, false, false, false, "char *x[4]"},
+		{"array pointer", arrayPointer, arrayPointer, 0, 1, false, false, true, false, "int (*x)[3]"},
+		{"variable array", variableArray, variableArray, 0, 0, false, false, false, false, "char *x[n][4]"},
+		{"incomplete", incomplete, incomplete, 0, 0, false, false, false, false, "int x[]"},
+		{"out of range", tm.Len(), tm.Len(), 0, 0, false, false, false, false, "x"},
+	}
+	for _, test := range tests {
+		c, q := tm.Strip(test.index)
+		if c != test.canonical || q != test.quals {
+			t.Errorf("%s: Strip got %d %q, expected %d %q", test.name, c, q, test.canonical, test.quals)
+		}
+		if got := tm.Canonical(test.index); got != test.canonical {
+			t.Errorf("%s: Canonical got %d, expected %d", test.name, got, test.canonical)
+		}
+		if got := tm.PointerDepth(test.index); got != test.depth {
+			t.Errorf("%s: PointerDepth got %d, expected %d", test.name, got, test.depth)
+		}
+		if tm.IsInteger(test.index) != test.integer ||
+			tm.IsFloat(test.index) != test.float ||
+			tm.IsPointer(test.index) != test.pointer ||
+			tm.IsRecord(test.index) != test.record {
+			t.Errorf("%s: wrong category", test.name)
+		}
+		if got := tm.Declarator(test.index, "x"); got != test.decl {
+			t.Errorf("%s: Declarator got %q, expected %q", test.name, got, test.decl)
+		}
+	}
+
+	assertEqualString(t, tm.Declarator(charPointer, ""), "char *")
+	assertEqualString(t, tm.Declarator(functionPointer, ""), "int (*)(char *)")
+	assertEqualInt(t, tm.Desugar(myInt), sizeT)
+	assertEqualInt(t, tm.Desugar(intType), -1)
+	assertEqualInt(t, tm.Pointee(stringPointer), stringType)
+	assertEqualInt(t, tm.Pointee(stringType), charType)
+	assertEqualInt(t, tm.Pointee(intType), -1)
+
+	// Typedefs leading to each other do not loop.
+	loop := must(tm.AddTypedef(ast.TypeTypedef{UnderlyingTypeIndex: tm.Len() + 1, TypeSpelling: "loop1"}))
+	must(tm.AddTypedef(ast.TypeTypedef{UnderlyingTypeIndex: loop, TypeSpelling: "loop2"}))
+	assertTrue(t, !tm.IsInteger(loop))
+}
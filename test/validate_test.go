@@ -0,0 +1,94 @@
+package clang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+)
+
+// validTu returns binaryTu, with a Back cursor, the way Populate lays one
+// out: the Children of each cursor set, following each other.
+func validTu(t *testing.T) *ast.TranslationUnit {
+	t.Helper()
+	tu := backTu(t)
+	for j := 1; j < len(tu.Cursors); j++ {
+		c := &tu.Cursors[tu.Cursors[j].ParentIndex].Children
+		if c.Len == 0 {
+			c.Head = j
+		}
+		c.Len++
+	}
+	return tu
+}
+
+func TestValidate(t *testing.T) {
+	tu := validTu(t)
+	if err := tu.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// As it is once SetBackChildren has had the Back cursor lead on.
+	if err := tu.SetBackChildren(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(tu *ast.TranslationUnit)
+		want    []string
+	}{
+		{"kind", func(tu *ast.TranslationUnit) { tu.Cursors[2].CursorKindId = 12345 }, []string{"cursor 2: kind 12345"}},
+		{"parent", func(tu *ast.TranslationUnit) { tu.Cursors[4].ParentIndex = 1 }, []string{
+			"cursor 1: 2 children, not 1",
+			"cursor 2: 0 children, not 1",
+			"cursor 2: child 4 has parent 1",
+			"cursor 4: outside the children 3:1 of its parent 1",
+		}},
+		{"children count", func(tu *ast.TranslationUnit) { tu.Cursors[0].Children.Len = 1 }, []string{
+			"cursor 0: 2 children, not 1",
+			"cursor 2: outside the children 1:1 of its parent 0",
+		}},
+		{"children range", func(tu *ast.TranslationUnit) { tu.Cursors[3].Children.Head = 6 }, []string{"cursor 3: children 6:1 out of range"}},
+		{"children before", func(tu *ast.TranslationUnit) { tu.Cursors[2].Children.Head = 1 }, []string{"cursor 2: children 1:1 out of range"}},
+		{"not contiguous", func(tu *ast.TranslationUnit) {
+			// The children of the root counted right, but from the VarDecl.
+			tu.Cursors[0].Children = ast.IndexPair{Head: 2, Len: 2}
+		}, []string{"cursor 0: child 3 has parent 1", "cursor 1: outside the children 2:2 of its parent 0"}},
+		{"tokens", func(tu *ast.TranslationUnit) { tu.Cursors[4].Tokens.Len = 9 }, []string{"cursor 4: tokens 6:9 out of TokenIds 9"}},
+		{"token id", func(tu *ast.TranslationUnit) { tu.TokenIds[8] = 40 }, []string{"TokenIds 8: TokenMap"}},
+		{"no tokens", func(tu *ast.TranslationUnit) { tu.TokenMap = ast.TokenMap{} }, []string{"TokenIds 0: TokenMap"}},
+		{"token name", func(tu *ast.TranslationUnit) { tu.TokenMap.Tokens[1].TokenNameId = 40 }, []string{"token 1: TokenNameMap"}},
+		{"name", func(tu *ast.TranslationUnit) { tu.Cursors[2].CursorNameId = -3 }, []string{"cursor 2: CursorNameMap"}},
+		{"no names", func(tu *ast.TranslationUnit) { tu.CursorNameMap = ast.StringMap{} }, []string{"cursor 0: CursorNameMap"}},
+		{"type", func(tu *ast.TranslationUnit) { tu.Cursors[2].TypeIndex = 50 }, []string{"cursor 2: TypeMap"}},
+		{"type arg", func(tu *ast.TranslationUnit) { tu.TypeMap.Functions[0].ArgIds[0] = 50 }, []string{"Functions 0: Keys"}},
+		{"back entry", func(tu *ast.TranslationUnit) { delete(tu.Back, 5) }, []string{"cursor 5: Back cursor not in the Back map"}},
+		{"back forward", func(tu *ast.TranslationUnit) { tu.Back[5] = 5 }, []string{"Back 5:5: not leading back"}},
+		{"back from", func(tu *ast.TranslationUnit) { tu.Back[4] = 2 }, []string{"Back 4:2: not from a Back cursor"}},
+		{"back children", func(tu *ast.TranslationUnit) { tu.Cursors[5].Children = ast.IndexPair{Head: 2, Len: 1} }, []string{"cursor 5: Back cursor with children 2:1, not leading to 1"}},
+		{"referenced back", func(tu *ast.TranslationUnit) { tu.Referenced[4] = 5 }, []string{"Referenced 4:5: leading to a Back cursor"}},
+		{"definition range", func(tu *ast.TranslationUnit) { tu.Definition[1] = 7 }, []string{"Definition 1:7: cursors out of range"}},
+		{"all at once", func(tu *ast.TranslationUnit) {
+			tu.Cursors[2].CursorKindId = 12345
+			tu.Cursors[4].Tokens.Len = 9
+			tu.Referenced[4] = 5
+		}, []string{"cursor 2: kind 12345", "cursor 4: tokens 6:9", "Referenced 4:5"}},
+	}
+	for _, test := range tests {
+		tu := validTu(t)
+		test.corrupt(tu)
+		err := tu.Validate()
+		if _, ok := err.(*ast.TuErr); !ok {
+			t.Errorf("%s: got %v, expected a *TuErr", test.name, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: got %v, expected %q in it", test.name, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package clang_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/frankreh/go-clang/ast"
+	"github.com/frankreh/go-clang/clang/cursorkind"
+)
+
+// walkTu returns a small tree of cursors, with a Back cursor, 5, leading to
+// the ParmDecl 3:
+//
+//	0 TranslationUnit
+//	  1 FunctionDecl
+//	    3 ParmDecl
+//	    4 CompoundStmt
+//	      6 CallExpr
+//	      7 CallExpr
+//	        8 DeclRefExpr
+//	  2 VarDecl
+//	    5 Back
+func walkTu() *ast.TranslationUnit {
+	return &ast.TranslationUnit{
+		Cursors: []ast.Cursor{
+			{CursorKindId: cursorkind.TranslationUnit, ParentIndex: -1, Children: ast.IndexPair{Head: 1, Len: 2}},
+			{CursorKindId: cursorkind.FunctionDecl, ParentIndex: 0, Children: ast.IndexPair{Head: 3, Len: 2}},
+			{CursorKindId: cursorkind.VarDecl, ParentIndex: 0, Children: ast.IndexPair{Head: 5, Len: 1}},
+			{CursorKindId: cursorkind.ParmDecl, ParentIndex: 1},
+			{CursorKindId: cursorkind.CompoundStmt, ParentIndex: 1, Children: ast.IndexPair{Head: 6, Len: 2}},
+			{CursorKindId: cursorkind.Back, ParentIndex: 2},
+			{CursorKindId: cursorkind.CallExpr, ParentIndex: 4},
+			{CursorKindId: cursorkind.CallExpr, ParentIndex: 4, Children: ast.IndexPair{Head: 8, Len: 1}},
+			{CursorKindId: cursorkind.DeclRefExpr, ParentIndex: 7},
+		},
+		Back: map[int]int{5: 3},
+	}
+}
+
+func TestWalk(t *testing.T) {
+	tu := walkTu()
+
+	tests := []struct {
+		name   string
+		root   int
+		back   ast.BackLinks
+		skip   int // Cursor whose children are skipped.
+		stop   int // Cursor whose entering stops the walk.
+		expect string
+	}{
+		{"all", 0, ast.IgnoreBack, -1, -1, "+0 +1 +3 -3 +4 +6 -6 +7 +8 -8 -7 -4 -1 +2 -2 -0"},
+		{"keep", 2, ast.KeepBack, -1, -1, "+2 +5 -5 -2"},
+		{"follow", 2, ast.FollowBack, -1, -1, "+2 +3 -3 -2"},
+		{"follow root", 5, ast.FollowBack, -1, -1, "+3 -3"},
+		{"ignore root", 5, ast.IgnoreBack, -1, -1, ""},
+		{"skip", 1, ast.IgnoreBack, 4, -1, "+1 +3 -3 +4 -4 -1"},
+		{"stop", 0, ast.IgnoreBack, -1, 7, "+0 +1 +3 -3 +4 +6 -6 +7"},
+		{"out of range", 9, ast.IgnoreBack, -1, -1, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ""
+			visit := func(sign string, id int) {
+				if got != "" {
+					got += " "
+				}
+				got += fmt.Sprintf("%s%d", sign, id)
+			}
+			tu.WalkWith(test.root, test.back, func(id int) ast.WalkAction {
+				visit("+", id)
+				switch id {
+				case test.skip:
+					return ast.WalkSkip
+				case test.stop:
+					return ast.WalkStop
+				}
+				return ast.WalkContinue
+			}, func(id int) ast.WalkAction {
+				visit("-", id)
+				return ast.WalkContinue
+			})
+			assertEqualString(t, got, test.expect)
+		})
+	}
+
+	count := 0
+	tu.Walk(0, nil, func(id int) ast.WalkAction {
+		count++
+		return ast.WalkContinue
+	})
+	assertEqualInt(t, count, 8)
+}
+
+func TestWalkBackLoop(t *testing.T) {
+	// A Back cursor leading to its own ancestor is not followed.
+	tu := &ast.TranslationUnit{
+		Cursors: []ast.Cursor{
+			{CursorKindId: cursorkind.TranslationUnit, ParentIndex: -1, Children: ast.IndexPair{Head: 1, Len: 1}},
+			{CursorKindId: cursorkind.StructDecl, ParentIndex: 0, Children: ast.IndexPair{Head: 2, Len: 1}},
+			{CursorKindId: cursorkind.Back, ParentIndex: 1},
+		},
+		Back: map[int]int{2: 1},
+	}
+	assertEqualString(t, fmt.Sprint(tu.Descendants(0, cursorkind.StructDecl, ast.FollowBack)), "[1]")
+}
+
+func TestTraversals(t *testing.T) {
+	tu := walkTu()
+
+	tests := []struct {
+		name   string
+		got    []int
+		expect string
+	}{
+		{"children", tu.Children(0, ast.IgnoreBack), "[1 2]"},
+		{"children ignore", tu.Children(2, ast.IgnoreBack), "[]"},
+		{"children keep", tu.Children(2, ast.KeepBack), "[5]"},
+		{"children follow", tu.Children(2, ast.FollowBack), "[3]"},
+		{"children of back", tu.Children(5, ast.FollowBack), "[]"},
+		{"children of leaf", tu.Children(8, ast.IgnoreBack), "[]"},
+		{"ancestors", tu.Ancestors(8), "[7 4 1 0]"},
+		{"ancestors of root", tu.Ancestors(0), "[]"},
+		{"siblings", tu.Siblings(6, ast.IgnoreBack), "[7]"},
+		{"siblings of root", tu.Siblings(0, ast.IgnoreBack), "[]"},
+		{"calls", tu.Descendants(0, cursorkind.CallExpr, ast.IgnoreBack), "[6 7]"},
+		{"parms", tu.Descendants(0, cursorkind.ParmDecl, ast.IgnoreBack), "[3]"},
+		{"parms follow", tu.Descendants(0, cursorkind.ParmDecl, ast.FollowBack), "[3 3]"},
+		{"not self", tu.Descendants(7, cursorkind.CallExpr, ast.IgnoreBack), "[]"},
+	}
+	for _, test := range tests {
+		got := fmt.Sprint(test.got)
+		if got != test.expect {
+			t.Errorf("%s: got %s, expected %s", test.name, got, test.expect)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// File to test call graph extraction, with direct, indirect and recursive
+// calls.
+static int twice(int x) { return 2 * x; }
+
+static int apply(int (*fn)(int), int x) { return fn(x); }
+
+struct ops {
+    int (*op)(int);
+};
+
+int fact(int n) { return n <= 1 ? 1 : n * fact(n - 1); }
+
+int main(void) {
+    struct ops o = { twice };
+    return apply(twice, fact(3)) + o.op(1);
+}
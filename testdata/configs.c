@@ -0,0 +1,24 @@
+// File to test the configuration explorer: DEBUG and WIDE change what is
+// compiled, and some code is compiled under no configuration.
+#ifdef WIDE
+typedef long value;
+#else
+typedef int value;
+#endif
+
+struct counter {
+    value count;
+#ifdef DEBUG
+    const char *name;
+#endif
+};
+
+#ifdef DEBUG
+void trace(const char *msg);
+#endif
+
+#if 0
+int legacy(void);
+#endif
+
+value add(value a, value b) { return a + b; }
@@ -0,0 +1,5 @@
+// File to test unused include detection: g is declared in proto.h and only
+// defined here.
+#include "proto.h"
+
+int g(int x) { return x; }
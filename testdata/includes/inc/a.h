@@ -0,0 +1,6 @@
+#ifndef A_H
+#define A_H
+#include "b.h"
+#include <c.h>
+#define A B
+#endif
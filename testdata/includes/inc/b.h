@@ -0,0 +1,5 @@
+#ifndef B_H
+#define B_H
+#include "a.h"
+#define B 2
+#endif
@@ -0,0 +1,7 @@
+// File to test the include graph, with a search path, a header included
+// twice and an include cycle.
+#include "local.h"
+#include <a.h>
+#include <c.h>
+
+int main(void) { return A + C + LOCAL; }
@@ -0,0 +1,7 @@
+// File to test unused include detection: c.h is not used, and B comes from
+// b.h, only included through a.h.
+#include <a.h>
+#include <c.h>
+#include "local.h"
+
+int f(void) { return B + LOCAL; }
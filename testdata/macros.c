@@ -0,0 +1,17 @@
+// File to test the macro report: expansions, unused macros, macros using
+// other macros and function-like macros that could be inline functions.
+#define SIZE 16
+#define DOUBLE_SIZE (2 * SIZE)
+#define UNUSED 1
+#define SQUARE(x) ((x) * (x))
+#define NAME(x) #x
+#define CHECK(x) do { if (!(x)) return -1; } while (0)
+#define MAX(a, b) ((a) > (b) ? (a) : (b))
+
+static int table[DOUBLE_SIZE];
+
+int check(int n) {
+    CHECK(n < SIZE);
+    table[n] = SQUARE(n);
+    return MAX(table[n], SIZE) + sizeof NAME(n);
+}
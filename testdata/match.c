@@ -0,0 +1,21 @@
+// File to test cursor matchers.
+typedef unsigned long size_t;
+void *malloc(size_t size);
+void free(void *p);
+
+static char *buffer;
+
+void foo(int n) {
+    buffer = malloc(n);
+    if (n > 10)
+        free(buffer);
+}
+
+int bar(void) {
+    return 1;
+}
+
+void baz(void) {
+    char *p = malloc(4);
+    free(p);
+}
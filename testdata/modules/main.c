@@ -0,0 +1,6 @@
+#include "circle.h"
+
+int main(void) {
+	struct circle c = {{0, 0}, 1};
+	return c.radius > 0;
+}
@@ -0,0 +1,11 @@
+#ifndef CIRCLE_H
+#define CIRCLE_H
+
+#include "shapes.h"
+
+struct circle {
+	struct point center;
+	double radius;
+};
+
+#endif
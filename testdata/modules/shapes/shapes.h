@@ -0,0 +1,8 @@
+#ifndef SHAPES_H
+#define SHAPES_H
+
+struct point {
+	double x, y;
+};
+
+#endif